@@ -4,87 +4,276 @@ import (
 	"context"
 	"errors"
 	"flag"
+	"fmt"
 	"log/slog"
+	"maps"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/dedup"
+	"github.com/erobsham/reform/lib/filter"
+	"github.com/erobsham/reform/lib/limit"
 	"github.com/erobsham/reform/lib/log"
-	"github.com/erobsham/reform/lib/parser"
+	"github.com/erobsham/reform/lib/redact"
+	"github.com/erobsham/reform/lib/route"
 	"github.com/erobsham/reform/lib/streams"
+	"github.com/erobsham/reform/lib/types"
 )
 
 func parseArgs() config.CliArgs {
 	a := config.CliArgs{}
 
+	// `reform tui [flags]` shows the stream in an interactive viewer, rather than printing it
+	cliArgs := os.Args[1:]
+	if len(cliArgs) > 0 && cliArgs[0] == "tui" {
+		a.TUI = true
+		cliArgs = cliArgs[1:]
+	}
+
 	flag.IntVar(&a.LogLevel, "log", int(slog.LevelInfo), "set log level (default: 0)")
 	flag.StringVar(&a.Cmd, "cmd", "", "command to read the stdout from ie 'ssh user@host tail -F /var/log/syslog' (default: none)")
 	flag.StringVar(&a.OutputPath, "out", "", "file to append processed output to -- if not set, defaults to stdout (default: none)")
 	flag.StringVar(&a.ConfigPath, "config", "", "path to a json config to allow reading multiple streams at once (default: none)")
-	flag.StringVar(&a.SeqServer, "seq", "", "specify `{hostname}:{port}[;{apikey}]` ex: `localhost:5341` | `localhost:5341;api-key-value` (default: none)")
+	flag.StringVar(&a.SeqServer, "seq", "", "specify `{url}[;{apikey}]` ex: `localhost:5341` | `https://seq.internal:5341/prefix;api-key-value` (default: none)")
+	flag.BoolVar(&a.SeqGzip, "seq-gzip", false, "gzip requests to the seq server (default: false)")
+	flag.StringVar(&a.SeqTLS.CAFile, "seq-ca", "", "PEM bundle of CAs to trust for the seq server (default: system roots)")
+	flag.StringVar(&a.SeqTLS.CertFile, "seq-cert", "", "client certificate to present to the seq server (default: none)")
+	flag.StringVar(&a.SeqTLS.KeyFile, "seq-key", "", "private key for `-seq-cert` (default: none)")
+	flag.BoolVar(&a.SeqTLS.InsecureSkipVerify, "seq-insecure", false, "skip verifying the seq server's certificate (default: false)")
+	flag.StringVar(&a.SeqSpool, "seq-spool", "", "directory to save logs to while the seq server is unreachable, they're resent once it's back (default: none)")
+	flag.BoolVar(&a.Terminal, "term", false, "print colorized columns sized to the terminal, when outputting to stdout (default: false)")
+	flag.StringVar(&a.Filter, "filter", "", "only keep lines matching the expression, ie 'level >= warn and not proc == kernel' (default: none)")
+	flag.BoolVar(&a.Redact, "redact", false, "mask api keys, tokens, passwords in urls, emails, and mac / ip addresses in the output (default: false)")
+	flag.DurationVar(&a.OrderDelay, "order-delay", 0, "merge lines across the inputs in timestamp order, waiting up to this long for late ones ie '2s' (default: off)")
+	flag.IntVar(&a.TUIHistory, "tui-history", streams.DefaultTUIHistory, "number of events `reform tui` keeps to scroll back through")
+	flag.DurationVar(&a.ShutdownTimeout, "shutdown-timeout", time.Second*10, "max time to wait for outputs to flush on exit")
 
-	flag.Parse()
+	flag.CommandLine.Parse(cliArgs)
 
 	return a
 }
 
+type namedOutput struct {
+	name    string
+	queue   config.OutputQueueCfg
+	onError config.OutputErrorCfg
+	filter  *filter.Expr
+	streams.OutputStream
+}
+
 func main() {
 	args := parseArgs()
 
-	inStreams, outStreams := handleArgs(args)
-	if len(inStreams) == 0 || len(outStreams) == 0 {
+	// inputs are tied to this context, outputs are not -- they're shut down
+	// explicitly once the inputs have been drained.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, func() {
+		// restore the default handling, so a second signal exits immediately
+		stop()
+		log.Default().Info("shutting down, signal again to force quit")
+	})
+
+	// lets a `fail-fast` output bring everything down
+	ctx, abort := context.WithCancelCause(ctx)
+	defer abort(nil)
+
+	inStreams, outputs, pipe := handleArgs(ctx, abort, args)
+	if len(inStreams) == 0 || len(outputs) == 0 {
+		closeInputs(inStreams)
+		closeOutputs(outputs, args.ShutdownTimeout)
 		return
 	}
 
-	runloop(inStreams, outStreams)
+	runloop(ctx, inStreams, outputs, pipe, args.ShutdownTimeout, args.TUI)
 }
 
-func handleArgs(args config.CliArgs) (inStreams []streams.InputStream, outStreams []streams.OutputStream) {
+func handleArgs(ctx context.Context, abort context.CancelCauseFunc, args config.CliArgs) (inStreams []streams.InputStream, outputs []*queuedOutput, pipe *pipeline) {
 	inStreams = []streams.InputStream{}
-	outStreams = []streams.OutputStream{}
+	outStreams := []namedOutput{}
+	pipe = &pipeline{filters: lineFilter{sources: map[string]*filter.Expr{}}}
 
 	// clamp loglevel to the range we're using
 	logLevel := max(slog.Level(args.LogLevel), slog.LevelDebug)
 	logLevel = min(logLevel, slog.LevelError)
 	log.SetDefaultLogLevel(logLevel)
 
-	if args.Cmd != "" {
-		cmd, args := config.ParseCmdStr(args.Cmd)
-		s := streams.NewCmdStream(context.Background(), "cmd", cmd, args...)
-		inStreams = append(inStreams, s)
+	if args.Filter != "" {
+		expr, err := filter.Parse(args.Filter)
+		if err != nil {
+			log.Default().Error("invalid filter",
+				slog.String("error", err.Error()),
+			)
+			return nil, nil, nil
+		}
+		pipe.filters.global = append(pipe.filters.global, expr)
+	}
+
+	pipe.orderDelay = args.OrderDelay
+
+	if args.Redact {
+		// the built-in detectors, a `redact` config replaces this
+		redactor, err := redact.New(config.RedactCfg{})
+		if err != nil {
+			log.Default().
+				Error("error creating redactor",
+					slog.String("error", err.Error()),
+				)
+			return nil, nil, nil
+		}
+		pipe.redactor = redactor
 	}
+
+	// outputs and the config are all built and checked before any input is
+	// started, anything already built is closed if one of them fails
+	abandon := func() {
+		closeNamedOutputs(outStreams)
+	}
+
 	if args.OutputPath != "" {
-		out, err := streams.NewOutputFile(args.OutputPath)
+		out, err := streams.NewOutputFile(config.OutputFileCfg{Path: args.OutputPath})
 		if err != nil {
 			log.Default().Error("invalid output path",
 				slog.String("path", args.OutputPath),
 				slog.String("err", err.Error()),
 			)
-			return nil, nil
+			abandon()
+			return nil, nil, nil
 		}
-		outStreams = append(outStreams, out)
+		outStreams = append(outStreams, namedOutput{name: "file", OutputStream: out})
 	}
 	if args.SeqServer != "" {
-		host, key := config.ParseSeqServer(args.SeqServer)
-		s := streams.NewSeqStream(context.Background(), host, key)
-		outStreams = append(outStreams, s)
+		serverURL, key, err := config.ParseSeqServer(args.SeqServer)
+		if err != nil {
+			log.Default().Error("invalid seq server",
+				slog.String("seq", args.SeqServer),
+				slog.String("error", err.Error()),
+			)
+			abandon()
+			return nil, nil, nil
+		}
+
+		s, err := streams.NewSeqStream(context.Background(), config.OutputSeqCfg{
+			URL:      serverURL,
+			APIKey:   key,
+			TLS:      args.SeqTLS,
+			Gzip:     args.SeqGzip,
+			SpoolDir: args.SeqSpool,
+		})
+		if err != nil {
+			log.Default().Error("unable to create seq output",
+				slog.String("error", err.Error()),
+			)
+			abandon()
+			return nil, nil, nil
+		}
+		outStreams = append(outStreams, namedOutput{name: "seq", OutputStream: s})
 	}
 
+	sources := map[string]config.SourceStreamCfg{}
 	if args.ConfigPath != "" {
-		ins, outs := handleConfig(args.ConfigPath)
-		inStreams = append(inStreams, ins...)
+		// multiple Seq outputs sharing a spool would resend each other's batches
+		spoolDirs := map[string]string{}
+		if args.SeqServer != "" && args.SeqSpool != "" {
+			spoolDirs[filepath.Clean(args.SeqSpool)] = "seq"
+		}
+
+		var outs []namedOutput
+		sources, outs = handleConfig(args.ConfigPath, spoolDirs, pipe, args.TUI)
+		if sources == nil {
+			// the config couldn't be loaded, don't carry on without (ie) its redaction
+			abandon()
+			return nil, nil, nil
+		}
 		outStreams = append(outStreams, outs...)
 	}
 
-	if len(outStreams) == 0 {
-		out := &streams.StdoutStream{}
-		outStreams = append(outStreams, out)
+	// checked before starting the tui, which takes over the terminal until it's closed
+	if args.Cmd == "" && len(sources) == 0 {
+		log.Default().Error("no inputs, pass -cmd or a -config with sources")
+		abandon()
+		return nil, nil, nil
+	}
+
+	if args.TUI {
+		tui, err := streams.NewTUIStream(args.TUIHistory, func() { abort(nil) })
+		if err != nil {
+			log.Default().Error("unable to start the tui",
+				slog.String("error", err.Error()),
+			)
+			abandon()
+			return nil, nil, nil
+		}
+		// dropping events is better than holding up the other outputs if the terminal is slow
+		queue := config.OutputQueueCfg{Overflow: config.OverflowPolicy_DropOldest}
+		outStreams = append(outStreams, namedOutput{name: "tui", queue: queue, OutputStream: tui})
+	} else if len(outStreams) == 0 {
+		var out streams.OutputStream = &streams.StdoutStream{}
+		if args.Terminal {
+			out = streams.NewTerminalStream(config.OutputStdoutCfg{Terminal: true})
+		}
+		outStreams = append(outStreams, namedOutput{name: "stdout", OutputStream: out})
+	}
+
+	// the cli outputs (`file`, `seq`, `tui`, `stdout`) share a name space with
+	// the config's, and the routes / stats go by name
+	named := map[string]struct{}{}
+	for _, out := range outStreams {
+		if _, exists := named[out.name]; exists {
+			log.Default().Error("duplicate output name, rename the config output",
+				slog.String("name", out.name),
+			)
+			abandon()
+			return nil, nil, nil
+		}
+		named[out.name] = struct{}{}
+	}
+
+	outputs, err := queueOutputs(outStreams, abort)
+	if err != nil {
+		log.Default().Error("error creating output queue",
+			slog.String("error", err.Error()),
+		)
+		return nil, nil, nil
+	}
+
+	// checked against the outputs which were actually built, ie a misspelled
+	// output would otherwise silently drop its route's lines
+	names := make([]string, len(outputs))
+	for i, out := range outputs {
+		names[i] = out.Name()
+	}
+	if pipe.router, err = route.New(pipe.routes, names); err != nil {
+		log.Default().Error("invalid routes",
+			slog.String("error", err.Error()),
+		)
+		closeOutputs(outputs, args.ShutdownTimeout)
+		return nil, nil, nil
+	}
+
+	if args.Cmd != "" {
+		cmd, args := config.ParseCmdStr(args.Cmd)
+		s := streams.NewCmdStream(ctx, "cmd", cmd, args...)
+		inStreams = append(inStreams, s)
+	}
+	for name, src := range sources {
+		s := streams.NewCmdStream(ctx, name, src.Cmd, src.Args...)
+		inStreams = append(inStreams, s)
 	}
 
 	return
 }
 
-func handleConfig(cfgPath string) (inStreams []streams.InputStream, outStreams []streams.OutputStream) {
-	inStreams = []streams.InputStream{}
-	outStreams = []streams.OutputStream{}
+// builds the outputs + pipeline stages from the config, and returns its sources
+// for the caller to start.  the sources are nil if the config is invalid.
+func handleConfig(cfgPath string, spoolDirs map[string]string, pipe *pipeline, tui bool) (sources map[string]config.SourceStreamCfg, outStreams []namedOutput) {
+	outStreams = []namedOutput{}
 
 	cfg, err := config.LoadConfigFrom(cfgPath)
 	if err != nil {
@@ -95,16 +284,96 @@ func handleConfig(cfgPath string) (inStreams []streams.InputStream, outStreams [
 		return nil, nil
 	}
 
+	if cfg.Filter != nil {
+		pipe.filters.global = append(pipe.filters.global, cfg.Filter)
+	}
+	pipe.routes = cfg.Routes
+	// `-order-delay` takes precedence
+	if cfg.OrderDelay > 0 && pipe.orderDelay == 0 {
+		pipe.orderDelay = time.Duration(cfg.OrderDelay)
+	}
+
+	if cfg.Dedup != nil {
+		dedupCfg, err := config.ParseDedupCfg(cfg.Dedup)
+		if err != nil {
+			log.Default().
+				Error("dedup config parsing error",
+					slog.String("error", err.Error()),
+				)
+			return nil, nil
+		}
+		pipe.deduper = dedup.New(dedupCfg)
+	}
+
+	if cfg.Limits != nil {
+		limitCfg, err := config.ParseLimitCfg(cfg.Limits)
+		if err != nil {
+			log.Default().
+				Error("limits config parsing error",
+					slog.String("error", err.Error()),
+				)
+			return nil, nil
+		}
+		pipe.limiter = limit.New(limitCfg)
+	}
+
+	if cfg.Redact != nil {
+		redactCfg, err := config.ParseRedactCfg(cfg.Redact)
+		if err != nil {
+			log.Default().
+				Error("redact config parsing error",
+					slog.String("error", err.Error()),
+				)
+			return nil, nil
+		}
+		if pipe.redactor, err = redact.New(redactCfg); err != nil {
+			log.Default().
+				Error("error creating redactor",
+					slog.String("error", err.Error()),
+				)
+			return nil, nil
+		}
+	}
+
+	sources = map[string]config.SourceStreamCfg{}
 	for name, src := range cfg.Sources {
-		s := streams.NewCmdStream(context.Background(), name, src.Cmd, src.Args...)
-		inStreams = append(inStreams, s)
+		sources[name] = src
+		if src.Filter != nil {
+			pipe.filters.sources[name] = src.Filter
+		}
 	}
 
 	for name, out := range cfg.Outputs {
 		switch out.OutputType {
 		case config.OutputType_Stdout:
-			o := &streams.StdoutStream{}
-			outStreams = append(outStreams, o)
+			if tui {
+				log.Default().
+					Warn("stdout outputs are disabled while the tui is running",
+						slog.String("name", name),
+					)
+				continue
+			}
+
+			cfg, err := config.ParseOutputStdoutCfg(out.Config)
+			if err != nil {
+				log.Default().
+					Error("stdout output config parsing error",
+						slog.String("name", name),
+						slog.String("error", err.Error()),
+					)
+				continue
+			}
+
+			o, err := streams.NewStdoutStream(cfg)
+			if err != nil {
+				log.Default().
+					Error("error creating stdout output",
+						slog.String("name", name),
+						slog.String("error", err.Error()),
+					)
+				continue
+			}
+			outStreams = append(outStreams, namedOutput{name, out.Queue, out.OnError, out.Filter, o})
 		case config.OutputType_File:
 			cfg, err := config.ParseOutputFileCfg(out.Config)
 			if err != nil {
@@ -116,7 +385,7 @@ func handleConfig(cfgPath string) (inStreams []streams.InputStream, outStreams [
 				continue
 			}
 
-			o, err := streams.NewOutputFile(cfg.Path)
+			o, err := streams.NewOutputFile(cfg)
 			if err != nil {
 				log.Default().
 					Error("error creating output file",
@@ -125,7 +394,168 @@ func handleConfig(cfgPath string) (inStreams []streams.InputStream, outStreams [
 					)
 				continue
 			}
-			outStreams = append(outStreams, o)
+			outStreams = append(outStreams, namedOutput{name, out.Queue, out.OnError, out.Filter, o})
+		case config.OutputType_Seq:
+			cfg, err := config.ParseOutputSeqCfg(out.Config)
+			if err != nil {
+				log.Default().
+					Error("seq output config parsing error",
+						slog.String("name", name),
+						slog.String("error", err.Error()),
+					)
+				continue
+			}
+
+			if cfg.SpoolDir != "" {
+				dir := filepath.Clean(cfg.SpoolDir)
+				if other, exists := spoolDirs[dir]; exists {
+					log.Default().
+						Error("seq outputs can't share a spool_dir",
+							slog.String("name", name),
+							slog.String("other", other),
+							slog.String("spool_dir", cfg.SpoolDir),
+						)
+					continue
+				}
+				spoolDirs[dir] = name
+			}
+
+			o, err := streams.NewSeqStream(context.Background(), cfg)
+			if err != nil {
+				log.Default().
+					Error("error creating seq output",
+						slog.String("name", name),
+						slog.String("error", err.Error()),
+					)
+				continue
+			}
+			outStreams = append(outStreams, namedOutput{name, out.Queue, out.OnError, out.Filter, o})
+		case config.OutputType_Loki:
+			cfg, err := config.ParseOutputLokiCfg(out.Config)
+			if err != nil {
+				log.Default().
+					Error("loki output config parsing error",
+						slog.String("name", name),
+						slog.String("error", err.Error()),
+					)
+				continue
+			}
+
+			o, err := streams.NewLokiStream(context.Background(), cfg)
+			if err != nil {
+				log.Default().
+					Error("error creating loki output",
+						slog.String("name", name),
+						slog.String("error", err.Error()),
+					)
+				continue
+			}
+			outStreams = append(outStreams, namedOutput{name, out.Queue, out.OnError, out.Filter, o})
+		case config.OutputType_Elasticsearch:
+			cfg, err := config.ParseOutputElasticCfg(out.Config)
+			if err != nil {
+				log.Default().
+					Error("elasticsearch output config parsing error",
+						slog.String("name", name),
+						slog.String("error", err.Error()),
+					)
+				continue
+			}
+
+			o, err := streams.NewElasticStream(context.Background(), cfg)
+			if err != nil {
+				log.Default().
+					Error("error creating elasticsearch output",
+						slog.String("name", name),
+						slog.String("error", err.Error()),
+					)
+				continue
+			}
+			outStreams = append(outStreams, namedOutput{name, out.Queue, out.OnError, out.Filter, o})
+		case config.OutputType_OTLP:
+			cfg, err := config.ParseOutputOTLPCfg(out.Config)
+			if err != nil {
+				log.Default().
+					Error("otlp output config parsing error",
+						slog.String("name", name),
+						slog.String("error", err.Error()),
+					)
+				continue
+			}
+
+			o, err := streams.NewOTLPStream(context.Background(), cfg)
+			if err != nil {
+				log.Default().
+					Error("error creating otlp output",
+						slog.String("name", name),
+						slog.String("error", err.Error()),
+					)
+				continue
+			}
+			outStreams = append(outStreams, namedOutput{name, out.Queue, out.OnError, out.Filter, o})
+		case config.OutputType_GELF:
+			cfg, err := config.ParseOutputGELFCfg(out.Config)
+			if err != nil {
+				log.Default().
+					Error("gelf output config parsing error",
+						slog.String("name", name),
+						slog.String("error", err.Error()),
+					)
+				continue
+			}
+
+			o, err := streams.NewGELFStream(cfg)
+			if err != nil {
+				log.Default().
+					Error("error creating gelf output",
+						slog.String("name", name),
+						slog.String("error", err.Error()),
+					)
+				continue
+			}
+			outStreams = append(outStreams, namedOutput{name, out.Queue, out.OnError, out.Filter, o})
+		case config.OutputType_Splunk:
+			cfg, err := config.ParseOutputSplunkCfg(out.Config)
+			if err != nil {
+				log.Default().
+					Error("splunk output config parsing error",
+						slog.String("name", name),
+						slog.String("error", err.Error()),
+					)
+				continue
+			}
+
+			o, err := streams.NewSplunkStream(context.Background(), cfg)
+			if err != nil {
+				log.Default().
+					Error("error creating splunk output",
+						slog.String("name", name),
+						slog.String("error", err.Error()),
+					)
+				continue
+			}
+			outStreams = append(outStreams, namedOutput{name, out.Queue, out.OnError, out.Filter, o})
+		case config.OutputType_Syslog:
+			cfg, err := config.ParseOutputSyslogCfg(out.Config)
+			if err != nil {
+				log.Default().
+					Error("syslog output config parsing error",
+						slog.String("name", name),
+						slog.String("error", err.Error()),
+					)
+				continue
+			}
+
+			o, err := streams.NewSyslogStream(cfg)
+			if err != nil {
+				log.Default().
+					Error("error creating syslog output",
+						slog.String("name", name),
+						slog.String("error", err.Error()),
+					)
+				continue
+			}
+			outStreams = append(outStreams, namedOutput{name, out.Queue, out.OnError, out.Filter, o})
 		// `case config.OutputType_None:`
 		default:
 			log.Default().
@@ -139,13 +569,78 @@ func handleConfig(cfgPath string) (inStreams []streams.InputStream, outStreams [
 	return
 }
 
-func runloop(inStreams []streams.InputStream, outStreams []streams.OutputStream) {
+// how often the pipeline checks for held lines / summaries which are due, when
+// no lines are arriving
+const pipelineTickInterval = time.Millisecond * 250
 
-	a := streams.NewStreamAggregator(context.Background(), inStreams)
+// `holdOpen` keeps the outputs open after the inputs finish, until `ctx` is done
+// (ie so the tui can still be browsed).
+func runloop(ctx context.Context, inStreams []streams.InputStream, outputs []*queuedOutput, pipe *pipeline, shutdownTimeout time.Duration, holdOpen bool) {
+	router := pipe.router
+	send := make([]bool, len(outputs))
+
+	var a *streams.StreamAggregator
+	if pipe.orderDelay > 0 {
+		a = streams.NewOrderedStreamAggregator(ctx, inStreams, pipe.orderDelay)
+	} else {
+		a = streams.NewStreamAggregator(ctx, inStreams)
+	}
+	stats := newRunStats(inStreams, outputs, router)
 
 	errs := []error{}
+
+	// sends a line to the outputs its route picks.  each output has its own
+	// queue, so this only blocks when one is full and set to the `block`
+	// overflow policy.  an output which stops taking lines is dropped, the
+	// others carry on.
+	deliver := func(line types.ParsedLine) {
+		router.Route(line, send)
+		for i, out := range outputs {
+			if !send[i] || out.stopped {
+				continue
+			}
+			if !out.filter.Match(line) {
+				out.filtered += 1
+				continue
+			}
+			err := out.Output(line)
+			if err != nil {
+				// its error policy has already dealt with the failure
+				log.Default().Error("output stopped, no more lines will be sent to it",
+					slog.String("name", out.Name()),
+					slog.String("error", err.Error()),
+				)
+				out.stopped = true
+			}
+		}
+	}
+
+	// the stages after dedup
+	process := func(line types.ParsedLine) {
+		if !pipe.limiter.Allow(line) {
+			stats.limited[line.Source] += 1
+			return
+		}
+		pipe.redactor.Redact(&line)
+		deliver(line)
+	}
+
+	// lets go of the held lines / summaries which are due, or all of them when `force`
+	flush := func(force bool) {
+		for _, held := range pipe.deduper.Flush(force) {
+			process(held)
+		}
+		if summary, ok := pipe.limiter.Summary(force); ok {
+			deliver(summary)
+		}
+	}
+
 	for {
-		line, err := a.Next()
+		line, err := a.NextTimeout(pipelineTickInterval)
+		if errors.Is(err, streams.ErrNextTimeout) {
+			flush(false)
+			continue
+		}
 		if err != nil {
 			if errors.Is(err, streams.ErrStreamClosed) {
 				break
@@ -154,28 +649,213 @@ func runloop(inStreams []streams.InputStream, outStreams []streams.OutputStream)
 			break
 		}
 
-		parsed := parser.ParseLine(line)
+		parsed := line.Parsed
+		stats.sources[line.Source] += 1
 
-		for _, out := range outStreams {
-			err := out.Output(parsed)
-			if err != nil {
-				errs = append(errs, err)
-			}
+		if !pipe.filters.match(parsed) {
+			stats.filtered[line.Source] += 1
+			continue
 		}
-		if len(errs) > 0 {
-			break
+
+		if pipe.deduper != nil {
+			pipe.deduper.Add(parsed)
+		} else {
+			process(parsed)
 		}
+		flush(false)
 	}
 
+	// whatever's still held, and dropped since the last summary
+	flush(true)
+
 	for _, err := range errs {
 		log.Default().Error("encountered error",
 			slog.String("error", err.Error()),
 		)
 	}
 
+	if holdOpen && len(errs) == 0 {
+		log.Default().Info("all inputs have finished, press q to quit")
+		<-ctx.Done()
+	}
+
+	// stop any inputs that are still running before flushing the outputs
+	a.Close()
+	closeOutputs(outputs, shutdownTimeout)
+
+	stats.log()
+}
+
+type queuedOutput struct {
+	*streams.QueuedOutput
+	filter *filter.Expr
+	// lines the filter kept from the output
+	filtered uint64
+	// the output isn't taking any more lines, ie it failed under `fail-fast`
+	stopped bool
+}
+
+// wraps each output with its own queue + worker, so they run independently.
+// if any queue can't be created, all the outputs are closed.
+func queueOutputs(outStreams []namedOutput, onFatal func(error)) ([]*queuedOutput, error) {
+	outputs := []*queuedOutput{}
+	for i, out := range outStreams {
+		q, err := streams.NewQueuedOutput(out.name, out.OutputStream, out.queue, out.onError, onFatal)
+		if err != nil {
+			for _, queued := range outputs {
+				queued.Close()
+			}
+			closeNamedOutputs(outStreams[i:])
+			return nil, fmt.Errorf("output %q: %w", out.name, err)
+		}
+		outputs = append(outputs, &queuedOutput{QueuedOutput: q, filter: out.filter})
+	}
+	return outputs, nil
+}
+
+// stops inputs which were started before something else failed.
+func closeInputs(inStreams []streams.InputStream) {
+	for _, in := range inStreams {
+		in.Close()
+	}
+}
+
+// closes outputs which were built before something else failed.
+func closeNamedOutputs(outStreams []namedOutput) {
 	for _, out := range outStreams {
 		out.Close()
 	}
+}
 
-	a.Close()
+// closes (flushing) all the outputs in parallel, giving up on any which
+// haven't finished by the timeout.
+func closeOutputs(outputs []*queuedOutput, timeout time.Duration) {
+	lock := sync.Mutex{}
+	pending := map[string]struct{}{}
+
+	wg := sync.WaitGroup{}
+	for _, out := range outputs {
+		pending[out.Name()] = struct{}{}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out.Close()
+
+			lock.Lock()
+			delete(pending, out.Name())
+			lock.Unlock()
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		lock.Lock()
+		defer lock.Unlock()
+		for name := range pending {
+			log.Default().Error("timed out flushing output",
+				slog.String("name", name),
+				slog.Duration("timeout", timeout),
+			)
+		}
+	}
+}
+
+//#< Pipeline
+
+// the stages each line goes through between being parsed and reaching the outputs
+type pipeline struct {
+	filters lineFilter
+	// nil when nothing is deduplicated / limited / redacted
+	deduper  *dedup.Deduper
+	limiter  *limit.Limiter
+	redactor *redact.Redactor
+	routes   []config.RouteCfg
+	// picks the outputs for each line, built from `routes` once the outputs are
+	router *route.Router
+
+	// merge the inputs in timestamp order when set, see `streams.NewOrderedStreamAggregator`
+	orderDelay time.Duration
+}
+
+// lines have to match all the `global` filters, and their source's filter, to
+// reach any of the outputs
+type lineFilter struct {
+	global  []*filter.Expr
+	sources map[string]*filter.Expr
+}
+
+func (f *lineFilter) match(line types.ParsedLine) bool {
+	for _, expr := range f.global {
+		if !expr.Match(line) {
+			return false
+		}
+	}
+	return f.sources[line.Source].Match(line)
+}
+
+//#> Pipeline
+
+//#< Run Stats
+
+type runStats struct {
+	sources  map[string]uint64
+	filtered map[string]uint64
+	limited  map[string]uint64
+	outputs  []*queuedOutput
+	router   *route.Router
+}
+
+func newRunStats(inStreams []streams.InputStream, outputs []*queuedOutput, router *route.Router) *runStats {
+	s := &runStats{
+		sources:  map[string]uint64{},
+		filtered: map[string]uint64{},
+		limited:  map[string]uint64{},
+		outputs:  outputs,
+		router:   router,
+	}
+	// pre-populate so sources that never saw a line still show up
+	for _, in := range inStreams {
+		s.sources[in.Name()] = 0
+	}
+	return s
 }
+
+func (s *runStats) log() {
+	for _, name := range slices.Sorted(maps.Keys(s.sources)) {
+		log.Default().Info("source summary",
+			slog.String("name", name),
+			slog.Uint64("lines", s.sources[name]),
+			slog.Uint64("filtered", s.filtered[name]),
+			slog.Uint64("limited", s.limited[name]),
+		)
+	}
+	for _, rt := range s.router.Stats() {
+		log.Default().Info("route summary",
+			slog.String("name", rt.Name),
+			slog.Uint64("lines", rt.Lines),
+		)
+	}
+	for _, out := range s.outputs {
+		o := out.Stats()
+		log.Default().Info("output summary",
+			slog.String("name", out.Name()),
+			slog.Uint64("sent", o.Sent),
+			slog.Uint64("filtered", out.filtered),
+			slog.Uint64("failed", o.Failed),
+			slog.Uint64("dropped", o.Dropped),
+			slog.Uint64("spilled", o.Spilled),
+			slog.Uint64("dead_lettered", o.DeadLettered),
+			slog.Bool("degraded", o.Degraded),
+		)
+	}
+}
+
+//#> Run Stats
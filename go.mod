@@ -1,3 +1,11 @@
 module github.com/erobsham/reform
 
 go 1.24.4
+
+require (
+	github.com/klauspost/compress v1.18.0
+	golang.org/x/term v0.34.0
+	google.golang.org/protobuf v1.36.6
+)
+
+require golang.org/x/sys v0.35.0 // indirect
@@ -0,0 +1,257 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// helpers for pulling typed values out of an output's `"config": {...}` map.
+// missing keys return the zero value, keys with the wrong type are an error.
+
+func cfgString(cfg map[string]any, key string) (string, error) {
+	v, exists := cfg[key]
+	if !exists || v == nil {
+		return "", nil
+	}
+	str, ok := v.(string)
+	if !ok {
+		return "", OutputTypeParseError(fmt.Sprintf("'%s' must be a string", key))
+	}
+	return str, nil
+}
+
+func cfgBool(cfg map[string]any, key string) (bool, error) {
+	v, exists := cfg[key]
+	if !exists || v == nil {
+		return false, nil
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, OutputTypeParseError(fmt.Sprintf("'%s' must be a bool", key))
+	}
+	return b, nil
+}
+
+func cfgInt(cfg map[string]any, key string) (int, error) {
+	v, exists := cfg[key]
+	if !exists || v == nil {
+		return 0, nil
+	}
+	// numbers from `encoding/json` are always float64
+	f, ok := v.(float64)
+	if !ok || f != float64(int(f)) {
+		return 0, OutputTypeParseError(fmt.Sprintf("'%s' must be an integer", key))
+	}
+	return int(f), nil
+}
+
+func cfgFloat(cfg map[string]any, key string) (float64, error) {
+	v, exists := cfg[key]
+	if !exists || v == nil {
+		return 0, nil
+	}
+	f, ok := v.(float64)
+	if !ok {
+		return 0, OutputTypeParseError(fmt.Sprintf("'%s' must be a number", key))
+	}
+	return f, nil
+}
+
+func cfgDuration(cfg map[string]any, key string) (time.Duration, error) {
+	str, err := cfgString(cfg, key)
+	if err != nil || str == "" {
+		return 0, err
+	}
+	d, err := time.ParseDuration(str)
+	if err != nil {
+		return 0, OutputTypeParseError(fmt.Sprintf("'%s' must be a duration: %s", key, err.Error()))
+	}
+	return d, nil
+}
+
+var byteSizeUnits = map[string]int64{
+	"":    1,
+	"b":   1,
+	"kb":  1000,
+	"mb":  1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"kib": 1024,
+	"mib": 1024 * 1024,
+	"gib": 1024 * 1024 * 1024,
+}
+
+// either a number of bytes, or a string with a unit ie `"100MB"` | `"1.5GiB"`
+func cfgByteSize(cfg map[string]any, key string) (int64, error) {
+	v, exists := cfg[key]
+	if !exists || v == nil {
+		return 0, nil
+	}
+
+	switch size := v.(type) {
+	case float64:
+		if size < 0 || size != float64(int64(size)) {
+			return 0, OutputTypeParseError(fmt.Sprintf("'%s' must be a positive whole number of bytes", key))
+		}
+		return int64(size), nil
+	case string:
+		str := strings.ToLower(strings.TrimSpace(size))
+		idx := strings.IndexFunc(str, func(r rune) bool {
+			return (r < '0' || r > '9') && r != '.'
+		})
+		if idx == -1 {
+			idx = len(str)
+		}
+
+		num, err := strconv.ParseFloat(str[:idx], 64)
+		unit, knownUnit := byteSizeUnits[strings.TrimSpace(str[idx:])]
+		if err != nil || !knownUnit || num < 0 {
+			return 0, OutputTypeParseError(fmt.Sprintf("'%s' must be a size like \"100MB\" or \"1GiB\"", key))
+		}
+		return int64(num * float64(unit)), nil
+	default:
+		return 0, OutputTypeParseError(fmt.Sprintf("'%s' must be a number of bytes or a size string", key))
+	}
+}
+
+func cfgMap(cfg map[string]any, key string) (map[string]any, error) {
+	v, exists := cfg[key]
+	if !exists || v == nil {
+		return nil, nil
+	}
+	m, ok := v.(map[string]any)
+	if !ok {
+		return nil, OutputTypeParseError(fmt.Sprintf("'%s' must be an object", key))
+	}
+	return m, nil
+}
+
+func cfgMapSlice(cfg map[string]any, key string) ([]map[string]any, error) {
+	v, exists := cfg[key]
+	if !exists || v == nil {
+		return nil, nil
+	}
+	list, ok := v.([]any)
+	if !ok {
+		return nil, OutputTypeParseError(fmt.Sprintf("'%s' must be a list of objects", key))
+	}
+
+	maps := make([]map[string]any, 0, len(list))
+	for _, item := range list {
+		m, ok := item.(map[string]any)
+		if !ok {
+			return nil, OutputTypeParseError(fmt.Sprintf("'%s' must be a list of objects", key))
+		}
+		maps = append(maps, m)
+	}
+	return maps, nil
+}
+
+func cfgStringSlice(cfg map[string]any, key string) ([]string, error) {
+	v, exists := cfg[key]
+	if !exists || v == nil {
+		return nil, nil
+	}
+	list, ok := v.([]any)
+	if !ok {
+		return nil, OutputTypeParseError(fmt.Sprintf("'%s' must be a list of strings", key))
+	}
+
+	strs := make([]string, 0, len(list))
+	for _, item := range list {
+		str, ok := item.(string)
+		if !ok {
+			return nil, OutputTypeParseError(fmt.Sprintf("'%s' must be a list of strings", key))
+		}
+		strs = append(strs, str)
+	}
+	return strs, nil
+}
+
+func cfgStringMap(cfg map[string]any, key string) (map[string]string, error) {
+	m, err := cfgMap(cfg, key)
+	if err != nil || m == nil {
+		return nil, err
+	}
+
+	strs := make(map[string]string, len(m))
+	for k, v := range m {
+		str, ok := v.(string)
+		if !ok {
+			return nil, OutputTypeParseError(fmt.Sprintf("'%s.%s' must be a string", key, k))
+		}
+		strs[k] = str
+	}
+	return strs, nil
+}
+
+// secrets can be given directly as `{key}`, or read from an env var
+// (`{key}_env`) / file (`{key}_file`) so they don't need to live in the config.
+func cfgSecret(cfg map[string]any, key string) (string, error) {
+	secret, err := cfgString(cfg, key)
+	if err != nil {
+		return "", err
+	}
+	secretEnv, err := cfgString(cfg, key+"_env")
+	if err != nil {
+		return "", err
+	}
+	secretFile, err := cfgString(cfg, key+"_file")
+	if err != nil {
+		return "", err
+	}
+
+	set := 0
+	for _, v := range []string{secret, secretEnv, secretFile} {
+		if v != "" {
+			set += 1
+		}
+	}
+	if set > 1 {
+		return "", OutputTypeParseError(fmt.Sprintf("only one of '%[1]s', '%[1]s_env', or '%[1]s_file' can be set", key))
+	}
+
+	switch {
+	case secretEnv != "":
+		secret = os.Getenv(secretEnv)
+		if secret == "" {
+			return "", OutputTypeParseError(fmt.Sprintf("env var %q for '%s_env' is empty", secretEnv, key))
+		}
+	case secretFile != "":
+		data, err := os.ReadFile(secretFile)
+		if err != nil {
+			return "", OutputTypeParseError(fmt.Sprintf("unable to read '%s_file': %s", key, err.Error()))
+		}
+		secret = strings.TrimSpace(string(data))
+	}
+
+	return secret, nil
+}
+
+// accepts either a full url, or a bare `{hostname}:{port}` (which defaults
+// to `http://`), and returns it without a trailing '/'.
+func normalizeBaseURL(rawURL string) (string, error) {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", OutputTypeParseError(fmt.Sprintf("invalid url: %s", err.Error()))
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", OutputTypeParseError(fmt.Sprintf("unsupported url scheme: %q", u.Scheme))
+	}
+	if u.Host == "" {
+		return "", OutputTypeParseError("url missing host")
+	}
+
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawQuery = ""
+	u.Fragment = ""
+
+	return u.String(), nil
+}
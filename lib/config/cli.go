@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
+	"time"
 
 	"github.com/erobsham/reform/lib/log"
 )
@@ -14,6 +15,17 @@ type CliArgs struct {
 	Cmd        string
 	OutputPath string
 	SeqServer  string
+	SeqSpool   string
+	SeqGzip    bool
+	SeqTLS     TLSCfg
+	Terminal   bool
+	TUI        bool
+	TUIHistory int
+	Filter     string
+	Redact     bool
+	OrderDelay time.Duration
+
+	ShutdownTimeout time.Duration
 }
 
 func ParseCmdStr(cmdStr string) (string, []string) {
@@ -74,18 +86,19 @@ func parseCmdArgs(argStr string) []string {
 	return args
 }
 
-func ParseSeqServer(serverInfo string) (string, string) {
-	idx := strings.IndexByte(serverInfo, ';')
-	if idx == -1 {
-		log.Default().Debug("init with seq output stream", slog.String("host", serverInfo))
-		return serverInfo, ""
-	} else {
-		host := serverInfo[:idx]
-		key := serverInfo[idx+1:]
-		log.Default().Debug("init with seq output stream",
-			slog.String("host", host),
-			slog.String("api-key", key),
-		)
-		return host, key
+// ParseSeqServer splits `{url}[;{apikey}]`, where the url can be a bare
+// `{hostname}:{port}` or a full `https://{hostname}:{port}/{prefix}`.
+func ParseSeqServer(serverInfo string) (string, string, error) {
+	serverURL, key, _ := strings.Cut(serverInfo, ";")
+
+	serverURL, err := NormalizeSeqURL(serverURL)
+	if err != nil {
+		return "", "", err
 	}
+
+	log.Default().Debug("init with seq output stream",
+		slog.String("url", serverURL),
+		slog.Bool("api-key", key != ""),
+	)
+	return serverURL, key, nil
 }
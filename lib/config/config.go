@@ -3,22 +3,63 @@ package config
 import (
 	"encoding/json"
 	"os"
+
+	"github.com/erobsham/reform/lib/filter"
 )
 
 type Configuration struct {
 	Sources map[string]SourceStreamCfg `json:"sources"`
 	Outputs map[string]OutputStreamCfg `json:"outputs"`
+
+	// lines which don't match are dropped before reaching any output
+	Filter *filter.Expr `json:"filter,omitempty"`
+
+	// checked in order, the first matching route picks which outputs get the line
+	Routes []RouteCfg `json:"routes,omitempty"`
+
+	// collapses repeated lines, see `ParseDedupCfg`
+	Dedup map[string]any `json:"dedup,omitempty"`
+
+	// rate limits / sampling, see `ParseLimitCfg`
+	Limits map[string]any `json:"limits,omitempty"`
+
+	// masks secrets / PII before lines reach the outputs, see `ParseRedactCfg`
+	Redact map[string]any `json:"redact,omitempty"`
+
+	// when set, lines are merged across the sources in timestamp order, each
+	// waiting up to this long for earlier lines from the other sources
+	OrderDelay Duration `json:"order_delay,omitempty"`
 }
 
 // the command + args to run that we'll read the stdout of as an input source.
 type SourceStreamCfg struct {
 	Cmd  string   `json:"cmd"`
 	Args []string `json:"args,omitempty"`
+
+	// only lines from this source which match are kept
+	Filter *filter.Expr `json:"filter,omitempty"`
 }
 
 type OutputStreamCfg struct {
 	OutputType OutputType     `json:"type"`
 	Config     map[string]any `json:"config,omitempty"`
+	Queue      OutputQueueCfg `json:"queue,omitzero"`
+	OnError    OutputErrorCfg `json:"on_error,omitzero"`
+
+	// only lines which match are sent to this output
+	Filter *filter.Expr `json:"filter,omitempty"`
+}
+
+// sends lines which match to a subset of the outputs.  outputs which aren't in
+// any route still get every line.
+type RouteCfg struct {
+	Name string `json:"name"`
+	// a route without a `match` matches everything, ie as the last / default route
+	Match   *filter.Expr `json:"match,omitempty"`
+	Outputs []string     `json:"outputs"`
+
+	// carry on checking the later routes after this one matches
+	Continue bool `json:"continue,omitempty"`
 }
 
 func LoadConfigFrom(path string) (Configuration, error) {
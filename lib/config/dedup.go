@@ -0,0 +1,53 @@
+package config
+
+import (
+	"time"
+)
+
+//#< dedup
+
+const (
+	DefaultDedupWindow     = time.Second * 5
+	DefaultDedupMaxPending = 10000
+)
+
+// collapses identical (host, process, message) lines, set with the top level
+// `"dedup": {...}` config
+type DedupCfg struct {
+	// how long a line is held for, waiting for repeats
+	Window time.Duration
+	// lines held at once, the oldest are let go early past this
+	MaxPending int
+}
+
+func (c DedupCfg) WithDefaults() DedupCfg {
+	if c.Window <= 0 {
+		c.Window = DefaultDedupWindow
+	}
+	if c.MaxPending <= 0 {
+		c.MaxPending = DefaultDedupMaxPending
+	}
+	return c
+}
+
+func ParseDedupCfg(cfg map[string]any) (DedupCfg, error) {
+	dedupCfg := DedupCfg{}
+
+	var err error
+	if dedupCfg.Window, err = cfgDuration(cfg, "window"); err != nil {
+		return DedupCfg{}, err
+	}
+	if dedupCfg.Window < 0 {
+		return DedupCfg{}, OutputTypeParseError("'window' can't be negative")
+	}
+	if dedupCfg.MaxPending, err = cfgInt(cfg, "max_pending"); err != nil {
+		return DedupCfg{}, err
+	}
+	if dedupCfg.MaxPending < 0 {
+		return DedupCfg{}, OutputTypeParseError("'max_pending' can't be negative")
+	}
+
+	return dedupCfg.WithDefaults(), nil
+}
+
+//#> dedup
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseDedupCfg(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     map[string]any
+		want    DedupCfg
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			cfg:  map[string]any{},
+			want: DedupCfg{Window: DefaultDedupWindow, MaxPending: DefaultDedupMaxPending},
+		},
+		{
+			name: "set",
+			cfg:  map[string]any{"window": "30s", "max_pending": float64(500)},
+			want: DedupCfg{Window: time.Second * 30, MaxPending: 500},
+		},
+		{
+			name:    "invalid window",
+			cfg:     map[string]any{"window": "soon"},
+			wantErr: true,
+		},
+		{
+			name:    "negative max_pending",
+			cfg:     map[string]any{"max_pending": float64(-1)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDedupCfg(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseDedupCfg() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDedupCfg() got vs want:\n  %+v\n  %+v", got, tt.want)
+			}
+		})
+	}
+}
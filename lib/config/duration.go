@@ -0,0 +1,28 @@
+package config
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Duration is a `time.Duration` which unmarshals from strings like `"500ms"` or `"1m30s"`.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+
+	v, err := time.ParseDuration(str)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
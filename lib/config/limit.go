@@ -0,0 +1,153 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/erobsham/reform/lib/filter"
+	"github.com/erobsham/reform/lib/types"
+)
+
+//#< limits
+
+const (
+	DefaultLimitSummaryInterval = time.Minute
+	DefaultRateLimitPer         = time.Second
+)
+
+// drops lines from noisy sources before they reach the outputs, set with the
+// top level `"limits": {...}` config
+type LimitCfg struct {
+	Rate []RateLimitCfg
+	// the fraction (0-1) of lines kept at each severity, severities which
+	// aren't listed keep everything
+	Sample map[types.Severity]float64
+
+	// how often a summary of what was dropped is sent to the outputs
+	SummaryInterval time.Duration
+}
+
+// a token bucket, per distinct value of the `By` fields
+type RateLimitCfg struct {
+	Name string
+	// fields (see `types.ParsedLine.Field`) which get their own bucket, ie `["host", "proc"]`
+	By []string
+
+	// lines allowed per `Per`, in bursts of up to `Burst`
+	Rate  float64
+	Per   time.Duration
+	Burst int
+
+	// only lines which match are limited
+	Match *filter.Expr
+}
+
+func (c LimitCfg) WithDefaults() LimitCfg {
+	if c.SummaryInterval <= 0 {
+		c.SummaryInterval = DefaultLimitSummaryInterval
+	}
+	return c
+}
+
+func (c RateLimitCfg) WithDefaults() RateLimitCfg {
+	if c.Per <= 0 {
+		c.Per = DefaultRateLimitPer
+	}
+	if c.Burst <= 0 {
+		c.Burst = max(int(math.Ceil(c.Rate)), 1)
+	}
+	return c
+}
+
+func ParseLimitCfg(cfg map[string]any) (LimitCfg, error) {
+	limitCfg := LimitCfg{}
+
+	rates, err := cfgMapSlice(cfg, "rate")
+	if err != nil {
+		return LimitCfg{}, err
+	}
+	// the names key the summary's counts, so they can't be shared
+	names := map[string]bool{}
+	for i, rate := range rates {
+		rateCfg, err := parseRateLimitCfg(rate)
+		if err != nil {
+			return LimitCfg{}, err
+		}
+		if rateCfg.Name == "" {
+			rateCfg.Name = "rate-" + strconv.Itoa(i)
+		}
+		if names[rateCfg.Name] {
+			return LimitCfg{}, OutputTypeParseError(fmt.Sprintf("duplicate rate limit name: %q", rateCfg.Name))
+		}
+		names[rateCfg.Name] = true
+		limitCfg.Rate = append(limitCfg.Rate, rateCfg)
+	}
+
+	sample, err := cfgMap(cfg, "sample")
+	if err != nil {
+		return LimitCfg{}, err
+	}
+	if len(sample) > 0 {
+		limitCfg.Sample = map[types.Severity]float64{}
+	}
+	for level := range sample {
+		severity, known := types.LevelSeverity(level)
+		if !known {
+			return LimitCfg{}, OutputTypeParseError(fmt.Sprintf("unknown level in 'sample': %q", level))
+		}
+		keep, err := cfgFloat(sample, level)
+		if err != nil {
+			return LimitCfg{}, err
+		}
+		if keep < 0 || keep > 1 {
+			return LimitCfg{}, OutputTypeParseError(fmt.Sprintf("'sample.%s' must be between 0 and 1", level))
+		}
+		limitCfg.Sample[severity] = keep
+	}
+
+	if limitCfg.SummaryInterval, err = cfgDuration(cfg, "summary_interval"); err != nil {
+		return LimitCfg{}, err
+	}
+
+	return limitCfg.WithDefaults(), nil
+}
+
+func parseRateLimitCfg(cfg map[string]any) (RateLimitCfg, error) {
+	rateCfg := RateLimitCfg{}
+
+	var err error
+	if rateCfg.Name, err = cfgString(cfg, "name"); err != nil {
+		return RateLimitCfg{}, err
+	}
+	if rateCfg.By, err = cfgStringSlice(cfg, "by"); err != nil {
+		return RateLimitCfg{}, err
+	}
+	if rateCfg.Rate, err = cfgFloat(cfg, "rate"); err != nil {
+		return RateLimitCfg{}, err
+	}
+	if rateCfg.Rate <= 0 {
+		return RateLimitCfg{}, OutputTypeParseError("'rate' must be more than 0")
+	}
+	if rateCfg.Per, err = cfgDuration(cfg, "per"); err != nil {
+		return RateLimitCfg{}, err
+	}
+	if rateCfg.Burst, err = cfgInt(cfg, "burst"); err != nil {
+		return RateLimitCfg{}, err
+	}
+
+	match, err := cfgString(cfg, "match")
+	if err != nil {
+		return RateLimitCfg{}, err
+	}
+	if match != "" {
+		if rateCfg.Match, err = filter.Parse(match); err != nil {
+			return RateLimitCfg{}, OutputTypeParseError(err.Error())
+		}
+	}
+
+	return rateCfg.WithDefaults(), nil
+}
+
+//#> limits
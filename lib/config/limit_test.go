@@ -0,0 +1,94 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/erobsham/reform/lib/filter"
+	"github.com/erobsham/reform/lib/types"
+)
+
+func TestParseLimitCfg(t *testing.T) {
+	match, _ := filter.Parse("level < warn")
+
+	tests := []struct {
+		name    string
+		cfg     map[string]any
+		want    LimitCfg
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			cfg:  map[string]any{},
+			want: LimitCfg{SummaryInterval: DefaultLimitSummaryInterval},
+		},
+		{
+			name: "rates and sampling",
+			cfg: map[string]any{
+				"rate": []any{
+					map[string]any{"name": "per-proc", "by": []any{"host", "proc"}, "rate": float64(50), "burst": float64(200), "match": "level < warn"},
+					map[string]any{"rate": 2.5, "per": "1m"},
+				},
+				"sample":           map[string]any{"debug": 0.1, "info": float64(1)},
+				"summary_interval": "30s",
+			},
+			want: LimitCfg{
+				Rate: []RateLimitCfg{
+					{Name: "per-proc", By: []string{"host", "proc"}, Rate: 50, Per: time.Second, Burst: 200, Match: match},
+					{Name: "rate-1", Rate: 2.5, Per: time.Minute, Burst: 3},
+				},
+				Sample:          map[types.Severity]float64{types.Severity_Debug: 0.1, types.Severity_Info: 1},
+				SummaryInterval: time.Second * 30,
+			},
+		},
+		{
+			name:    "no rate",
+			cfg:     map[string]any{"rate": []any{map[string]any{"by": []any{"host"}}}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid match",
+			cfg:     map[string]any{"rate": []any{map[string]any{"rate": float64(1), "match": "level = warn"}}},
+			wantErr: true,
+		},
+		{
+			name: "duplicate name",
+			cfg: map[string]any{"rate": []any{
+				map[string]any{"name": "per-host", "rate": float64(1)},
+				map[string]any{"name": "per-host", "rate": float64(2)},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "duplicate generated name",
+			cfg: map[string]any{"rate": []any{
+				map[string]any{"name": "rate-1", "rate": float64(1)},
+				map[string]any{"rate": float64(2)},
+			}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown level",
+			cfg:     map[string]any{"sample": map[string]any{"verbose": 0.5}},
+			wantErr: true,
+		},
+		{
+			name:    "sample out of range",
+			cfg:     map[string]any{"sample": map[string]any{"debug": float64(10)}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLimitCfg(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseLimitCfg() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLimitCfg() got vs want:\n  %+v\n  %+v", got, tt.want)
+			}
+		})
+	}
+}
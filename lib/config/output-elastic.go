@@ -0,0 +1,81 @@
+package config
+
+import (
+	"regexp"
+	"strings"
+)
+
+//#< output_type -- elasticsearch
+
+const DefaultElasticIndex = "reform-%Y.%m.%d"
+
+var datePlaceholders = regexp.MustCompile(`%.`)
+
+type OutputElasticCfg struct {
+	HTTPOutputCfg
+
+	// index to write to, with optional date placeholders (ie `logs-%Y.%m.%d`)
+	// filled in from each line's timestamp.
+	Index string
+
+	Username string
+	Password string
+	// sent as `Authorization: ApiKey {key}`
+	APIKey string
+
+	// map lines onto Elastic Common Schema fields, rather than sending them as CLEF
+	ECS bool
+}
+
+func (c OutputElasticCfg) WithDefaults() OutputElasticCfg {
+	c.HTTPOutputCfg = c.HTTPOutputCfg.WithDefaults()
+	if c.Index == "" {
+		c.Index = DefaultElasticIndex
+	}
+	return c
+}
+
+func ParseOutputElasticCfg(cfg map[string]any) (OutputElasticCfg, error) {
+	httpCfg, err := parseHTTPOutputCfg(cfg)
+	if err != nil {
+		return OutputElasticCfg{}, err
+	}
+
+	esCfg := OutputElasticCfg{HTTPOutputCfg: httpCfg}
+	if esCfg.Index, err = cfgString(cfg, "index"); err != nil {
+		return OutputElasticCfg{}, err
+	}
+	// ignoring the date placeholders, which are upper case
+	if plain := datePlaceholders.ReplaceAllString(esCfg.Index, ""); plain != strings.ToLower(plain) {
+		return OutputElasticCfg{}, OutputTypeParseError("'index' must be lowercase")
+	}
+	if esCfg.Username, err = cfgString(cfg, "username"); err != nil {
+		return OutputElasticCfg{}, err
+	}
+	if esCfg.Password, err = cfgSecret(cfg, "password"); err != nil {
+		return OutputElasticCfg{}, err
+	}
+	if esCfg.APIKey, err = cfgSecret(cfg, "api_key"); err != nil {
+		return OutputElasticCfg{}, err
+	}
+	if esCfg.APIKey != "" && esCfg.Username != "" {
+		return OutputElasticCfg{}, OutputTypeParseError("only one of 'api_key' or 'username' can be set")
+	}
+	if esCfg.ECS, err = cfgBool(cfg, "ecs"); err != nil {
+		return OutputElasticCfg{}, err
+	}
+
+	// documents have to be json, so `format` is just another way of setting `ecs`
+	format, err := parseOutputFormatCfg(cfg, "format", OutputFormat_CLEF, OutputFormat_ECSJSON)
+	if err != nil {
+		return OutputElasticCfg{}, err
+	}
+	if format.Format == OutputFormat_CLEF && esCfg.ECS {
+		return OutputElasticCfg{}, OutputTypeParseError("'ecs' can't be used with the 'clef' format")
+	}
+	esCfg.ECS = esCfg.ECS || format.Format == OutputFormat_ECSJSON
+
+	return esCfg, nil
+}
+
+//#> output_type -- elasticsearch
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOutputElasticCfg(t *testing.T) {
+	t.Setenv("REFORM_TEST_ES_KEY", "key-from-env")
+
+	tests := []struct {
+		name    string
+		cfg     map[string]any
+		want    OutputElasticCfg
+		wantErr bool
+	}{
+		{
+			name: "api key",
+			cfg: map[string]any{
+				"url":         "https://search.internal:9200",
+				"index":       "logs-%Y.%m.%d",
+				"api_key_env": "REFORM_TEST_ES_KEY",
+				"ecs":         true,
+			},
+			want: OutputElasticCfg{
+				HTTPOutputCfg: HTTPOutputCfg{URL: "https://search.internal:9200"},
+				Index:         "logs-%Y.%m.%d",
+				APIKey:        "key-from-env",
+				ECS:           true,
+			},
+		},
+		{
+			name: "basic auth",
+			cfg:  map[string]any{"url": "localhost:9200", "username": "reform", "password": "secret"},
+			want: OutputElasticCfg{
+				HTTPOutputCfg: HTTPOutputCfg{URL: "http://localhost:9200"},
+				Username:      "reform",
+				Password:      "secret",
+			},
+		},
+		{
+			name:    "api key and username",
+			cfg:     map[string]any{"url": "localhost:9200", "username": "reform", "api_key": "key"},
+			wantErr: true,
+		},
+		{
+			name:    "uppercase index",
+			cfg:     map[string]any{"url": "localhost:9200", "index": "Logs"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOutputElasticCfg(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseOutputElasticCfg() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOutputElasticCfg() got vs want:\n  %+v\n  %+v", got, tt.want)
+			}
+		})
+	}
+}
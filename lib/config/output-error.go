@@ -0,0 +1,94 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+//#< output error handling
+
+const (
+	DefaultOutputMaxRetries = 3
+	DefaultOutputBackoff    = time.Millisecond * 500
+	DefaultOutputMaxBackoff = time.Second * 30
+)
+
+type OutputErrorCfg struct {
+	Policy ErrorPolicy `json:"policy,omitempty"`
+
+	// only used with the `retry` policy
+	MaxRetries int      `json:"max_retries,omitempty"`
+	Backoff    Duration `json:"backoff,omitempty"`
+	MaxBackoff Duration `json:"max_backoff,omitempty"`
+
+	// lines which couldn't be delivered are appended here (if set)
+	DeadLetterPath string `json:"dead_letter,omitempty"`
+}
+
+func (c OutputErrorCfg) WithDefaults() OutputErrorCfg {
+	if c.MaxRetries <= 0 {
+		c.MaxRetries = DefaultOutputMaxRetries
+	}
+	if c.Backoff <= 0 {
+		c.Backoff = Duration(DefaultOutputBackoff)
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = Duration(DefaultOutputMaxBackoff)
+	}
+	return c
+}
+
+//#> output error handling
+
+//#< error policy
+
+const (
+	ErrorPolicyKey_Degrade  = "degrade"
+	ErrorPolicyKey_Retry    = "retry"
+	ErrorPolicyKey_FailFast = "fail-fast"
+)
+
+const (
+	// mark the output as degraded, and move on to the next line (the zero value / default)
+	ErrorPolicy_Degrade ErrorPolicy = iota
+	// retry with backoff, then degrade
+	ErrorPolicy_Retry
+	// shut everything down on the first error
+	ErrorPolicy_FailFast
+)
+
+type ErrorPolicy uint8
+
+func (p *ErrorPolicy) UnmarshalJSON(d []byte) error {
+	var str string
+	if err := json.Unmarshal(d, &str); err != nil {
+		return err
+	}
+
+	switch str {
+	case ErrorPolicyKey_Degrade:
+		*p = ErrorPolicy_Degrade
+	case ErrorPolicyKey_Retry:
+		*p = ErrorPolicy_Retry
+	case ErrorPolicyKey_FailFast:
+		*p = ErrorPolicy_FailFast
+	default:
+		return fmt.Errorf("unknown ErrorPolicy: %q", str)
+	}
+
+	return nil
+}
+
+func (p ErrorPolicy) String() string {
+	switch p {
+	case ErrorPolicy_Retry:
+		return ErrorPolicyKey_Retry
+	case ErrorPolicy_FailFast:
+		return ErrorPolicyKey_FailFast
+	default:
+		return ErrorPolicyKey_Degrade
+	}
+}
+
+//#> error policy
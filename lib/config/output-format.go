@@ -0,0 +1,218 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"text/template"
+)
+
+//#< output_format
+
+const DefaultTextTemplate = `{{.Timestamp.Format "2006-01-02T15:04:05.000Z07:00"}} {{.LogLevel}} {{.Host}} {{.Process.Name}}: {{.Message}}`
+
+const (
+	OutputFormatKey_CLEF    = "clef"
+	OutputFormatKey_ECSJSON = "ecs-json"
+	OutputFormatKey_Logfmt  = "logfmt"
+	OutputFormatKey_Text    = "text"
+)
+
+const (
+	// the output's own format, ie CLEF for files, or the plain message for Loki
+	OutputFormat_Default OutputFormat = iota
+	OutputFormat_CLEF
+	OutputFormat_ECSJSON
+	OutputFormat_Logfmt
+	OutputFormat_Text
+)
+
+type OutputFormat uint8
+
+func ParseOutputFormat(str string) (OutputFormat, error) {
+	switch str {
+	case OutputFormatKey_CLEF:
+		return OutputFormat_CLEF, nil
+	case OutputFormatKey_ECSJSON:
+		return OutputFormat_ECSJSON, nil
+	case OutputFormatKey_Logfmt:
+		return OutputFormat_Logfmt, nil
+	case OutputFormatKey_Text:
+		return OutputFormat_Text, nil
+	default:
+		return OutputFormat_Default, fmt.Errorf("unknown OutputFormat: %q", str)
+	}
+}
+
+func (f *OutputFormat) UnmarshalJSON(d []byte) error {
+	var str string
+	if err := json.Unmarshal(d, &str); err != nil {
+		return err
+	}
+
+	v, err := ParseOutputFormat(str)
+	if err != nil {
+		return err
+	}
+	*f = v
+	return nil
+}
+
+func (f OutputFormat) String() string {
+	switch f {
+	case OutputFormat_CLEF:
+		return OutputFormatKey_CLEF
+	case OutputFormat_ECSJSON:
+		return OutputFormatKey_ECSJSON
+	case OutputFormat_Logfmt:
+		return OutputFormatKey_Logfmt
+	case OutputFormat_Text:
+		return OutputFormatKey_Text
+	default:
+		return "default"
+	}
+}
+
+// how an output renders each line, set with the `format` and `template` keys
+type OutputFormatCfg struct {
+	Format OutputFormat
+	// a `text/template` executed with each `types.ParsedLine`, only used by the `text` format
+	Template string
+}
+
+// parses the `key` (usually `format`) / `template` keys, limited to the
+// `supported` formats when given
+func parseOutputFormatCfg(cfg map[string]any, key string, supported ...OutputFormat) (OutputFormatCfg, error) {
+	str, err := cfgString(cfg, key)
+	if err != nil {
+		return OutputFormatCfg{}, err
+	}
+	tmpl, err := cfgString(cfg, "template")
+	if err != nil {
+		return OutputFormatCfg{}, err
+	}
+
+	formatCfg := OutputFormatCfg{}
+	if str != "" {
+		if formatCfg.Format, err = ParseOutputFormat(str); err != nil {
+			return OutputFormatCfg{}, OutputTypeParseError(err.Error())
+		}
+		if len(supported) > 0 && !slices.Contains(supported, formatCfg.Format) {
+			return OutputFormatCfg{}, OutputTypeParseError(fmt.Sprintf("'%s' %q isn't supported by this output", key, str))
+		}
+	}
+
+	if formatCfg.Format != OutputFormat_Text {
+		if tmpl != "" {
+			return OutputFormatCfg{}, OutputTypeParseError(fmt.Sprintf("'template' is only used with the '%s' format", OutputFormatKey_Text))
+		}
+		return formatCfg, nil
+	}
+
+	formatCfg.Template = tmpl
+	if formatCfg.Template == "" {
+		formatCfg.Template = DefaultTextTemplate
+	}
+	// catch syntax errors up front
+	if _, err := template.New("format").Parse(formatCfg.Template); err != nil {
+		return OutputFormatCfg{}, OutputTypeParseError(fmt.Sprintf("invalid 'template': %v", err))
+	}
+	return formatCfg, nil
+}
+
+//#> output_format
+
+//#< output_type -- stdout
+
+type OutputStdoutCfg struct {
+	// defaults to a short, fixed width summary of each line
+	Format OutputFormatCfg
+
+	// colorized columns sized to the terminal, rather than `Format`
+	Terminal bool
+	Color    ColorMode
+	// wrap long messages onto extra lines, instead of truncating them
+	Wrap bool
+	// 0 uses the terminal's width (or doesn't truncate, when stdout isn't a terminal)
+	Width int
+}
+
+func ParseOutputStdoutCfg(cfg map[string]any) (OutputStdoutCfg, error) {
+	format, err := parseOutputFormatCfg(cfg, "format")
+	if err != nil {
+		return OutputStdoutCfg{}, err
+	}
+
+	stdoutCfg := OutputStdoutCfg{Format: format}
+	if stdoutCfg.Terminal, err = cfgBool(cfg, "terminal"); err != nil {
+		return OutputStdoutCfg{}, err
+	}
+	if stdoutCfg.Terminal && format.Format != OutputFormat_Default {
+		return OutputStdoutCfg{}, OutputTypeParseError("'terminal' can't be used with 'format'")
+	}
+
+	color, err := cfgString(cfg, "color")
+	if err != nil {
+		return OutputStdoutCfg{}, err
+	}
+	if color != "" {
+		if stdoutCfg.Color, err = ParseColorMode(color); err != nil {
+			return OutputStdoutCfg{}, OutputTypeParseError(err.Error())
+		}
+	}
+	if stdoutCfg.Wrap, err = cfgBool(cfg, "wrap"); err != nil {
+		return OutputStdoutCfg{}, err
+	}
+	if stdoutCfg.Width, err = cfgInt(cfg, "width"); err != nil {
+		return OutputStdoutCfg{}, err
+	}
+	if stdoutCfg.Width < 0 {
+		return OutputStdoutCfg{}, OutputTypeParseError("'width' can't be negative")
+	}
+
+	return stdoutCfg, nil
+}
+
+const (
+	ColorModeKey_Auto   = "auto"
+	ColorModeKey_Always = "always"
+	ColorModeKey_Never  = "never"
+)
+
+const (
+	// color when stdout is a terminal, and `NO_COLOR` isn't set
+	ColorMode_Auto ColorMode = iota
+	ColorMode_Always
+	ColorMode_Never
+)
+
+type ColorMode uint8
+
+func ParseColorMode(str string) (ColorMode, error) {
+	switch str {
+	case ColorModeKey_Auto:
+		return ColorMode_Auto, nil
+	case ColorModeKey_Always:
+		return ColorMode_Always, nil
+	case ColorModeKey_Never:
+		return ColorMode_Never, nil
+	default:
+		return ColorMode_Auto, fmt.Errorf("unknown ColorMode: %q", str)
+	}
+}
+
+func (c *ColorMode) UnmarshalJSON(d []byte) error {
+	var str string
+	if err := json.Unmarshal(d, &str); err != nil {
+		return err
+	}
+
+	v, err := ParseColorMode(str)
+	if err != nil {
+		return err
+	}
+	*c = v
+	return nil
+}
+
+//#> output_type -- stdout
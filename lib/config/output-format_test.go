@@ -0,0 +1,128 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseOutputFormatCfg(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       map[string]any
+		supported []OutputFormat
+		want      OutputFormatCfg
+		wantErr   bool
+	}{
+		{
+			name: "default",
+			cfg:  map[string]any{},
+			want: OutputFormatCfg{},
+		},
+		{
+			name: "logfmt",
+			cfg:  map[string]any{"format": "logfmt"},
+			want: OutputFormatCfg{Format: OutputFormat_Logfmt},
+		},
+		{
+			name: "text",
+			cfg:  map[string]any{"format": "text", "template": "{{.Host}} {{.Message}}"},
+			want: OutputFormatCfg{Format: OutputFormat_Text, Template: "{{.Host}} {{.Message}}"},
+		},
+		{
+			name: "text with default template",
+			cfg:  map[string]any{"format": "text"},
+			want: OutputFormatCfg{Format: OutputFormat_Text, Template: DefaultTextTemplate},
+		},
+		{
+			name:      "supported",
+			cfg:       map[string]any{"format": "ecs-json"},
+			supported: []OutputFormat{OutputFormat_CLEF, OutputFormat_ECSJSON},
+			want:      OutputFormatCfg{Format: OutputFormat_ECSJSON},
+		},
+		{
+			name:      "unsupported",
+			cfg:       map[string]any{"format": "logfmt"},
+			supported: []OutputFormat{OutputFormat_CLEF},
+			wantErr:   true,
+		},
+		{
+			name:    "unknown format",
+			cfg:     map[string]any{"format": "xml"},
+			wantErr: true,
+		},
+		{
+			name:    "template without text",
+			cfg:     map[string]any{"format": "clef", "template": "{{.Message}}"},
+			wantErr: true,
+		},
+		{
+			name:    "bad template",
+			cfg:     map[string]any{"format": "text", "template": "{{.Message"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseOutputFormatCfg(tt.cfg, "format", tt.supported...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseOutputFormatCfg() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseOutputFormatCfg() got vs want:\n  %+v\n  %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOutputStdoutCfg(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     map[string]any
+		want    OutputStdoutCfg
+		wantErr bool
+	}{
+		{
+			name: "no config",
+			cfg:  nil,
+			want: OutputStdoutCfg{},
+		},
+		{
+			name: "format",
+			cfg:  map[string]any{"format": "clef"},
+			want: OutputStdoutCfg{Format: OutputFormatCfg{Format: OutputFormat_CLEF}},
+		},
+		{
+			name: "terminal",
+			cfg:  map[string]any{"terminal": true, "color": "always", "wrap": true, "width": float64(100)},
+			want: OutputStdoutCfg{Terminal: true, Color: ColorMode_Always, Wrap: true, Width: 100},
+		},
+		{
+			name:    "terminal with a format",
+			cfg:     map[string]any{"terminal": true, "format": "logfmt"},
+			wantErr: true,
+		},
+		{
+			name:    "bad color",
+			cfg:     map[string]any{"terminal": true, "color": "rainbow"},
+			wantErr: true,
+		},
+		{
+			name:    "negative width",
+			cfg:     map[string]any{"terminal": true, "width": float64(-1)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOutputStdoutCfg(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseOutputStdoutCfg() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOutputStdoutCfg() got vs want:\n  %+v\n  %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+//#< output_type -- gelf
+
+const (
+	// fits in a single packet on most WANs, per the GELF docs
+	DefaultGELFChunkSize = 1420
+	// headers take up 12 bytes of each chunk
+	MinGELFChunkSize = 64
+)
+
+type OutputGELFCfg struct {
+	NetOutputCfg
+
+	// only used over UDP, TCP frames can't be compressed
+	Compression GELFCompression
+	// max UDP datagram size, larger messages are split into chunks
+	ChunkSize int
+}
+
+func (c OutputGELFCfg) WithDefaults() OutputGELFCfg {
+	c.NetOutputCfg = c.NetOutputCfg.WithDefaults()
+	if c.ChunkSize <= 0 {
+		c.ChunkSize = DefaultGELFChunkSize
+	}
+	return c
+}
+
+func ParseOutputGELFCfg(cfg map[string]any) (OutputGELFCfg, error) {
+	netCfg, err := parseNetOutputCfg(cfg)
+	if err != nil {
+		return OutputGELFCfg{}, err
+	}
+
+	gelfCfg := OutputGELFCfg{NetOutputCfg: netCfg}
+
+	compression, err := cfgString(cfg, "compression")
+	if err != nil {
+		return OutputGELFCfg{}, err
+	}
+	if compression != "" {
+		if gelfCfg.Compression, err = ParseGELFCompression(compression); err != nil {
+			return OutputGELFCfg{}, OutputTypeParseError(err.Error())
+		}
+	}
+	if gelfCfg.Compression != GELFCompression_None && gelfCfg.Protocol != NetProtocol_UDP {
+		return OutputGELFCfg{}, OutputTypeParseError("'compression' is only supported over udp")
+	}
+
+	if gelfCfg.ChunkSize, err = cfgInt(cfg, "chunk_size"); err != nil {
+		return OutputGELFCfg{}, err
+	}
+	if gelfCfg.ChunkSize != 0 && gelfCfg.ChunkSize < MinGELFChunkSize {
+		return OutputGELFCfg{}, OutputTypeParseError(fmt.Sprintf("'chunk_size' must be at least %d", MinGELFChunkSize))
+	}
+	// GELF messages are structured, so there's nothing to format
+	if _, err = parseOutputFormatCfg(cfg, "format", OutputFormat_Default); err != nil {
+		return OutputGELFCfg{}, err
+	}
+
+	return gelfCfg, nil
+}
+
+const (
+	GELFCompressionKey_None = "none"
+	GELFCompressionKey_Gzip = "gzip"
+	GELFCompressionKey_Zlib = "zlib"
+)
+
+const (
+	GELFCompression_None GELFCompression = iota
+	GELFCompression_Gzip
+	GELFCompression_Zlib
+)
+
+type GELFCompression uint8
+
+func ParseGELFCompression(str string) (GELFCompression, error) {
+	switch str {
+	case GELFCompressionKey_None:
+		return GELFCompression_None, nil
+	case GELFCompressionKey_Gzip:
+		return GELFCompression_Gzip, nil
+	case GELFCompressionKey_Zlib:
+		return GELFCompression_Zlib, nil
+	default:
+		return GELFCompression_None, fmt.Errorf("unknown GELFCompression: %q", str)
+	}
+}
+
+func (c *GELFCompression) UnmarshalJSON(d []byte) error {
+	var str string
+	if err := json.Unmarshal(d, &str); err != nil {
+		return err
+	}
+
+	v, err := ParseGELFCompression(str)
+	if err != nil {
+		return err
+	}
+	*c = v
+	return nil
+}
+
+//#> output_type -- gelf
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseOutputGELFCfg(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     map[string]any
+		want    OutputGELFCfg
+		wantErr bool
+	}{
+		{
+			name: "udp",
+			cfg: map[string]any{
+				"address":     "graylog.internal:12201",
+				"compression": "gzip",
+				"chunk_size":  float64(8154),
+			},
+			want: OutputGELFCfg{
+				NetOutputCfg: NetOutputCfg{Address: "graylog.internal:12201"},
+				Compression:  GELFCompression_Gzip,
+				ChunkSize:    8154,
+			},
+		},
+		{
+			name: "tls",
+			cfg: map[string]any{
+				"address":  "graylog.internal:12201",
+				"protocol": "tls",
+				"timeout":  "1s",
+				"tls":      map[string]any{"ca_file": "/etc/reform/ca.pem"},
+			},
+			want: OutputGELFCfg{
+				NetOutputCfg: NetOutputCfg{
+					Address:  "graylog.internal:12201",
+					Protocol: NetProtocol_TLS,
+					Timeout:  time.Second,
+					TLS:      TLSCfg{CAFile: "/etc/reform/ca.pem"},
+				},
+			},
+		},
+		{
+			name:    "compressed tcp",
+			cfg:     map[string]any{"address": "graylog.internal:12201", "protocol": "tcp", "compression": "zlib"},
+			wantErr: true,
+		},
+		{
+			name:    "missing port",
+			cfg:     map[string]any{"address": "graylog.internal"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown protocol",
+			cfg:     map[string]any{"address": "graylog.internal:12201", "protocol": "http"},
+			wantErr: true,
+		},
+		{
+			name:    "tiny chunks",
+			cfg:     map[string]any{"address": "graylog.internal:12201", "chunk_size": float64(12)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOutputGELFCfg(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseOutputGELFCfg() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOutputGELFCfg() got vs want:\n  %+v\n  %+v", got, tt.want)
+			}
+		})
+	}
+}
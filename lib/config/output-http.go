@@ -0,0 +1,108 @@
+package config
+
+import (
+	"time"
+)
+
+//#< http output settings
+
+const (
+	DefaultHTTPMaxBatchEvents = 1000
+	DefaultHTTPBatchWait      = time.Second
+	DefaultHTTPMaxRetries     = 5
+	DefaultHTTPBackoff        = time.Millisecond * 500
+	DefaultHTTPMaxBackoff     = time.Second * 30
+	DefaultHTTPTimeout        = time.Second * 10
+)
+
+// settings shared by the outputs which batch up lines and POST them to a server.
+type HTTPOutputCfg struct {
+	URL     string
+	TLS     TLSCfg
+	Timeout time.Duration
+
+	// extra headers to send with every request
+	Headers map[string]string
+
+	// a batch is sent once it has `MaxBatchEvents` lines, or `BatchWait`
+	// after its first line -- whichever comes first.
+	MaxBatchEvents int
+	BatchWait      time.Duration
+
+	// a negative `MaxRetries` disables retrying
+	MaxRetries int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+func (c HTTPOutputCfg) WithDefaults() HTTPOutputCfg {
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultHTTPTimeout
+	}
+	if c.MaxBatchEvents <= 0 {
+		c.MaxBatchEvents = DefaultHTTPMaxBatchEvents
+	}
+	if c.BatchWait <= 0 {
+		c.BatchWait = DefaultHTTPBatchWait
+	}
+	if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	} else if c.MaxRetries == 0 {
+		c.MaxRetries = DefaultHTTPMaxRetries
+	}
+	if c.Backoff <= 0 {
+		c.Backoff = DefaultHTTPBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = DefaultHTTPMaxBackoff
+	}
+	return c
+}
+
+func parseHTTPOutputCfg(cfg map[string]any) (HTTPOutputCfg, error) {
+	rawURL, err := cfgString(cfg, "url")
+	if err != nil {
+		return HTTPOutputCfg{}, err
+	}
+	if rawURL == "" {
+		return HTTPOutputCfg{}, OutputTypeParseError("missing required 'url' key")
+	}
+
+	httpCfg := HTTPOutputCfg{}
+	if httpCfg.URL, err = normalizeBaseURL(rawURL); err != nil {
+		return HTTPOutputCfg{}, err
+	}
+	if httpCfg.Timeout, err = cfgDuration(cfg, "timeout"); err != nil {
+		return HTTPOutputCfg{}, err
+	}
+	if httpCfg.Headers, err = cfgStringMap(cfg, "headers"); err != nil {
+		return HTTPOutputCfg{}, err
+	}
+	if httpCfg.MaxBatchEvents, err = cfgInt(cfg, "max_batch_events"); err != nil {
+		return HTTPOutputCfg{}, err
+	}
+	if httpCfg.BatchWait, err = cfgDuration(cfg, "batch_wait"); err != nil {
+		return HTTPOutputCfg{}, err
+	}
+	if httpCfg.MaxRetries, err = cfgInt(cfg, "max_retries"); err != nil {
+		return HTTPOutputCfg{}, err
+	}
+	if httpCfg.Backoff, err = cfgDuration(cfg, "backoff"); err != nil {
+		return HTTPOutputCfg{}, err
+	}
+	if httpCfg.MaxBackoff, err = cfgDuration(cfg, "max_backoff"); err != nil {
+		return HTTPOutputCfg{}, err
+	}
+
+	tlsMap, err := cfgMap(cfg, "tls")
+	if err != nil {
+		return HTTPOutputCfg{}, err
+	}
+	if httpCfg.TLS, err = ParseTLSCfg(tlsMap); err != nil {
+		return HTTPOutputCfg{}, err
+	}
+
+	return httpCfg, nil
+}
+
+//#> http output settings
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/erobsham/reform/lib/types"
+)
+
+//#< output_type -- loki
+
+var (
+	DefaultLokiLabels       = []string{types.Field_Host, types.Field_Source}
+	DefaultLokiStaticLabels = map[string]string{"job": "reform"}
+)
+
+type OutputLokiCfg struct {
+	HTTPOutputCfg
+
+	// sent as `X-Scope-OrgID` for multi-tenant setups
+	TenantID string
+	Username string
+	Password string
+
+	Encoding LokiEncoding
+
+	// `ParsedLine` fields to use as stream labels (ie `host`, `proc`, `level`, `source`)
+	Labels       []string
+	StaticLabels map[string]string
+
+	// send the fields which aren't labels as structured metadata (needs Loki 3.x)
+	DisableStructuredMetadata bool
+
+	// how the log line is written, defaults to the plain message
+	Format OutputFormatCfg
+}
+
+func (c OutputLokiCfg) WithDefaults() OutputLokiCfg {
+	c.HTTPOutputCfg = c.HTTPOutputCfg.WithDefaults()
+	if c.Labels == nil {
+		c.Labels = DefaultLokiLabels
+	}
+	if c.StaticLabels == nil {
+		c.StaticLabels = DefaultLokiStaticLabels
+	}
+	return c
+}
+
+func ParseOutputLokiCfg(cfg map[string]any) (OutputLokiCfg, error) {
+	httpCfg, err := parseHTTPOutputCfg(cfg)
+	if err != nil {
+		return OutputLokiCfg{}, err
+	}
+
+	lokiCfg := OutputLokiCfg{HTTPOutputCfg: httpCfg}
+	if lokiCfg.TenantID, err = cfgString(cfg, "tenant_id"); err != nil {
+		return OutputLokiCfg{}, err
+	}
+	if lokiCfg.Username, err = cfgString(cfg, "username"); err != nil {
+		return OutputLokiCfg{}, err
+	}
+	if lokiCfg.Password, err = cfgSecret(cfg, "password"); err != nil {
+		return OutputLokiCfg{}, err
+	}
+
+	encoding, err := cfgString(cfg, "encoding")
+	if err != nil {
+		return OutputLokiCfg{}, err
+	}
+	if encoding != "" {
+		if lokiCfg.Encoding, err = ParseLokiEncoding(encoding); err != nil {
+			return OutputLokiCfg{}, OutputTypeParseError(err.Error())
+		}
+	}
+
+	if lokiCfg.Labels, err = cfgStringSlice(cfg, "labels"); err != nil {
+		return OutputLokiCfg{}, err
+	}
+	for _, label := range lokiCfg.Labels {
+		if !slices.Contains(types.FixedFields, label) || label == types.Field_Message {
+			return OutputLokiCfg{}, OutputTypeParseError(fmt.Sprintf("unsupported loki label field: %q", label))
+		}
+	}
+	if lokiCfg.StaticLabels, err = cfgStringMap(cfg, "static_labels"); err != nil {
+		return OutputLokiCfg{}, err
+	}
+
+	structuredMetadata := true
+	if _, exists := cfg["structured_metadata"]; exists {
+		if structuredMetadata, err = cfgBool(cfg, "structured_metadata"); err != nil {
+			return OutputLokiCfg{}, err
+		}
+	}
+	lokiCfg.DisableStructuredMetadata = !structuredMetadata
+
+	if lokiCfg.Format, err = parseOutputFormatCfg(cfg, "format"); err != nil {
+		return OutputLokiCfg{}, err
+	}
+
+	return lokiCfg, nil
+}
+
+const (
+	LokiEncodingKey_Protobuf = "protobuf"
+	LokiEncodingKey_JSON     = "json"
+)
+
+const (
+	// snappy compressed protobuf (the zero value / default)
+	LokiEncoding_Protobuf LokiEncoding = iota
+	LokiEncoding_JSON
+)
+
+type LokiEncoding uint8
+
+func ParseLokiEncoding(str string) (LokiEncoding, error) {
+	switch str {
+	case LokiEncodingKey_Protobuf:
+		return LokiEncoding_Protobuf, nil
+	case LokiEncodingKey_JSON:
+		return LokiEncoding_JSON, nil
+	default:
+		return LokiEncoding_Protobuf, fmt.Errorf("unknown LokiEncoding: %q", str)
+	}
+}
+
+func (e *LokiEncoding) UnmarshalJSON(d []byte) error {
+	var str string
+	if err := json.Unmarshal(d, &str); err != nil {
+		return err
+	}
+
+	v, err := ParseLokiEncoding(str)
+	if err != nil {
+		return err
+	}
+	*e = v
+	return nil
+}
+
+//#> output_type -- loki
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseOutputLokiCfg(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     map[string]any
+		want    OutputLokiCfg
+		wantErr bool
+	}{
+		{
+			name: "all settings",
+			cfg: map[string]any{
+				"url":                 "https://loki.internal:3100/",
+				"tenant_id":           "lab",
+				"username":            "reform",
+				"password":            "secret",
+				"encoding":            "json",
+				"labels":              []any{"host", "level"},
+				"static_labels":       map[string]any{"env": "lab"},
+				"structured_metadata": false,
+				"headers":             map[string]any{"X-Extra": "1"},
+				"batch_wait":          "250ms",
+				"max_batch_events":    float64(100),
+			},
+			want: OutputLokiCfg{
+				HTTPOutputCfg: HTTPOutputCfg{
+					URL:            "https://loki.internal:3100",
+					Headers:        map[string]string{"X-Extra": "1"},
+					BatchWait:      time.Millisecond * 250,
+					MaxBatchEvents: 100,
+				},
+				TenantID:                  "lab",
+				Username:                  "reform",
+				Password:                  "secret",
+				Encoding:                  LokiEncoding_JSON,
+				Labels:                    []string{"host", "level"},
+				StaticLabels:              map[string]string{"env": "lab"},
+				DisableStructuredMetadata: true,
+			},
+		},
+		{
+			name: "defaults",
+			cfg:  map[string]any{"url": "localhost:3100"},
+			want: OutputLokiCfg{
+				HTTPOutputCfg: HTTPOutputCfg{URL: "http://localhost:3100"},
+			},
+		},
+		{
+			name:    "unknown encoding",
+			cfg:     map[string]any{"url": "localhost:3100", "encoding": "xml"},
+			wantErr: true,
+		},
+		{
+			name:    "message as a label",
+			cfg:     map[string]any{"url": "localhost:3100", "labels": []any{"msg"}},
+			wantErr: true,
+		},
+		{
+			name:    "missing url",
+			cfg:     map[string]any{},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOutputLokiCfg(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseOutputLokiCfg() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOutputLokiCfg() got vs want:\n  %+v\n  %+v", got, tt.want)
+			}
+		})
+	}
+}
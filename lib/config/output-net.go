@@ -0,0 +1,125 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+)
+
+//#< network output settings
+
+const DefaultNetTimeout = time.Second * 5
+
+// settings shared by the outputs which write straight to a socket.
+type NetOutputCfg struct {
+	// `{host}:{port}`
+	Address  string
+	Protocol NetProtocol
+
+	// only used with `NetProtocol_TLS`
+	TLS TLSCfg
+
+	// for connecting + each write
+	Timeout time.Duration
+}
+
+func (c NetOutputCfg) WithDefaults() NetOutputCfg {
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultNetTimeout
+	}
+	return c
+}
+
+func parseNetOutputCfg(cfg map[string]any) (NetOutputCfg, error) {
+	netCfg := NetOutputCfg{}
+
+	var err error
+	if netCfg.Address, err = cfgString(cfg, "address"); err != nil {
+		return NetOutputCfg{}, err
+	}
+	if netCfg.Address == "" {
+		return NetOutputCfg{}, OutputTypeParseError("missing required 'address' key")
+	}
+	if _, _, err = net.SplitHostPort(netCfg.Address); err != nil {
+		return NetOutputCfg{}, OutputTypeParseError(fmt.Sprintf("invalid 'address': %s", err.Error()))
+	}
+
+	protocol, err := cfgString(cfg, "protocol")
+	if err != nil {
+		return NetOutputCfg{}, err
+	}
+	if protocol != "" {
+		if netCfg.Protocol, err = ParseNetProtocol(protocol); err != nil {
+			return NetOutputCfg{}, OutputTypeParseError(err.Error())
+		}
+	}
+
+	if netCfg.Timeout, err = cfgDuration(cfg, "timeout"); err != nil {
+		return NetOutputCfg{}, err
+	}
+
+	tlsMap, err := cfgMap(cfg, "tls")
+	if err != nil {
+		return NetOutputCfg{}, err
+	}
+	if netCfg.TLS, err = ParseTLSCfg(tlsMap); err != nil {
+		return NetOutputCfg{}, err
+	}
+
+	return netCfg, nil
+}
+
+const (
+	NetProtocolKey_UDP = "udp"
+	NetProtocolKey_TCP = "tcp"
+	NetProtocolKey_TLS = "tls"
+)
+
+const (
+	NetProtocol_UDP NetProtocol = iota
+	NetProtocol_TCP
+	NetProtocol_TLS
+)
+
+type NetProtocol uint8
+
+func ParseNetProtocol(str string) (NetProtocol, error) {
+	switch str {
+	case NetProtocolKey_UDP:
+		return NetProtocol_UDP, nil
+	case NetProtocolKey_TCP:
+		return NetProtocol_TCP, nil
+	case NetProtocolKey_TLS:
+		return NetProtocol_TLS, nil
+	default:
+		return NetProtocol_UDP, fmt.Errorf("unknown NetProtocol: %q", str)
+	}
+}
+
+func (p *NetProtocol) UnmarshalJSON(d []byte) error {
+	var str string
+	if err := json.Unmarshal(d, &str); err != nil {
+		return err
+	}
+
+	v, err := ParseNetProtocol(str)
+	if err != nil {
+		return err
+	}
+	*p = v
+	return nil
+}
+
+func (p NetProtocol) String() string {
+	switch p {
+	case NetProtocol_TCP:
+		return NetProtocolKey_TCP
+	case NetProtocol_TLS:
+		return NetProtocolKey_TLS
+	default:
+		return NetProtocolKey_UDP
+	}
+}
+
+//#> network output settings
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+//#< output_type -- otlp
+
+type OutputOTLPCfg struct {
+	HTTPOutputCfg
+
+	Encoding OTLPEncoding
+	Gzip     bool
+
+	// extra attributes to add to every resource (ie `deployment.environment`),
+	// on top of the per-source `host.name` / `service.name`
+	ResourceAttributes map[string]string
+}
+
+func (c OutputOTLPCfg) WithDefaults() OutputOTLPCfg {
+	c.HTTPOutputCfg = c.HTTPOutputCfg.WithDefaults()
+	return c
+}
+
+func ParseOutputOTLPCfg(cfg map[string]any) (OutputOTLPCfg, error) {
+	httpCfg, err := parseHTTPOutputCfg(cfg)
+	if err != nil {
+		return OutputOTLPCfg{}, err
+	}
+
+	otlpCfg := OutputOTLPCfg{HTTPOutputCfg: httpCfg}
+	encoding, err := cfgString(cfg, "encoding")
+	if err != nil {
+		return OutputOTLPCfg{}, err
+	}
+	if encoding != "" {
+		if otlpCfg.Encoding, err = ParseOTLPEncoding(encoding); err != nil {
+			return OutputOTLPCfg{}, OutputTypeParseError(err.Error())
+		}
+	}
+	if otlpCfg.Gzip, err = cfgBool(cfg, "gzip"); err != nil {
+		return OutputOTLPCfg{}, err
+	}
+	if otlpCfg.ResourceAttributes, err = cfgStringMap(cfg, "resource_attributes"); err != nil {
+		return OutputOTLPCfg{}, err
+	}
+	// log records are structured, so there's nothing to format
+	if _, err = parseOutputFormatCfg(cfg, "format", OutputFormat_Default); err != nil {
+		return OutputOTLPCfg{}, err
+	}
+
+	return otlpCfg, nil
+}
+
+const (
+	OTLPEncodingKey_Protobuf = "protobuf"
+	OTLPEncodingKey_JSON     = "json"
+)
+
+const (
+	OTLPEncoding_Protobuf OTLPEncoding = iota
+	OTLPEncoding_JSON
+)
+
+type OTLPEncoding uint8
+
+func ParseOTLPEncoding(str string) (OTLPEncoding, error) {
+	switch str {
+	case OTLPEncodingKey_Protobuf:
+		return OTLPEncoding_Protobuf, nil
+	case OTLPEncodingKey_JSON:
+		return OTLPEncoding_JSON, nil
+	default:
+		return OTLPEncoding_Protobuf, fmt.Errorf("unknown OTLPEncoding: %q", str)
+	}
+}
+
+func (e *OTLPEncoding) UnmarshalJSON(d []byte) error {
+	var str string
+	if err := json.Unmarshal(d, &str); err != nil {
+		return err
+	}
+
+	v, err := ParseOTLPEncoding(str)
+	if err != nil {
+		return err
+	}
+	*e = v
+	return nil
+}
+
+//#> output_type -- otlp
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOutputOTLPCfg(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     map[string]any
+		want    OutputOTLPCfg
+		wantErr bool
+	}{
+		{
+			name: "all settings",
+			cfg: map[string]any{
+				"url":                 "https://otel.internal:4318",
+				"encoding":            "json",
+				"gzip":                true,
+				"resource_attributes": map[string]any{"deployment.environment": "lab"},
+				"headers":             map[string]any{"Authorization": "Bearer token"},
+			},
+			want: OutputOTLPCfg{
+				HTTPOutputCfg: HTTPOutputCfg{
+					URL:     "https://otel.internal:4318",
+					Headers: map[string]string{"Authorization": "Bearer token"},
+				},
+				Encoding:           OTLPEncoding_JSON,
+				Gzip:               true,
+				ResourceAttributes: map[string]string{"deployment.environment": "lab"},
+			},
+		},
+		{
+			name: "defaults",
+			cfg:  map[string]any{"url": "localhost:4318"},
+			want: OutputOTLPCfg{HTTPOutputCfg: HTTPOutputCfg{URL: "http://localhost:4318"}},
+		},
+		{
+			name:    "unknown encoding",
+			cfg:     map[string]any{"url": "localhost:4318", "encoding": "grpc"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOutputOTLPCfg(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseOutputOTLPCfg() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOutputOTLPCfg() got vs want:\n  %+v\n  %+v", got, tt.want)
+			}
+		})
+	}
+}
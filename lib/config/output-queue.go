@@ -0,0 +1,84 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+//#< output queue
+
+const DefaultOutputQueueSize = 1024
+
+type OutputQueueCfg struct {
+	// max number of lines buffered in memory for the output
+	Size     int            `json:"size,omitempty"`
+	Overflow OverflowPolicy `json:"overflow,omitempty"`
+
+	// where to spill lines to for `spill-to-disk` -- defaults to a new file in the tmp dir
+	SpillPath string `json:"spill_path,omitempty"`
+}
+
+func (c OutputQueueCfg) WithDefaults() OutputQueueCfg {
+	if c.Size <= 0 {
+		c.Size = DefaultOutputQueueSize
+	}
+	return c
+}
+
+//#> output queue
+
+//#< overflow policy
+
+const (
+	OverflowPolicyKey_Block       = "block"
+	OverflowPolicyKey_DropOldest  = "drop-oldest"
+	OverflowPolicyKey_DropNewest  = "drop-newest"
+	OverflowPolicyKey_SpillToDisk = "spill-to-disk"
+)
+
+const (
+	// wait for room in the queue (the zero value / default)
+	OverflowPolicy_Block OverflowPolicy = iota
+	OverflowPolicy_DropOldest
+	OverflowPolicy_DropNewest
+	OverflowPolicy_SpillToDisk
+)
+
+type OverflowPolicy uint8
+
+func (p *OverflowPolicy) UnmarshalJSON(d []byte) error {
+	var str string
+	if err := json.Unmarshal(d, &str); err != nil {
+		return err
+	}
+
+	switch str {
+	case OverflowPolicyKey_Block:
+		*p = OverflowPolicy_Block
+	case OverflowPolicyKey_DropOldest:
+		*p = OverflowPolicy_DropOldest
+	case OverflowPolicyKey_DropNewest:
+		*p = OverflowPolicy_DropNewest
+	case OverflowPolicyKey_SpillToDisk:
+		*p = OverflowPolicy_SpillToDisk
+	default:
+		return fmt.Errorf("unknown OverflowPolicy: %q", str)
+	}
+
+	return nil
+}
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowPolicy_DropOldest:
+		return OverflowPolicyKey_DropOldest
+	case OverflowPolicy_DropNewest:
+		return OverflowPolicyKey_DropNewest
+	case OverflowPolicy_SpillToDisk:
+		return OverflowPolicyKey_SpillToDisk
+	default:
+		return OverflowPolicyKey_Block
+	}
+}
+
+//#> overflow policy
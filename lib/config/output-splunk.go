@@ -0,0 +1,81 @@
+package config
+
+import (
+	"time"
+)
+
+//#< output_type -- splunk
+
+const (
+	DefaultSplunkAckTimeout = time.Minute * 2
+)
+
+type OutputSplunkCfg struct {
+	HTTPOutputCfg
+
+	// HEC token, sent as `Authorization: Splunk {token}`
+	Token string
+
+	// event metadata, `Source` defaults to the name of the line's input
+	Index      string
+	SourceType string
+	Source     string
+
+	// wait for indexer acknowledgement, resending batches which aren't
+	// acknowledged within `AckTimeout`. needs `useACK` enabled on the token.
+	UseAck     bool
+	AckTimeout time.Duration
+	// HEC channel id (a GUID), one is generated if not set
+	Channel string
+
+	// events are sent as CLEF json objects by default, `logfmt` / `text` are sent as strings
+	Format OutputFormatCfg
+}
+
+func (c OutputSplunkCfg) WithDefaults() OutputSplunkCfg {
+	c.HTTPOutputCfg = c.HTTPOutputCfg.WithDefaults()
+	if c.AckTimeout <= 0 {
+		c.AckTimeout = DefaultSplunkAckTimeout
+	}
+	return c
+}
+
+func ParseOutputSplunkCfg(cfg map[string]any) (OutputSplunkCfg, error) {
+	httpCfg, err := parseHTTPOutputCfg(cfg)
+	if err != nil {
+		return OutputSplunkCfg{}, err
+	}
+
+	splunkCfg := OutputSplunkCfg{HTTPOutputCfg: httpCfg}
+	if splunkCfg.Token, err = cfgSecret(cfg, "token"); err != nil {
+		return OutputSplunkCfg{}, err
+	}
+	if splunkCfg.Token == "" {
+		return OutputSplunkCfg{}, OutputTypeParseError("missing required 'token' key")
+	}
+	if splunkCfg.Index, err = cfgString(cfg, "index"); err != nil {
+		return OutputSplunkCfg{}, err
+	}
+	if splunkCfg.SourceType, err = cfgString(cfg, "sourcetype"); err != nil {
+		return OutputSplunkCfg{}, err
+	}
+	if splunkCfg.Source, err = cfgString(cfg, "source"); err != nil {
+		return OutputSplunkCfg{}, err
+	}
+	if splunkCfg.UseAck, err = cfgBool(cfg, "ack"); err != nil {
+		return OutputSplunkCfg{}, err
+	}
+	if splunkCfg.AckTimeout, err = cfgDuration(cfg, "ack_timeout"); err != nil {
+		return OutputSplunkCfg{}, err
+	}
+	if splunkCfg.Channel, err = cfgString(cfg, "channel"); err != nil {
+		return OutputSplunkCfg{}, err
+	}
+	if splunkCfg.Format, err = parseOutputFormatCfg(cfg, "format"); err != nil {
+		return OutputSplunkCfg{}, err
+	}
+
+	return splunkCfg, nil
+}
+
+//#> output_type -- splunk
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseOutputSplunkCfg(t *testing.T) {
+	t.Setenv("REFORM_TEST_HEC_TOKEN", "token-from-env")
+
+	tests := []struct {
+		name    string
+		cfg     map[string]any
+		want    OutputSplunkCfg
+		wantErr bool
+	}{
+		{
+			name: "all settings",
+			cfg: map[string]any{
+				"url":         "https://splunk.internal:8088",
+				"token_env":   "REFORM_TEST_HEC_TOKEN",
+				"index":       "security",
+				"sourcetype":  "reform:json",
+				"source":      "reform",
+				"ack":         true,
+				"ack_timeout": "30s",
+			},
+			want: OutputSplunkCfg{
+				HTTPOutputCfg: HTTPOutputCfg{URL: "https://splunk.internal:8088"},
+				Token:         "token-from-env",
+				Index:         "security",
+				SourceType:    "reform:json",
+				Source:        "reform",
+				UseAck:        true,
+				AckTimeout:    time.Second * 30,
+			},
+		},
+		{
+			name:    "missing token",
+			cfg:     map[string]any{"url": "https://splunk.internal:8088"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOutputSplunkCfg(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseOutputSplunkCfg() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOutputSplunkCfg() got vs want:\n  %+v\n  %+v", got, tt.want)
+			}
+		})
+	}
+}
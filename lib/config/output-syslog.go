@@ -0,0 +1,267 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+//#< output_type -- syslog
+
+// the IANA example enterprise number, used unless there's a real one to use
+const DefaultSyslogSDID = "reform@32473"
+
+type OutputSyslogCfg struct {
+	NetOutputCfg
+
+	Format SyslogFormat
+	// how messages are delimited over tcp / tls, defaults to octet counting
+	// for rfc5424 and newlines for rfc3164
+	Framing SyslogFraming
+
+	// `kern` is the zero value, `ParseOutputSyslogCfg` defaults to `user`
+	Facility SyslogFacility
+
+	// used when the line doesn't have a process name
+	AppName string
+
+	// SD-ID for the structured data element holding the extra fields (rfc5424 only)
+	SDID string
+
+	// how the MSG part is written, defaults to the plain message.  set with
+	// `message_format`, as `format` picks the syslog flavour.
+	MessageFormat OutputFormatCfg
+}
+
+func (c OutputSyslogCfg) WithDefaults() OutputSyslogCfg {
+	c.NetOutputCfg = c.NetOutputCfg.WithDefaults()
+	if c.Framing == SyslogFraming_Default {
+		if c.Format == SyslogFormat_RFC3164 {
+			c.Framing = SyslogFraming_Newline
+		} else {
+			c.Framing = SyslogFraming_OctetCounting
+		}
+	}
+	if c.AppName == "" {
+		c.AppName = "reform"
+	}
+	if c.SDID == "" {
+		c.SDID = DefaultSyslogSDID
+	}
+	return c
+}
+
+func ParseOutputSyslogCfg(cfg map[string]any) (OutputSyslogCfg, error) {
+	netCfg, err := parseNetOutputCfg(cfg)
+	if err != nil {
+		return OutputSyslogCfg{}, err
+	}
+
+	syslogCfg := OutputSyslogCfg{NetOutputCfg: netCfg, Facility: SyslogFacility_User}
+
+	format, err := cfgString(cfg, "format")
+	if err != nil {
+		return OutputSyslogCfg{}, err
+	}
+	if format != "" {
+		if syslogCfg.Format, err = ParseSyslogFormat(format); err != nil {
+			return OutputSyslogCfg{}, OutputTypeParseError(err.Error())
+		}
+	}
+
+	framing, err := cfgString(cfg, "framing")
+	if err != nil {
+		return OutputSyslogCfg{}, err
+	}
+	if framing != "" {
+		if syslogCfg.Framing, err = ParseSyslogFraming(framing); err != nil {
+			return OutputSyslogCfg{}, OutputTypeParseError(err.Error())
+		}
+	}
+
+	facility, err := cfgString(cfg, "facility")
+	if err != nil {
+		return OutputSyslogCfg{}, err
+	}
+	if facility != "" {
+		if syslogCfg.Facility, err = ParseSyslogFacility(facility); err != nil {
+			return OutputSyslogCfg{}, OutputTypeParseError(err.Error())
+		}
+	}
+
+	if syslogCfg.AppName, err = cfgString(cfg, "app_name"); err != nil {
+		return OutputSyslogCfg{}, err
+	}
+	if syslogCfg.SDID, err = cfgString(cfg, "sd_id"); err != nil {
+		return OutputSyslogCfg{}, err
+	}
+	if strings.ContainsAny(syslogCfg.SDID, ` ="]`) {
+		return OutputSyslogCfg{}, OutputTypeParseError(`'sd_id' can't contain ' ', '=', '"', or ']'`)
+	}
+	if syslogCfg.MessageFormat, err = parseOutputFormatCfg(cfg, "message_format"); err != nil {
+		return OutputSyslogCfg{}, err
+	}
+
+	return syslogCfg, nil
+}
+
+//#> output_type -- syslog
+
+//#< output_type -- syslog format
+
+const (
+	SyslogFormatKey_RFC5424 = "rfc5424"
+	SyslogFormatKey_RFC3164 = "rfc3164"
+)
+
+const (
+	SyslogFormat_RFC5424 SyslogFormat = iota
+	SyslogFormat_RFC3164
+)
+
+type SyslogFormat uint8
+
+func ParseSyslogFormat(str string) (SyslogFormat, error) {
+	switch str {
+	case SyslogFormatKey_RFC5424:
+		return SyslogFormat_RFC5424, nil
+	case SyslogFormatKey_RFC3164:
+		return SyslogFormat_RFC3164, nil
+	default:
+		return SyslogFormat_RFC5424, fmt.Errorf("unknown SyslogFormat: %q", str)
+	}
+}
+
+func (f *SyslogFormat) UnmarshalJSON(d []byte) error {
+	var str string
+	if err := json.Unmarshal(d, &str); err != nil {
+		return err
+	}
+
+	v, err := ParseSyslogFormat(str)
+	if err != nil {
+		return err
+	}
+	*f = v
+	return nil
+}
+
+//#> output_type -- syslog format
+
+//#< output_type -- syslog framing
+
+const (
+	SyslogFramingKey_OctetCounting = "octet-counting"
+	SyslogFramingKey_Newline       = "newline"
+)
+
+const (
+	SyslogFraming_Default SyslogFraming = iota
+	// `{len} {msg}` (RFC 6587)
+	SyslogFraming_OctetCounting
+	// `{msg}\n`
+	SyslogFraming_Newline
+)
+
+type SyslogFraming uint8
+
+func ParseSyslogFraming(str string) (SyslogFraming, error) {
+	switch str {
+	case SyslogFramingKey_OctetCounting:
+		return SyslogFraming_OctetCounting, nil
+	case SyslogFramingKey_Newline:
+		return SyslogFraming_Newline, nil
+	default:
+		return SyslogFraming_Default, fmt.Errorf("unknown SyslogFraming: %q", str)
+	}
+}
+
+func (f *SyslogFraming) UnmarshalJSON(d []byte) error {
+	var str string
+	if err := json.Unmarshal(d, &str); err != nil {
+		return err
+	}
+
+	v, err := ParseSyslogFraming(str)
+	if err != nil {
+		return err
+	}
+	*f = v
+	return nil
+}
+
+//#> output_type -- syslog framing
+
+//#< output_type -- syslog facility
+
+const (
+	SyslogFacility_Kern SyslogFacility = iota
+	SyslogFacility_User
+	SyslogFacility_Mail
+	SyslogFacility_Daemon
+	SyslogFacility_Auth
+	SyslogFacility_Syslog
+	SyslogFacility_LPR
+	SyslogFacility_News
+	SyslogFacility_UUCP
+	SyslogFacility_Cron
+	SyslogFacility_AuthPriv
+	SyslogFacility_FTP
+	SyslogFacility_Local0 SyslogFacility = iota + 4
+	SyslogFacility_Local1
+	SyslogFacility_Local2
+	SyslogFacility_Local3
+	SyslogFacility_Local4
+	SyslogFacility_Local5
+	SyslogFacility_Local6
+	SyslogFacility_Local7
+)
+
+var syslogFacilityNames = map[string]SyslogFacility{
+	"kern":     SyslogFacility_Kern,
+	"user":     SyslogFacility_User,
+	"mail":     SyslogFacility_Mail,
+	"daemon":   SyslogFacility_Daemon,
+	"auth":     SyslogFacility_Auth,
+	"syslog":   SyslogFacility_Syslog,
+	"lpr":      SyslogFacility_LPR,
+	"news":     SyslogFacility_News,
+	"uucp":     SyslogFacility_UUCP,
+	"cron":     SyslogFacility_Cron,
+	"authpriv": SyslogFacility_AuthPriv,
+	"ftp":      SyslogFacility_FTP,
+	"local0":   SyslogFacility_Local0,
+	"local1":   SyslogFacility_Local1,
+	"local2":   SyslogFacility_Local2,
+	"local3":   SyslogFacility_Local3,
+	"local4":   SyslogFacility_Local4,
+	"local5":   SyslogFacility_Local5,
+	"local6":   SyslogFacility_Local6,
+	"local7":   SyslogFacility_Local7,
+}
+
+type SyslogFacility uint8
+
+func ParseSyslogFacility(str string) (SyslogFacility, error) {
+	facility, ok := syslogFacilityNames[strings.ToLower(str)]
+	if !ok {
+		return SyslogFacility_User, fmt.Errorf("unknown SyslogFacility: %q", str)
+	}
+	return facility, nil
+}
+
+func (f *SyslogFacility) UnmarshalJSON(d []byte) error {
+	var str string
+	if err := json.Unmarshal(d, &str); err != nil {
+		return err
+	}
+
+	v, err := ParseSyslogFacility(str)
+	if err != nil {
+		return err
+	}
+	*f = v
+	return nil
+}
+
+//#> output_type -- syslog facility
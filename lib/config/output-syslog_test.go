@@ -0,0 +1,66 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseOutputSyslogCfg(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     map[string]any
+		want    OutputSyslogCfg
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			cfg:  map[string]any{"address": "rsyslog.internal:514"},
+			want: OutputSyslogCfg{
+				NetOutputCfg: NetOutputCfg{Address: "rsyslog.internal:514"},
+				Facility:     SyslogFacility_User,
+			},
+		},
+		{
+			name: "all settings",
+			cfg: map[string]any{
+				"address":  "rsyslog.internal:6514",
+				"protocol": "tls",
+				"format":   "rfc3164",
+				"framing":  "octet-counting",
+				"facility": "local3",
+				"app_name": "relay",
+				"sd_id":    "meta@12345",
+			},
+			want: OutputSyslogCfg{
+				NetOutputCfg: NetOutputCfg{Address: "rsyslog.internal:6514", Protocol: NetProtocol_TLS},
+				Format:       SyslogFormat_RFC3164,
+				Framing:      SyslogFraming_OctetCounting,
+				Facility:     SyslogFacility_Local3,
+				AppName:      "relay",
+				SDID:         "meta@12345",
+			},
+		},
+		{
+			name:    "unknown facility",
+			cfg:     map[string]any{"address": "rsyslog.internal:514", "facility": "local9"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid sd_id",
+			cfg:     map[string]any{"address": "rsyslog.internal:514", "sd_id": "my meta"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOutputSyslogCfg(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseOutputSyslogCfg() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOutputSyslogCfg() got vs want:\n  %+v\n  %+v", got, tt.want)
+			}
+		})
+	}
+}
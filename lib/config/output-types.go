@@ -3,6 +3,7 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 )
 
 //#< output_type
@@ -10,12 +11,29 @@ import (
 const (
 	OutputTypeKey_Stdout = "stdout"
 	OutputTypeKey_File   = "file"
+	OutputTypeKey_Seq    = "seq"
+	OutputTypeKey_Loki   = "loki"
+
+	OutputTypeKey_Elasticsearch = "elasticsearch"
+	OutputTypeKey_OpenSearch    = "opensearch"
+	OutputTypeKey_OTLP          = "otlp"
+	OutputTypeKey_GELF          = "gelf"
+	OutputTypeKey_Splunk        = "splunk"
+	OutputTypeKey_Syslog        = "syslog"
 )
 
 const (
 	OutputType_None OutputType = iota
 	OutputType_Stdout
 	OutputType_File
+	OutputType_Seq
+	OutputType_Loki
+	// also used for OpenSearch, which has the same `_bulk` api
+	OutputType_Elasticsearch
+	OutputType_OTLP
+	OutputType_GELF
+	OutputType_Splunk
+	OutputType_Syslog
 )
 
 type OutputType uint8
@@ -31,6 +49,20 @@ func (o *OutputType) UnmarshalJSON(d []byte) error {
 		*o = OutputType_Stdout
 	case OutputTypeKey_File:
 		*o = OutputType_File
+	case OutputTypeKey_Seq:
+		*o = OutputType_Seq
+	case OutputTypeKey_Loki:
+		*o = OutputType_Loki
+	case OutputTypeKey_Elasticsearch, OutputTypeKey_OpenSearch:
+		*o = OutputType_Elasticsearch
+	case OutputTypeKey_OTLP:
+		*o = OutputType_OTLP
+	case OutputTypeKey_GELF:
+		*o = OutputType_GELF
+	case OutputTypeKey_Splunk:
+		*o = OutputType_Splunk
+	case OutputTypeKey_Syslog:
+		*o = OutputType_Syslog
 	default:
 		*o = OutputType_None
 		return fmt.Errorf("unknown OutputType")
@@ -43,8 +75,41 @@ func (o *OutputType) UnmarshalJSON(d []byte) error {
 
 //#< output_type -- file
 
+const (
+	DefaultFileBufferSize    = 64 * 1024
+	DefaultFileFlushInterval = time.Second
+)
+
 type OutputFileCfg struct {
+	// may include date placeholders (ie `logs/%Y-%m-%d.clef`), a new file
+	// is started whenever the formatted path changes
 	Path string
+
+	// rotate once the file would grow past `MaxSize` bytes (0 is unlimited)
+	MaxSize int64
+	// rotate at the start of every hour / day
+	Rotate RotateInterval
+	// number of rotated files to keep (0 keeps them all)
+	MaxBackups int
+	// gzip files once they've been rotated
+	Compress bool
+
+	// writes are buffered, and flushed at least every `FlushInterval`
+	BufferSize    int
+	FlushInterval time.Duration
+
+	// defaults to CLEF
+	Format OutputFormatCfg
+}
+
+func (c OutputFileCfg) WithDefaults() OutputFileCfg {
+	if c.BufferSize <= 0 {
+		c.BufferSize = DefaultFileBufferSize
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = DefaultFileFlushInterval
+	}
+	return c
 }
 
 func ParseOutputFileCfg(cfg map[string]any) (OutputFileCfg, error) {
@@ -52,11 +117,229 @@ func ParseOutputFileCfg(cfg map[string]any) (OutputFileCfg, error) {
 	if !ok {
 		return OutputFileCfg{}, OutputTypeParseError("missing required 'path' key")
 	}
-	return OutputFileCfg{Path: path}, nil
+
+	fileCfg := OutputFileCfg{Path: path}
+
+	var err error
+	if fileCfg.MaxSize, err = cfgByteSize(cfg, "max_size"); err != nil {
+		return OutputFileCfg{}, err
+	}
+
+	rotate, err := cfgString(cfg, "rotate")
+	if err != nil {
+		return OutputFileCfg{}, err
+	}
+	if rotate != "" {
+		if fileCfg.Rotate, err = ParseRotateInterval(rotate); err != nil {
+			return OutputFileCfg{}, OutputTypeParseError(err.Error())
+		}
+	}
+
+	if fileCfg.MaxBackups, err = cfgInt(cfg, "max_backups"); err != nil {
+		return OutputFileCfg{}, err
+	}
+	if fileCfg.MaxBackups < 0 {
+		return OutputFileCfg{}, OutputTypeParseError("'max_backups' can't be negative")
+	}
+	if fileCfg.Compress, err = cfgBool(cfg, "compress"); err != nil {
+		return OutputFileCfg{}, err
+	}
+
+	bufferSize, err := cfgByteSize(cfg, "buffer_size")
+	if err != nil {
+		return OutputFileCfg{}, err
+	}
+	fileCfg.BufferSize = int(bufferSize)
+	if fileCfg.FlushInterval, err = cfgDuration(cfg, "flush_interval"); err != nil {
+		return OutputFileCfg{}, err
+	}
+	if fileCfg.Format, err = parseOutputFormatCfg(cfg, "format"); err != nil {
+		return OutputFileCfg{}, err
+	}
+
+	return fileCfg, nil
+}
+
+const (
+	RotateIntervalKey_None   = "none"
+	RotateIntervalKey_Hourly = "hourly"
+	RotateIntervalKey_Daily  = "daily"
+)
+
+const (
+	RotateInterval_None RotateInterval = iota
+	RotateInterval_Hourly
+	RotateInterval_Daily
+)
+
+type RotateInterval uint8
+
+func ParseRotateInterval(str string) (RotateInterval, error) {
+	switch str {
+	case RotateIntervalKey_None:
+		return RotateInterval_None, nil
+	case RotateIntervalKey_Hourly:
+		return RotateInterval_Hourly, nil
+	case RotateIntervalKey_Daily:
+		return RotateInterval_Daily, nil
+	default:
+		return RotateInterval_None, fmt.Errorf("unknown RotateInterval: %q", str)
+	}
+}
+
+func (r *RotateInterval) UnmarshalJSON(d []byte) error {
+	var str string
+	if err := json.Unmarshal(d, &str); err != nil {
+		return err
+	}
+
+	v, err := ParseRotateInterval(str)
+	if err != nil {
+		return err
+	}
+	*r = v
+	return nil
 }
 
 //#> output_type -- file
 
+//#< output_type -- seq
+
+const (
+	DefaultSeqMaxBatchEvents = 1000
+	DefaultSeqMaxBatchBytes  = 4 * 1024 * 1024
+	DefaultSeqMaxRetries     = 5
+	DefaultSeqBackoff        = time.Millisecond * 500
+	DefaultSeqMaxBackoff     = time.Second * 30
+	DefaultSeqTimeout        = time.Second * 10
+	DefaultSeqMaxSpoolBytes  = 1024 * 1024 * 1024
+)
+
+type OutputSeqCfg struct {
+	// base url of the server, ie `https://seq.internal:5341` | `http://proxy/seq`
+	URL    string
+	APIKey string
+	TLS    TLSCfg
+
+	// gzip the request bodies
+	Gzip bool
+
+	// limits for a single POST to the ingestion endpoint
+	MaxBatchEvents int
+	MaxBatchBytes  int
+
+	// a negative `MaxRetries` disables retrying
+	MaxRetries int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	Timeout    time.Duration
+
+	// batches which can't be delivered are saved here, and re-sent once
+	// the server is reachable again. spooling is disabled when empty.
+	SpoolDir      string
+	MaxSpoolBytes int64
+}
+
+func (c OutputSeqCfg) WithDefaults() OutputSeqCfg {
+	if c.MaxBatchEvents <= 0 {
+		c.MaxBatchEvents = DefaultSeqMaxBatchEvents
+	}
+	if c.MaxBatchBytes <= 0 {
+		c.MaxBatchBytes = DefaultSeqMaxBatchBytes
+	}
+	if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	} else if c.MaxRetries == 0 {
+		c.MaxRetries = DefaultSeqMaxRetries
+	}
+	if c.Backoff <= 0 {
+		c.Backoff = DefaultSeqBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = DefaultSeqMaxBackoff
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultSeqTimeout
+	}
+	if c.MaxSpoolBytes <= 0 {
+		c.MaxSpoolBytes = DefaultSeqMaxSpoolBytes
+	}
+	return c
+}
+
+func ParseOutputSeqCfg(cfg map[string]any) (OutputSeqCfg, error) {
+	rawURL, err := cfgString(cfg, "url")
+	if err != nil {
+		return OutputSeqCfg{}, err
+	}
+	if rawURL == "" {
+		return OutputSeqCfg{}, OutputTypeParseError("missing required 'url' key")
+	}
+
+	seqCfg := OutputSeqCfg{}
+	seqCfg.URL, err = NormalizeSeqURL(rawURL)
+	if err != nil {
+		return OutputSeqCfg{}, err
+	}
+
+	if seqCfg.APIKey, err = cfgSecret(cfg, "api_key"); err != nil {
+		return OutputSeqCfg{}, err
+	}
+
+	if seqCfg.Gzip, err = cfgBool(cfg, "gzip"); err != nil {
+		return OutputSeqCfg{}, err
+	}
+	if seqCfg.MaxBatchEvents, err = cfgInt(cfg, "max_batch_events"); err != nil {
+		return OutputSeqCfg{}, err
+	}
+	maxBatchBytes, err := cfgByteSize(cfg, "max_batch_bytes")
+	if err != nil {
+		return OutputSeqCfg{}, err
+	}
+	seqCfg.MaxBatchBytes = int(maxBatchBytes)
+	if seqCfg.MaxRetries, err = cfgInt(cfg, "max_retries"); err != nil {
+		return OutputSeqCfg{}, err
+	}
+	if seqCfg.Backoff, err = cfgDuration(cfg, "backoff"); err != nil {
+		return OutputSeqCfg{}, err
+	}
+	if seqCfg.MaxBackoff, err = cfgDuration(cfg, "max_backoff"); err != nil {
+		return OutputSeqCfg{}, err
+	}
+	if seqCfg.Timeout, err = cfgDuration(cfg, "timeout"); err != nil {
+		return OutputSeqCfg{}, err
+	}
+
+	if seqCfg.SpoolDir, err = cfgString(cfg, "spool_dir"); err != nil {
+		return OutputSeqCfg{}, err
+	}
+	if seqCfg.MaxSpoolBytes, err = cfgByteSize(cfg, "max_spool_bytes"); err != nil {
+		return OutputSeqCfg{}, err
+	}
+
+	tlsMap, err := cfgMap(cfg, "tls")
+	if err != nil {
+		return OutputSeqCfg{}, err
+	}
+	if seqCfg.TLS, err = ParseTLSCfg(tlsMap); err != nil {
+		return OutputSeqCfg{}, err
+	}
+	// Seq only ingests CLEF
+	if _, err = parseOutputFormatCfg(cfg, "format", OutputFormat_CLEF); err != nil {
+		return OutputSeqCfg{}, err
+	}
+
+	return seqCfg, nil
+}
+
+// NormalizeSeqURL accepts either a full url, or a bare `{hostname}:{port}`
+// (which defaults to `http://`), and returns the base url without a trailing '/'.
+func NormalizeSeqURL(rawURL string) (string, error) {
+	return normalizeBaseURL(rawURL)
+}
+
+//#> output_type -- seq
+
 //#< output_type -- custom errors
 
 const (
@@ -0,0 +1,221 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNormalizeSeqURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		rawURL  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "bare host:port",
+			rawURL: "localhost:5341",
+			want:   "http://localhost:5341",
+		},
+		{
+			name:   "https",
+			rawURL: "https://seq.internal:5341",
+			want:   "https://seq.internal:5341",
+		},
+		{
+			name:   "path prefix",
+			rawURL: "https://proxy.internal/seq/",
+			want:   "https://proxy.internal/seq",
+		},
+		{
+			name:    "unsupported scheme",
+			rawURL:  "ftp://seq.internal",
+			wantErr: true,
+		},
+		{
+			name:    "missing host",
+			rawURL:  "https://",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeSeqURL(tt.rawURL)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NormalizeSeqURL() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("NormalizeSeqURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOutputSeqCfg(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "seq.key")
+	os.WriteFile(keyFile, []byte("key-from-file\n"), 0600)
+	t.Setenv("REFORM_TEST_SEQ_KEY", "key-from-env")
+
+	tests := []struct {
+		name    string
+		cfg     map[string]any
+		want    OutputSeqCfg
+		wantErr bool
+	}{
+		{
+			name: "all settings",
+			cfg: map[string]any{
+				"url":              "seq.internal:5341",
+				"api_key":          "key",
+				"max_batch_events": float64(50),
+				"max_batch_bytes":  float64(1024),
+				"timeout":          "2s",
+				"spool_dir":        "/var/spool/reform",
+				"tls":              map[string]any{"insecure_skip_verify": true},
+			},
+			want: OutputSeqCfg{
+				URL:            "http://seq.internal:5341",
+				APIKey:         "key",
+				MaxBatchEvents: 50,
+				MaxBatchBytes:  1024,
+				Timeout:        time.Second * 2,
+				SpoolDir:       "/var/spool/reform",
+				TLS:            TLSCfg{InsecureSkipVerify: true},
+			},
+		},
+		{
+			name: "byte sizes",
+			cfg:  map[string]any{"url": "https://seq", "max_batch_bytes": "1MiB", "max_spool_bytes": "512MB"},
+			want: OutputSeqCfg{URL: "https://seq", MaxBatchBytes: 1024 * 1024, MaxSpoolBytes: 512 * 1000 * 1000},
+		},
+		{
+			name:    "bad spool size",
+			cfg:     map[string]any{"url": "https://seq", "max_spool_bytes": "lots"},
+			wantErr: true,
+		},
+		{
+			name: "api key from env",
+			cfg:  map[string]any{"url": "https://seq", "api_key_env": "REFORM_TEST_SEQ_KEY"},
+			want: OutputSeqCfg{URL: "https://seq", APIKey: "key-from-env"},
+		},
+		{
+			name: "api key from file",
+			cfg:  map[string]any{"url": "https://seq", "api_key_file": keyFile},
+			want: OutputSeqCfg{URL: "https://seq", APIKey: "key-from-file"},
+		},
+		{
+			name:    "multiple api keys",
+			cfg:     map[string]any{"url": "https://seq", "api_key": "key", "api_key_env": "REFORM_TEST_SEQ_KEY"},
+			wantErr: true,
+		},
+		{
+			name:    "missing url",
+			cfg:     map[string]any{"api_key": "key"},
+			wantErr: true,
+		},
+		{
+			name:    "bad timeout",
+			cfg:     map[string]any{"url": "https://seq", "timeout": float64(5)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOutputSeqCfg(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseOutputSeqCfg() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOutputSeqCfg() got vs want:\n  %+v\n  %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOutputFileCfg(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     map[string]any
+		want    OutputFileCfg
+		wantErr bool
+	}{
+		{
+			name: "path only",
+			cfg:  map[string]any{"path": "test.log"},
+			want: OutputFileCfg{Path: "test.log"},
+		},
+		{
+			name: "all settings",
+			cfg: map[string]any{
+				"path":           "logs/%Y-%m-%d.log",
+				"max_size":       "100MB",
+				"rotate":         "daily",
+				"max_backups":    float64(7),
+				"compress":       true,
+				"buffer_size":    "1MiB",
+				"flush_interval": "5s",
+			},
+			want: OutputFileCfg{
+				Path:          "logs/%Y-%m-%d.log",
+				MaxSize:       100 * 1000 * 1000,
+				Rotate:        RotateInterval_Daily,
+				MaxBackups:    7,
+				Compress:      true,
+				BufferSize:    1024 * 1024,
+				FlushInterval: time.Second * 5,
+			},
+		},
+		{
+			name: "max size in bytes",
+			cfg:  map[string]any{"path": "test.log", "max_size": float64(4096)},
+			want: OutputFileCfg{Path: "test.log", MaxSize: 4096},
+		},
+		{
+			name: "max size in binary units",
+			cfg:  map[string]any{"path": "test.log", "max_size": "1.5 GiB"},
+			want: OutputFileCfg{Path: "test.log", MaxSize: 1536 * 1024 * 1024},
+		},
+		{
+			name:    "missing path",
+			cfg:     map[string]any{"rotate": "hourly"},
+			wantErr: true,
+		},
+		{
+			name:    "bad max size",
+			cfg:     map[string]any{"path": "test.log", "max_size": "lots"},
+			wantErr: true,
+		},
+		{
+			name:    "bad rotate",
+			cfg:     map[string]any{"path": "test.log", "rotate": "weekly"},
+			wantErr: true,
+		},
+		{
+			name:    "bad flush interval",
+			cfg:     map[string]any{"path": "test.log", "flush_interval": "often"},
+			wantErr: true,
+		},
+		{
+			name:    "negative max backups",
+			cfg:     map[string]any{"path": "test.log", "max_backups": float64(-1)},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOutputFileCfg(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseOutputFileCfg() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseOutputFileCfg() got vs want:\n  %+v\n  %+v", got, tt.want)
+			}
+		})
+	}
+}
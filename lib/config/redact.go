@@ -0,0 +1,167 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+//#< redact
+
+const DefaultRedactMask = "[REDACTED]"
+
+// the built-in detectors
+const (
+	RedactDetector_APIKey      = "api_key"
+	RedactDetector_BearerToken = "bearer_token"
+	RedactDetector_URLPassword = "url_password"
+	RedactDetector_Email       = "email"
+	RedactDetector_MAC         = "mac"
+	RedactDetector_IP          = "ip"
+)
+
+// in the order they're applied
+var RedactDetectors = []string{
+	RedactDetector_URLPassword,
+	RedactDetector_BearerToken,
+	RedactDetector_APIKey,
+	RedactDetector_Email,
+	RedactDetector_MAC,
+	RedactDetector_IP,
+}
+
+// property names whose values are always redacted, whatever they hold.  they're
+// compared ignoring case, `-` and `_`, and also match as a suffix (ie
+// `db_password`, `X-Api-Key`)
+var RedactKeys = []string{
+	"password",
+	"passwd",
+	"secret",
+	"token",
+	"authorization",
+	"apikey",
+	"cookie",
+}
+
+// masks secrets / PII in each line's message, properties and raw text, set with
+// the top level `"redact": {...}` config
+type RedactCfg struct {
+	// built-in detectors to use, defaults to all of them
+	Detectors []string
+	// extra regexes by name, the whole match is redacted
+	Rules map[string]string
+	// property names to redact the whole value of, defaults to `RedactKeys`
+	Keys []string
+
+	Mode RedactMode
+	// what `mask` mode replaces matches with
+	Mask string
+	// HMAC key for `hash` mode
+	HashKey string
+}
+
+func (c RedactCfg) WithDefaults() RedactCfg {
+	if c.Detectors == nil {
+		c.Detectors = slices.Clone(RedactDetectors)
+	}
+	if c.Keys == nil {
+		c.Keys = slices.Clone(RedactKeys)
+	}
+	if c.Mask == "" {
+		c.Mask = DefaultRedactMask
+	}
+	return c
+}
+
+func ParseRedactCfg(cfg map[string]any) (RedactCfg, error) {
+	detectors, err := cfgStringSlice(cfg, "detectors")
+	if err != nil {
+		return RedactCfg{}, err
+	}
+	for _, name := range detectors {
+		if !slices.Contains(RedactDetectors, name) {
+			return RedactCfg{}, OutputTypeParseError(fmt.Sprintf("unknown redact detector: %q", name))
+		}
+	}
+
+	rules, err := cfgStringMap(cfg, "rules")
+	if err != nil {
+		return RedactCfg{}, err
+	}
+	for name, pattern := range rules {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return RedactCfg{}, OutputTypeParseError(fmt.Sprintf("invalid regex for rule %q: %v", name, err))
+		}
+	}
+
+	keys, err := cfgStringSlice(cfg, "keys")
+	if err != nil {
+		return RedactCfg{}, err
+	}
+
+	redactCfg := RedactCfg{Detectors: detectors, Rules: rules, Keys: keys}
+
+	mode, err := cfgString(cfg, "mode")
+	if err != nil {
+		return RedactCfg{}, err
+	}
+	if mode != "" {
+		if redactCfg.Mode, err = ParseRedactMode(mode); err != nil {
+			return RedactCfg{}, OutputTypeParseError(err.Error())
+		}
+	}
+
+	if redactCfg.Mask, err = cfgString(cfg, "mask"); err != nil {
+		return RedactCfg{}, err
+	}
+	if redactCfg.HashKey, err = cfgSecret(cfg, "hash_key"); err != nil {
+		return RedactCfg{}, err
+	}
+	if redactCfg.Mode == RedactMode_Hash && redactCfg.HashKey == "" {
+		return RedactCfg{}, OutputTypeParseError("'hash_key' is required for the 'hash' mode")
+	}
+
+	return redactCfg.WithDefaults(), nil
+}
+
+const (
+	RedactModeKey_Mask = "mask"
+	RedactModeKey_Hash = "hash"
+)
+
+const (
+	// replace matches with the `Mask`
+	RedactMode_Mask RedactMode = iota
+	// replace matches with a keyed hash of the value, so the same value still correlates
+	RedactMode_Hash
+)
+
+type RedactMode uint8
+
+func ParseRedactMode(str string) (RedactMode, error) {
+	switch str {
+	case RedactModeKey_Mask:
+		return RedactMode_Mask, nil
+	case RedactModeKey_Hash:
+		return RedactMode_Hash, nil
+	default:
+		return RedactMode_Mask, fmt.Errorf("unknown RedactMode: %q", str)
+	}
+}
+
+func (m *RedactMode) UnmarshalJSON(d []byte) error {
+	var str string
+	if err := json.Unmarshal(d, &str); err != nil {
+		return err
+	}
+
+	v, err := ParseRedactMode(str)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+//#> redact
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRedactCfg(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     map[string]any
+		want    RedactCfg
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			cfg:  map[string]any{},
+			want: RedactCfg{Detectors: RedactDetectors, Keys: RedactKeys, Mask: DefaultRedactMask},
+		},
+		{
+			name: "hashed",
+			cfg: map[string]any{
+				"detectors": []any{"email", "ip"},
+				"rules":     map[string]any{"serial": `SN-\d{8}`},
+				"mode":      "hash",
+				"hash_key":  "s3cret",
+			},
+			want: RedactCfg{
+				Detectors: []string{RedactDetector_Email, RedactDetector_IP},
+				Rules:     map[string]string{"serial": `SN-\d{8}`},
+				Keys:      RedactKeys,
+				Mode:      RedactMode_Hash,
+				Mask:      DefaultRedactMask,
+				HashKey:   "s3cret",
+			},
+		},
+		{
+			name: "only rules",
+			cfg: map[string]any{
+				"detectors": []any{},
+				"rules":     map[string]any{"serial": `SN-\d{8}`},
+				"mask":      "***",
+				"keys":      []any{},
+			},
+			want: RedactCfg{
+				Detectors: []string{},
+				Rules:     map[string]string{"serial": `SN-\d{8}`},
+				Keys:      []string{},
+				Mask:      "***",
+			},
+		},
+		{
+			name: "keys",
+			cfg:  map[string]any{"keys": []any{"pin", "session_id"}},
+			want: RedactCfg{
+				Detectors: RedactDetectors,
+				Keys:      []string{"pin", "session_id"},
+				Mask:      DefaultRedactMask,
+			},
+		},
+		{
+			name:    "unknown detector",
+			cfg:     map[string]any{"detectors": []any{"phone"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid rule",
+			cfg:     map[string]any{"rules": map[string]any{"bad": `(`}},
+			wantErr: true,
+		},
+		{
+			name:    "hash without a key",
+			cfg:     map[string]any{"mode": "hash"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown mode",
+			cfg:     map[string]any{"mode": "shred"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseRedactCfg(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseRedactCfg() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseRedactCfg() got vs want:\n  %+v\n  %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package config
+
+// TLS settings for outputs which talk to a server.
+type TLSCfg struct {
+	// PEM bundle of CAs to trust, in addition to the system roots
+	CAFile string
+
+	// client certificate + key, for servers which require mutual TLS
+	CertFile string
+	KeyFile  string
+
+	// must be explicitly opted into, ie for self-signed certs
+	InsecureSkipVerify bool
+}
+
+func ParseTLSCfg(cfg map[string]any) (TLSCfg, error) {
+	tlsCfg := TLSCfg{}
+	if cfg == nil {
+		return tlsCfg, nil
+	}
+
+	var err error
+	if tlsCfg.CAFile, err = cfgString(cfg, "ca_file"); err != nil {
+		return TLSCfg{}, err
+	}
+	if tlsCfg.CertFile, err = cfgString(cfg, "cert_file"); err != nil {
+		return TLSCfg{}, err
+	}
+	if tlsCfg.KeyFile, err = cfgString(cfg, "key_file"); err != nil {
+		return TLSCfg{}, err
+	}
+	if tlsCfg.InsecureSkipVerify, err = cfgBool(cfg, "insecure_skip_verify"); err != nil {
+		return TLSCfg{}, err
+	}
+
+	if (tlsCfg.CertFile == "") != (tlsCfg.KeyFile == "") {
+		return TLSCfg{}, OutputTypeParseError("'cert_file' and 'key_file' must be set together")
+	}
+
+	return tlsCfg, nil
+}
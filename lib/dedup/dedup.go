@@ -0,0 +1,147 @@
+package dedup
+
+import (
+	"maps"
+	"time"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/types"
+)
+
+//#< Deduper
+
+// the properties added to lines which were repeated
+const (
+	Property_RepeatCount    = "repeat_count"
+	Property_FirstTimestamp = "first_timestamp"
+	Property_LastTimestamp  = "last_timestamp"
+)
+
+// New builds a Deduper which collapses repeats within `cfg.Window`.
+func New(cfg config.DedupCfg) *Deduper {
+	return newDeduper(cfg, time.Now)
+}
+
+func newDeduper(cfg config.DedupCfg, now func() time.Time) *Deduper {
+	cfg = cfg.WithDefaults()
+	return &Deduper{
+		window:     cfg.Window,
+		maxPending: cfg.MaxPending,
+		pending:    map[key]*entry{},
+		now:        now,
+	}
+}
+
+// Deduper holds each line for the window after it's first seen, counting any
+// identical (host, process, message) lines which arrive meanwhile, then lets
+// it go on as a single line.
+type Deduper struct {
+	window     time.Duration
+	maxPending int
+	now        func() time.Time
+
+	pending map[key]*entry
+	// oldest first, which is also the order they expire in
+	order []*entry
+}
+
+// lines are only repeats when they're from the same source and at the same
+// level, ie so an `error` isn't folded into an earlier `info` line
+type key struct {
+	source  string
+	host    string
+	process string
+	level   string
+	message string
+}
+
+type entry struct {
+	key     key
+	line    types.ParsedLine
+	count   uint64
+	last    time.Time
+	expires time.Time
+}
+
+// Add holds `line`, or counts it as a repeat of a line which is already held.
+func (d *Deduper) Add(line types.ParsedLine) {
+	now := d.now()
+	k := key{
+		source:  line.Source,
+		host:    line.Host,
+		process: line.Process.Name,
+		level:   line.LogLevel,
+		message: line.Message,
+	}
+
+	if e, exists := d.pending[k]; exists {
+		e.count += 1
+		e.last = line.Timestamp
+		return
+	}
+
+	e := &entry{
+		key:     k,
+		line:    line,
+		count:   1,
+		last:    line.Timestamp,
+		expires: now.Add(d.window),
+	}
+	d.pending[k] = e
+	d.order = append(d.order, e)
+}
+
+// Flush returns the lines whose window has passed (or all of them when
+// `force`, ie on shutdown), oldest first.  lines which were repeated get the
+// `repeat_count` and first / last timestamp properties.
+func (d *Deduper) Flush(force bool) []types.ParsedLine {
+	if d == nil || len(d.order) == 0 {
+		return nil
+	}
+
+	now := d.now()
+	done := 0
+	for done < len(d.order) {
+		e := d.order[done]
+		// past the max, the oldest go early
+		overflowing := len(d.order)-done > d.maxPending
+		if !force && !overflowing && now.Before(e.expires) {
+			break
+		}
+		done += 1
+	}
+	if done == 0 {
+		return nil
+	}
+
+	lines := make([]types.ParsedLine, 0, done)
+	for _, e := range d.order[:done] {
+		delete(d.pending, e.key)
+		lines = append(lines, e.collapsed())
+	}
+	d.order = append(d.order[:0], d.order[done:]...)
+	return lines
+}
+
+func (e *entry) collapsed() types.ParsedLine {
+	line := e.line
+	if e.count == 1 {
+		return line
+	}
+
+	// copied, the held line's map may be shared with other copies of the line
+	props := make(map[string]any, len(line.Properties)+3)
+	maps.Copy(props, line.Properties)
+	line.Properties = props
+
+	line.Properties[Property_RepeatCount] = e.count
+	if !line.Timestamp.IsZero() {
+		line.Properties[Property_FirstTimestamp] = line.Timestamp.Format(time.RFC3339Nano)
+	}
+	if !e.last.IsZero() {
+		line.Properties[Property_LastTimestamp] = e.last.Format(time.RFC3339Nano)
+	}
+	return line
+}
+
+//#> Deduper
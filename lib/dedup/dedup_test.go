@@ -0,0 +1,154 @@
+package dedup
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/types"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func TestDeduper(t *testing.T) {
+	start := time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)
+	clock := &fakeClock{t: start}
+	d := newDeduper(config.DedupCfg{Window: time.Second * 5}, clock.now)
+
+	reconnect := types.ParsedLine{Host: "hst-1", Process: types.ProcessInfo{Name: "vpn", PID: 1}, Message: "reconnecting"}
+	other := types.ParsedLine{Host: "hst-2", Process: types.ProcessInfo{Name: "vpn", PID: 1}, Message: "reconnecting"}
+
+	for i := range 3 {
+		line := reconnect
+		line.Timestamp = start.Add(time.Second * time.Duration(i))
+		// a new pid is still a repeat
+		line.Process.PID = uint64(i)
+		d.Add(line)
+	}
+	clock.t = start.Add(time.Second * 2)
+	other.Timestamp = clock.t
+	d.Add(other)
+
+	if got := d.Flush(false); len(got) != 0 {
+		t.Errorf("flushed before the window: %+v", got)
+	}
+
+	clock.t = start.Add(time.Second * 5)
+	got := d.Flush(false)
+	want := []types.ParsedLine{{
+		Timestamp: start,
+		Host:      "hst-1",
+		Process:   types.ProcessInfo{Name: "vpn"},
+		Message:   "reconnecting",
+		Properties: map[string]any{
+			Property_RepeatCount:    uint64(3),
+			Property_FirstTimestamp: "2025-03-04T05:06:07Z",
+			Property_LastTimestamp:  "2025-03-04T05:06:09Z",
+		},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Flush() got vs want:\n  %+v\n  %+v", got, want)
+	}
+
+	// a line that wasn't repeated is let go as it was
+	got = d.Flush(true)
+	if !reflect.DeepEqual(got, []types.ParsedLine{other}) {
+		t.Errorf("Flush(true) got vs want:\n  %+v\n  %+v", got, []types.ParsedLine{other})
+	}
+
+	// the window starts again once a line has been let go
+	d.Add(reconnect)
+	if got := d.Flush(true); len(got) != 1 || got[0].Properties != nil {
+		t.Errorf("repeat after the window: %+v", got)
+	}
+}
+
+func TestDeduper_MaxPending(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)}
+	d := newDeduper(config.DedupCfg{Window: time.Minute, MaxPending: 2}, clock.now)
+
+	for _, msg := range []string{"a", "b", "c", "d"} {
+		d.Add(types.ParsedLine{Message: msg})
+	}
+
+	got := []string{}
+	for _, line := range d.Flush(false) {
+		got = append(got, line.Message)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got vs want:\n  %+v\n  %+v", got, want)
+	}
+	if len(d.pending) != 2 || len(d.order) != 2 {
+		t.Errorf("%d pending / %d ordered, want 2", len(d.pending), len(d.order))
+	}
+
+	var nilDeduper *Deduper
+	if got := nilDeduper.Flush(true); got != nil {
+		t.Errorf("a nil Deduper flushed %+v", got)
+	}
+}
+
+func TestDeduper_Key(t *testing.T) {
+	base := types.ParsedLine{
+		Source:   "edge",
+		Host:     "hst-1",
+		Process:  types.ProcessInfo{Name: "vpn"},
+		LogLevel: "info",
+		Message:  "reconnecting",
+	}
+
+	tests := []struct {
+		name   string
+		change func(line *types.ParsedLine)
+		repeat bool
+	}{
+		{"same line", func(line *types.ParsedLine) {}, true},
+		{"other pid", func(line *types.ParsedLine) { line.Process.PID = 7 }, true},
+		{"other source", func(line *types.ParsedLine) { line.Source = "core" }, false},
+		{"other host", func(line *types.ParsedLine) { line.Host = "hst-2" }, false},
+		{"other process", func(line *types.ParsedLine) { line.Process.Name = "sshd" }, false},
+		{"other level", func(line *types.ParsedLine) { line.LogLevel = "err" }, false},
+		{"other message", func(line *types.ParsedLine) { line.Message = "connected" }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &fakeClock{t: time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)}
+			d := newDeduper(config.DedupCfg{Window: time.Minute}, clock.now)
+
+			line := base
+			tt.change(&line)
+			d.Add(base)
+			d.Add(line)
+
+			got := d.Flush(true)
+			if tt.repeat && (len(got) != 1 || got[0].Properties[Property_RepeatCount] != uint64(2)) {
+				t.Errorf("want a single repeated line, got %+v", got)
+			}
+			if !tt.repeat && len(got) != 2 {
+				t.Errorf("want both lines, got %+v", got)
+			}
+		})
+	}
+}
+
+func TestDeduper_KeepsProperties(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)}
+	d := newDeduper(config.DedupCfg{Window: time.Minute}, clock.now)
+
+	props := map[string]any{"user": "bob"}
+	line := types.ParsedLine{Message: "reconnecting", Properties: props}
+	d.Add(line)
+	d.Add(line)
+
+	got := d.Flush(true)
+	if len(got) != 1 || got[0].Properties[Property_RepeatCount] != uint64(2) || got[0].Properties["user"] != "bob" {
+		t.Fatalf("got %+v", got)
+	}
+	// the line's own map (ie shared with another output) is left alone
+	if want := map[string]any{"user": "bob"}; !reflect.DeepEqual(props, want) {
+		t.Errorf("the original properties changed: %+v", props)
+	}
+}
@@ -0,0 +1,181 @@
+package filter
+
+import (
+	"cmp"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/erobsham/reform/lib/types"
+)
+
+//#< Expr
+
+// Expr is a compiled filter expression, matched against each line's fields
+// (see `types.ParsedLine.Field`) and properties.  a nil / empty `Expr` matches
+// every line.
+type Expr struct {
+	src  string
+	root node
+}
+
+func (e *Expr) Match(line types.ParsedLine) bool {
+	if e == nil || e.root == nil {
+		return true
+	}
+	return e.root.match(&line)
+}
+
+func (e *Expr) String() string {
+	if e == nil {
+		return ""
+	}
+	return e.src
+}
+
+func (e *Expr) UnmarshalJSON(d []byte) error {
+	var str string
+	if err := json.Unmarshal(d, &str); err != nil {
+		return err
+	}
+
+	parsed, err := Parse(str)
+	if err != nil {
+		return err
+	}
+	*e = *parsed
+	return nil
+}
+
+//#> Expr
+
+//#< Nodes
+
+type node interface {
+	match(line *types.ParsedLine) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) match(line *types.ParsedLine) bool {
+	return n.left.match(line) && n.right.match(line)
+}
+
+type orNode struct{ left, right node }
+
+func (n orNode) match(line *types.ParsedLine) bool {
+	return n.left.match(line) || n.right.match(line)
+}
+
+type notNode struct{ inner node }
+
+func (n notNode) match(line *types.ParsedLine) bool {
+	return !n.inner.match(line)
+}
+
+// `field`
+type existsNode struct{ field string }
+
+func (n existsNode) match(line *types.ParsedLine) bool {
+	_, ok := line.Field(n.field)
+	return ok
+}
+
+// `field =~ "regex"` / `field !~ "regex"`
+type regexNode struct {
+	field  string
+	re     *regexp.Regexp
+	negate bool
+}
+
+func (n regexNode) match(line *types.ParsedLine) bool {
+	value, _ := line.Field(n.field)
+	return n.re.MatchString(value) != n.negate
+}
+
+// `field contains "substr"`
+type containsNode struct {
+	field  string
+	substr string
+}
+
+func (n containsNode) match(line *types.ParsedLine) bool {
+	value, _ := line.Field(n.field)
+	return strings.Contains(value, n.substr)
+}
+
+// `field in (a, b, c)`
+type inNode []compareNode
+
+func (n inNode) match(line *types.ParsedLine) bool {
+	for _, cmp := range n {
+		if cmp.match(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// `field == value`, `field < value`, etc.
+//
+// levels are compared by severity, so `level >= warn` is anything at least as
+// severe as a warning (lines without a level count as `info`).  other fields
+// are compared as numbers when both sides are numbers, otherwise as strings.
+type compareNode struct {
+	field string
+	op    string
+	value string
+
+	num   float64
+	isNum bool
+
+	severity types.Severity
+	isLevel  bool
+}
+
+func newCompareNode(field string, op string, value string) compareNode {
+	n := compareNode{field: field, op: op, value: value}
+	if field == types.Field_Level {
+		n.severity, n.isLevel = types.LevelSeverity(value)
+	}
+	if num, err := strconv.ParseFloat(value, 64); err == nil {
+		n.num, n.isNum = num, true
+	}
+	return n
+}
+
+func (n compareNode) match(line *types.ParsedLine) bool {
+	var order int
+	switch {
+	case n.isLevel:
+		// more severe is "greater", and has the lower syslog number
+		order = cmp.Compare(n.severity, line.Severity())
+	default:
+		value, _ := line.Field(n.field)
+		order = strings.Compare(value, n.value)
+		if n.isNum {
+			if num, err := strconv.ParseFloat(value, 64); err == nil {
+				order = cmp.Compare(num, n.num)
+			}
+		}
+	}
+
+	switch n.op {
+	case "==":
+		return order == 0
+	case "!=":
+		return order != 0
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	}
+	return false
+}
+
+//#> Nodes
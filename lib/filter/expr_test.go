@@ -0,0 +1,93 @@
+package filter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/erobsham/reform/lib/types"
+)
+
+func TestExpr_Match(t *testing.T) {
+	sshd := types.ParsedLine{
+		Host:       "hst-name001",
+		Process:    types.ProcessInfo{Name: "sshd", PID: 42},
+		LogLevel:   "warning",
+		Message:    "Failed password for root",
+		Source:     "edge",
+		Properties: map[string]any{"user": "root", "attempts": 12},
+	}
+	kernel := types.ParsedLine{
+		Host:     "hst-name002",
+		Process:  types.ProcessInfo{Name: "kernel"},
+		LogLevel: "err",
+		Message:  "oom-killer invoked",
+	}
+	noLevel := types.ParsedLine{
+		Host:    "other",
+		Message: "no level",
+	}
+
+	tests := []struct {
+		expr string
+		want []bool // sshd, kernel, noLevel
+	}{
+		{``, []bool{true, true, true}},
+		{`level >= warn and host =~ "hst-name00.*" and not proc == "kernel"`, []bool{true, false, false}},
+		{`level >= warn`, []bool{true, true, false}},
+		{`level > warning`, []bool{false, true, false}},
+		{`level <= info`, []bool{false, false, true}},
+		{`level == info`, []bool{false, false, true}},
+		{`level == error`, []bool{false, true, false}},
+		{`proc == kernel || proc == sshd`, []bool{true, true, false}},
+		{`!(proc == kernel) && host != other`, []bool{true, false, false}},
+		{`proc in (sshd, "cron")`, []bool{true, false, false}},
+		{`msg contains "password"`, []bool{true, false, false}},
+		{`message =~ '(?i)^failed'`, []bool{true, false, false}},
+		{`host !~ "^hst-"`, []bool{false, false, true}},
+		{`pid > 10`, []bool{true, false, false}},
+		{`attempts >= 9`, []bool{true, false, false}},
+		{`user == "root"`, []bool{true, false, false}},
+		{`user`, []bool{true, false, false}},
+		{`not source`, []bool{false, true, true}},
+		{`proc == sshd or proc == kernel and level == warn`, []bool{true, false, false}},
+		{`(proc == sshd or proc == kernel) and level == err`, []bool{false, true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := Parse(tt.expr)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			for i, line := range []types.ParsedLine{sshd, kernel, noLevel} {
+				if got := expr.Match(line); got != tt.want[i] {
+					t.Errorf("Match(%q) = %v, want %v", line.Message, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExpr_UnmarshalJSON(t *testing.T) {
+	var cfg struct {
+		Filter *Expr `json:"filter"`
+		Unset  *Expr `json:"unset"`
+	}
+	err := json.Unmarshal([]byte(`{"filter": "proc == \"sshd\""}`), &cfg)
+	if err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if got := cfg.Filter.String(); got != `proc == "sshd"` {
+		t.Errorf("String() = %q", got)
+	}
+	if cfg.Filter.Match(types.ParsedLine{}) {
+		t.Errorf("filter matched an empty line")
+	}
+	if !cfg.Unset.Match(types.ParsedLine{}) {
+		t.Errorf("a nil filter should match everything")
+	}
+
+	err = json.Unmarshal([]byte(`{"filter": "proc = \"sshd\""}`), &cfg)
+	if err == nil {
+		t.Errorf("Unmarshal() of an invalid filter didn't fail")
+	}
+}
@@ -0,0 +1,365 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/erobsham/reform/lib/types"
+)
+
+//#< Lexer
+
+type tokenKind uint8
+
+const (
+	token_EOF tokenKind = iota
+	// a field name, keyword, or bare value (ie `level`, `and`, `warn`, `hst-name001`)
+	token_Word
+	// a quoted string, already unquoted
+	token_String
+	token_Op
+	token_LParen
+	token_RParen
+	token_Comma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var operators = []string{"==", "!=", "=~", "!~", "<=", ">=", "&&", "||", "<", ">", "!"}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.' || r == '-' || r == '@'
+}
+
+func lex(src string) ([]token, error) {
+	tokens := []token{}
+	for pos := 0; pos < len(src); {
+		r, size := utf8.DecodeRuneInString(src[pos:])
+		switch {
+		case unicode.IsSpace(r):
+			pos += size
+
+		case r == '(':
+			tokens = append(tokens, token{kind: token_LParen, text: "(", pos: pos})
+			pos += 1
+		case r == ')':
+			tokens = append(tokens, token{kind: token_RParen, text: ")", pos: pos})
+			pos += 1
+		case r == ',':
+			tokens = append(tokens, token{kind: token_Comma, text: ",", pos: pos})
+			pos += 1
+
+		case r == '"' || r == '`' || r == '\'':
+			end := quotedEnd(src, pos)
+			if end < 0 {
+				return nil, parseErrorf(src, pos, "unterminated string")
+			}
+			quoted := src[pos:end]
+			if r == '\'' {
+				// single quotes are taken as-is, handy for regexes
+				tokens = append(tokens, token{kind: token_String, text: quoted[1 : len(quoted)-1], pos: pos})
+				pos = end
+				continue
+			}
+			str, err := strconv.Unquote(quoted)
+			if err != nil {
+				return nil, parseErrorf(src, pos, "invalid string %s", quoted)
+			}
+			tokens = append(tokens, token{kind: token_String, text: str, pos: pos})
+			pos = end
+
+		case isWordRune(r):
+			start := pos
+			for pos < len(src) {
+				r, size := utf8.DecodeRuneInString(src[pos:])
+				if !isWordRune(r) {
+					break
+				}
+				pos += size
+			}
+			tokens = append(tokens, token{kind: token_Word, text: src[start:pos], pos: start})
+
+		default:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(src[pos:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				if r == '=' {
+					return nil, parseErrorf(src, pos, "unexpected '=', did you mean '=='?")
+				}
+				return nil, parseErrorf(src, pos, "unexpected %q", r)
+			}
+			tokens = append(tokens, token{kind: token_Op, text: op, pos: pos})
+			pos += len(op)
+		}
+	}
+	return append(tokens, token{kind: token_EOF, pos: len(src)}), nil
+}
+
+// the index just past the closing quote of the string starting at `start`, or
+// -1 if it isn't closed
+func quotedEnd(src string, start int) int {
+	quote := src[start]
+	for i := start + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			if quote == '"' {
+				i += 1
+			}
+		case quote:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+//#> Lexer
+
+//#< Parser
+
+// Parse compiles a filter expression, ie:
+//
+//	level >= warn and host =~ "hst-name00.*" and not proc == "kernel"
+//
+// an empty expression matches everything.
+func Parse(src string) (*Expr, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{src: src, tokens: tokens}
+	if p.peek().kind == token_EOF {
+		return &Expr{src: src}, nil
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != token_EOF {
+		return nil, parseErrorf(src, t.pos, "unexpected %q", t.text)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+type parser struct {
+	src    string
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != token_EOF {
+		p.pos += 1
+	}
+	return t
+}
+
+// whether the next token is the keyword / operator `text`, consuming it if so
+func (p *parser) accept(text string, alt string) bool {
+	t := p.peek()
+	if (t.kind == token_Word || t.kind == token_Op) && (t.text == text || (alt != "" && t.text == alt)) {
+		p.pos += 1
+		return true
+	}
+	return false
+}
+
+// or := and ('or' and)*
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("or", "||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+// and := unary ('and' unary)*
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("and", "&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+// unary := 'not' unary | '(' or ')' | condition
+func (p *parser) parseUnary() (node, error) {
+	if p.accept("not", "!") {
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner}, nil
+	}
+
+	if p.peek().kind == token_LParen {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != token_RParen {
+			return nil, p.unexpected(t, "')'")
+		}
+		return inner, nil
+	}
+
+	return p.parseCondition()
+}
+
+// condition := field [op value | 'in' '(' value (',' value)* ')']
+func (p *parser) parseCondition() (node, error) {
+	t := p.next()
+	if t.kind != token_Word || isKeyword(t.text) {
+		return nil, p.unexpected(t, "a field name")
+	}
+	field := normalizeField(t.text)
+
+	op := p.peek()
+	switch {
+	case op.kind == token_Op && op.text != "!" && op.text != "&&" && op.text != "||":
+		p.next()
+	case op.kind == token_Word && (op.text == "contains" || op.text == "in"):
+		p.next()
+	default:
+		// a bare field checks that it's set
+		return existsNode{field}, nil
+	}
+
+	if op.text == "in" {
+		return p.parseIn(field)
+	}
+
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.text {
+	case "=~", "!~":
+		re, err := regexp.Compile(value.text)
+		if err != nil {
+			return nil, parseErrorf(p.src, value.pos, "invalid regex: %v", err)
+		}
+		return regexNode{field: field, re: re, negate: op.text == "!~"}, nil
+	case "contains":
+		return containsNode{field: field, substr: value.text}, nil
+	default:
+		return newCompareNode(field, op.text, value.text), nil
+	}
+}
+
+func (p *parser) parseIn(field string) (node, error) {
+	if t := p.next(); t.kind != token_LParen {
+		return nil, p.unexpected(t, "'('")
+	}
+
+	values := []string{}
+	for {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value.text)
+
+		t := p.next()
+		if t.kind == token_RParen {
+			break
+		}
+		if t.kind != token_Comma {
+			return nil, p.unexpected(t, "',' or ')'")
+		}
+	}
+
+	n := inNode{}
+	for _, value := range values {
+		n = append(n, newCompareNode(field, "==", value))
+	}
+	return n, nil
+}
+
+// a quoted string, or a bare word / number
+func (p *parser) parseValue() (token, error) {
+	t := p.next()
+	if t.kind == token_String || (t.kind == token_Word && !isKeyword(t.text)) {
+		return t, nil
+	}
+	return token{}, p.unexpected(t, "a value")
+}
+
+func (p *parser) unexpected(t token, want string) error {
+	if t.kind == token_EOF {
+		return parseErrorf(p.src, t.pos, "expected %s, got the end of the filter", want)
+	}
+	return parseErrorf(p.src, t.pos, "expected %s, got %q", want, t.text)
+}
+
+func isKeyword(word string) bool {
+	switch word {
+	case "and", "or", "not", "in", "contains":
+		return true
+	}
+	return false
+}
+
+// accepts a few longer spellings of the `types.Field_*` names
+func normalizeField(name string) string {
+	switch name {
+	case "message", "@m":
+		return types.Field_Message
+	case "process":
+		return types.Field_Process
+	case "@l":
+		return types.Field_Level
+	case "hostname":
+		return types.Field_Host
+	}
+	return name
+}
+
+//#> Parser
+
+//#< Custom Error Type
+
+type ParseError string
+
+func (e ParseError) Error() string { return string(e) }
+
+func parseErrorf(src string, pos int, format string, args ...any) ParseError {
+	return ParseError(fmt.Sprintf("invalid filter %q at offset %d: %s", src, pos, fmt.Sprintf(format, args...)))
+}
+
+//#> Custom Error Type
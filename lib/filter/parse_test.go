@@ -0,0 +1,56 @@
+package filter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{`proc = sshd`, "did you mean '=='"},
+		{`proc == `, "expected a value, got the end of the filter"},
+		{`proc == "sshd`, "unterminated string"},
+		{`(proc == sshd`, "expected ')'"},
+		{`proc == sshd)`, `unexpected ")"`},
+		{`and proc == sshd`, `expected a field name, got "and"`},
+		{`host =~ "("`, "invalid regex"},
+		{`proc in sshd`, "expected '('"},
+		{`proc in (sshd cron)`, "expected ',' or ')'"},
+		{`host == a $ b`, `unexpected '$'`},
+		{`host == a host == b`, `unexpected "host"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := Parse(tt.expr)
+			if err == nil {
+				t.Fatalf("Parse() didn't fail")
+			}
+			if !errors.As(err, new(ParseError)) {
+				t.Errorf("error isn't a ParseError: %T", err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want it to contain %q", err.Error(), tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_lex(t *testing.T) {
+	tokens, err := lex(`level>=warn && msg contains "a \"b\"" || x =~ '\d+'`)
+	if err != nil {
+		t.Fatalf("lex() error = %v", err)
+	}
+
+	got := []string{}
+	for _, tok := range tokens[:len(tokens)-1] {
+		got = append(got, tok.text)
+	}
+	want := []string{"level", ">=", "warn", "&&", "msg", "contains", `a "b"`, "||", "x", "=~", `\d+`}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got vs want:\n  %q\n  %q", got, want)
+	}
+}
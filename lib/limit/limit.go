@@ -0,0 +1,224 @@
+package limit
+
+import (
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/filter"
+	"github.com/erobsham/reform/lib/types"
+)
+
+//#< Limiter
+
+// the `Source` / process name of the summary events
+const SummarySource = "reform"
+
+// New builds a Limiter from the rate limits and sampling in `cfg`.
+func New(cfg config.LimitCfg) *Limiter {
+	return newLimiter(cfg, time.Now, rand.Float64)
+}
+
+func newLimiter(cfg config.LimitCfg, now func() time.Time, random func() float64) *Limiter {
+	cfg = cfg.WithDefaults()
+
+	l := &Limiter{
+		summaryInterval: cfg.SummaryInterval,
+		now:             now,
+		random:          random,
+		lastSummary:     now(),
+	}
+	for i := range l.sample {
+		l.sample[i] = 1
+	}
+	for severity, keep := range cfg.Sample {
+		l.sample[severity] = keep
+	}
+
+	for _, rateCfg := range cfg.Rate {
+		rateCfg = rateCfg.WithDefaults()
+		l.rates = append(l.rates, &rateLimit{
+			name:    rateCfg.Name,
+			by:      rateCfg.By,
+			match:   rateCfg.Match,
+			perSec:  rateCfg.Rate / rateCfg.Per.Seconds(),
+			burst:   float64(rateCfg.Burst),
+			buckets: map[string]*bucket{},
+		})
+	}
+	return l
+}
+
+// Limiter drops lines by sampling their level, then by the rate limits, and
+// keeps count of what it dropped for the summary events.
+type Limiter struct {
+	rates []*rateLimit
+	// the fraction of lines kept, by severity
+	sample  [types.Severity_Debug + 1]float64
+	sampled [types.Severity_Debug + 1]uint64
+
+	summaryInterval time.Duration
+	lastSummary     time.Time
+
+	now    func() time.Time
+	random func() float64
+}
+
+// Allow reports whether `line` should be kept, a nil Limiter keeps everything.
+func (l *Limiter) Allow(line types.ParsedLine) bool {
+	if l == nil {
+		return true
+	}
+
+	severity := line.Severity()
+	if keep := l.sample[severity]; keep < 1 && l.random() >= keep {
+		l.sampled[severity] += 1
+		return false
+	}
+
+	// every matching bucket needs a token before any are used, so a line
+	// dropped by one limit doesn't count against the others
+	now := l.now()
+	buckets := make([]*bucket, 0, len(l.rates))
+	for _, rate := range l.rates {
+		b := rate.bucket(line, now)
+		if b == nil {
+			continue
+		}
+		if b.tokens < 1 {
+			rate.dropped += 1
+			return false
+		}
+		buckets = append(buckets, b)
+	}
+	for _, b := range buckets {
+		b.tokens -= 1
+	}
+	return true
+}
+
+// Summary returns an event counting the lines dropped since the last summary,
+// once the summary interval has passed (or straight away when `force`, ie on
+// shutdown).  nothing is returned if nothing was dropped.
+func (l *Limiter) Summary(force bool) (types.ParsedLine, bool) {
+	if l == nil {
+		return types.ParsedLine{}, false
+	}
+
+	now := l.now()
+	elapsed := now.Sub(l.lastSummary)
+	if !force && elapsed < l.summaryInterval {
+		return types.ParsedLine{}, false
+	}
+	l.lastSummary = now
+
+	total := uint64(0)
+	rateLimited := map[string]any{}
+	for _, rate := range l.rates {
+		if rate.dropped > 0 {
+			rateLimited[rate.name] = rate.dropped
+			total += rate.dropped
+			rate.dropped = 0
+		}
+		rate.prune(now)
+	}
+	sampled := map[string]any{}
+	for severity, count := range l.sampled {
+		if count > 0 {
+			sampled[types.Severity(severity).String()] = count
+			total += count
+			l.sampled[severity] = 0
+		}
+	}
+
+	if total == 0 {
+		return types.ParsedLine{}, false
+	}
+
+	properties := map[string]any{"dropped": total}
+	if len(rateLimited) > 0 {
+		properties["rate_limited"] = rateLimited
+	}
+	if len(sampled) > 0 {
+		properties["sampled"] = sampled
+	}
+	return types.ParsedLine{
+		Timestamp:  now,
+		Process:    types.ProcessInfo{Name: SummarySource},
+		LogLevel:   "warn",
+		Source:     SummarySource,
+		Message:    "dropped " + strconv.FormatUint(total, 10) + " lines in the last " + elapsed.Round(time.Second).String(),
+		Properties: properties,
+	}, true
+}
+
+//#> Limiter
+
+//#< Rate Limits
+
+type rateLimit struct {
+	name  string
+	by    []string
+	match *filter.Expr
+
+	perSec float64
+	burst  float64
+
+	buckets map[string]*bucket
+	dropped uint64
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// the line's bucket, refilled up to `now`, or nil when the limit doesn't apply to it
+func (r *rateLimit) bucket(line types.ParsedLine, now time.Time) *bucket {
+	if !r.match.Match(line) {
+		return nil
+	}
+
+	key := r.key(line)
+	b, exists := r.buckets[key]
+	if !exists {
+		b = &bucket{tokens: r.burst, last: now}
+		r.buckets[key] = b
+	}
+
+	b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*r.perSec, r.burst)
+	b.last = now
+	return b
+}
+
+// the values of the `by` fields, ie `hst-name001\x00sshd`
+func (r *rateLimit) key(line types.ParsedLine) string {
+	if len(r.by) == 1 {
+		value, _ := line.Field(r.by[0])
+		return value
+	}
+
+	b := strings.Builder{}
+	for i, field := range r.by {
+		if i > 0 {
+			b.WriteByte(0)
+		}
+		value, _ := line.Field(field)
+		b.WriteString(value)
+	}
+	return b.String()
+}
+
+// forgets the buckets which would have refilled by now, they're the same as new ones
+func (r *rateLimit) prune(now time.Time) {
+	refill := time.Duration(r.burst / r.perSec * float64(time.Second))
+	for key, b := range r.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(r.buckets, key)
+		}
+	}
+}
+
+//#> Rate Limits
@@ -0,0 +1,178 @@
+package limit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/filter"
+	"github.com/erobsham/reform/lib/types"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func TestLimiter_Rate(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)}
+	match, _ := filter.Parse("level < warn")
+
+	l := newLimiter(config.LimitCfg{
+		Rate: []config.RateLimitCfg{
+			{Name: "per-proc", By: []string{"host", "proc"}, Rate: 2, Burst: 3, Match: match},
+		},
+	}, clock.now, func() float64 { return 0 })
+
+	chatty := types.ParsedLine{Host: "hst-1", Process: types.ProcessInfo{Name: "chatty"}}
+	quiet := types.ParsedLine{Host: "hst-1", Process: types.ProcessInfo{Name: "quiet"}}
+	errLine := types.ParsedLine{Host: "hst-1", Process: types.ProcessInfo{Name: "chatty"}, LogLevel: "error"}
+
+	allowed := 0
+	for range 10 {
+		if l.Allow(chatty) {
+			allowed += 1
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("allowed %d lines from a burst, want 3", allowed)
+	}
+
+	// other processes have their own bucket, and errors don't match the limit
+	if !l.Allow(quiet) || !l.Allow(errLine) {
+		t.Errorf("line wrongly limited")
+	}
+
+	// refills at 2/s
+	clock.t = clock.t.Add(time.Second)
+	if !l.Allow(chatty) || !l.Allow(chatty) || l.Allow(chatty) {
+		t.Errorf("bucket didn't refill at the rate")
+	}
+
+	if got := len(l.rates[0].buckets); got != 2 {
+		t.Errorf("got %d buckets, want 2", got)
+	}
+	clock.t = clock.t.Add(time.Minute)
+	l.Summary(true)
+	if got := len(l.rates[0].buckets); got != 0 {
+		t.Errorf("idle buckets weren't pruned, got %d", got)
+	}
+}
+
+func TestLimiter_RateOverlapping(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)}
+
+	l := newLimiter(config.LimitCfg{
+		Rate: []config.RateLimitCfg{
+			{Name: "per-host", By: []string{"host"}, Rate: 1, Burst: 5},
+			{Name: "per-proc", By: []string{"host", "proc"}, Rate: 1, Burst: 2},
+		},
+	}, clock.now, func() float64 { return 0 })
+
+	chatty := types.ParsedLine{Host: "hst-1", Process: types.ProcessInfo{Name: "chatty"}}
+	quiet := types.ParsedLine{Host: "hst-1", Process: types.ProcessInfo{Name: "quiet"}}
+
+	allowed := 0
+	for range 10 {
+		if l.Allow(chatty) {
+			allowed += 1
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("allowed %d chatty lines, want 2", allowed)
+	}
+
+	// the lines per-proc dropped didn't use up the host's tokens
+	allowed = 0
+	for range 10 {
+		if l.Allow(quiet) {
+			allowed += 1
+		}
+	}
+	if allowed != 2 {
+		t.Errorf("allowed %d quiet lines, want 2", allowed)
+	}
+
+	summary, _ := l.Summary(true)
+	want := map[string]any{"per-proc": uint64(16)}
+	if got := summary.Properties["rate_limited"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("rate_limited = %v, want %v", got, want)
+	}
+}
+
+func TestLimiter_Sample(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)}
+	rolls := []float64{0.05, 0.5, 0.95, 0.2}
+	random := func() float64 {
+		r := rolls[0]
+		rolls = append(rolls[1:], r)
+		return r
+	}
+
+	l := newLimiter(config.LimitCfg{
+		Sample: map[types.Severity]float64{types.Severity_Debug: 0.1, types.Severity_Info: 0.5},
+	}, clock.now, random)
+
+	kept := map[string]int{}
+	for _, level := range []string{"debug", "info", "error"} {
+		for range 4 {
+			if l.Allow(types.ParsedLine{LogLevel: level}) {
+				kept[level] += 1
+			}
+		}
+	}
+
+	want := map[string]int{"debug": 1, "info": 2, "error": 4}
+	if !reflect.DeepEqual(kept, want) {
+		t.Errorf("kept got vs want:\n  %+v\n  %+v", kept, want)
+	}
+}
+
+func TestLimiter_Summary(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)}
+	l := newLimiter(config.LimitCfg{
+		Rate:            []config.RateLimitCfg{{Name: "all", Rate: 1}},
+		Sample:          map[types.Severity]float64{types.Severity_Debug: 0},
+		SummaryInterval: time.Minute,
+	}, clock.now, func() float64 { return 0.5 })
+
+	for range 5 {
+		l.Allow(types.ParsedLine{LogLevel: "info"})
+	}
+	l.Allow(types.ParsedLine{LogLevel: "debug"})
+
+	if _, ok := l.Summary(false); ok {
+		t.Errorf("summary before the interval")
+	}
+
+	clock.t = clock.t.Add(time.Minute)
+	got, ok := l.Summary(false)
+	if !ok {
+		t.Fatalf("no summary after the interval")
+	}
+	want := types.ParsedLine{
+		Timestamp: clock.t,
+		Process:   types.ProcessInfo{Name: SummarySource},
+		LogLevel:  "warn",
+		Source:    SummarySource,
+		Message:   "dropped 5 lines in the last 1m0s",
+		Properties: map[string]any{
+			"dropped":      uint64(5),
+			"rate_limited": map[string]any{"all": uint64(4)},
+			"sampled":      map[string]any{"debug": uint64(1)},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Summary() got vs want:\n  %+v\n  %+v", got, want)
+	}
+
+	// the counts are reset
+	if _, ok := l.Summary(true); ok {
+		t.Errorf("summary without any drops")
+	}
+
+	var nilLimiter *Limiter
+	if !nilLimiter.Allow(types.ParsedLine{}) {
+		t.Errorf("a nil Limiter should allow everything")
+	}
+}
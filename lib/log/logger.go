@@ -2,6 +2,7 @@ package log
 
 import (
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"sync"
@@ -10,10 +11,11 @@ import (
 var initSync sync.Once
 var stdErrLogger *slog.Logger
 var logLevel slog.LevelVar
+var logOutput = &switchableWriter{w: os.Stderr}
 
 func defaultStdErrLogger() *slog.Logger {
 	initSync.Do(func() {
-		h := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: &logLevel})
+		h := slog.NewTextHandler(logOutput, &slog.HandlerOptions{Level: &logLevel})
 		l := slog.New(h)
 		stdErrLogger = l
 	})
@@ -24,6 +26,9 @@ func defaultStdErrLogger() *slog.Logger {
 func SetDefaultLogLevel(level slog.Level) { logLevel.Set(level) }
 func Default() *slog.Logger               { return defaultStdErrLogger() }
 
+// SetOutput redirects all logging (stderr by default), ie while the tui owns the terminal.
+func SetOutput(w io.Writer) { logOutput.set(w) }
+
 func DebugErr(msg string, err error) {
 	defaultStdErrLogger().
 		Debug(msg, slog.String("error", err.Error()))
@@ -33,3 +38,21 @@ func Infof(format string, args ...any) {
 	defaultStdErrLogger().
 		Info(fmt.Sprintf(format, args...))
 }
+
+// lets the log output be swapped out after the (shared) logger has been created
+type switchableWriter struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func (s *switchableWriter) Write(p []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.w.Write(p)
+}
+
+func (s *switchableWriter) set(w io.Writer) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.w = w
+}
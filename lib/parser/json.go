@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/erobsham/reform/lib/types"
+)
+
+// the keys a json message's fields are read from, in order of preference.
+// CLEF's first, then what structured loggers commonly use.
+var (
+	jsonMessageKeys   = []string{"@m", "@mt", "message", "msg"}
+	jsonLevelKeys     = []string{"@l", "level", "severity"}
+	jsonTimestampKeys = []string{"@t", "time", "timestamp", "ts"}
+	jsonHostKeys      = []string{"host", "hostname"}
+)
+
+// CLEF's (Serilog's) level names which aren't syslog's
+var clefLevels = map[string]string{
+	"verbose":     "debug",
+	"information": "info",
+	"fatal":       "crit",
+}
+
+type jsonMessage struct {
+	message    string
+	level      string
+	timestamp  time.Time
+	host       string
+	properties map[string]any
+}
+
+// parses a message which is a json object (ie CLEF, or a structured logger's
+// output), the keys which aren't one of the line's fields become properties.
+func parseJSONMessage(msg string) (parsed jsonMessage, err error) {
+	msg = strings.TrimSpace(msg)
+	if !strings.HasPrefix(msg, "{") || !strings.HasSuffix(msg, "}") {
+		return parsed, ParseError("not a json object")
+	}
+
+	var fields map[string]any
+	err = json.Unmarshal([]byte(msg), &fields)
+	if err != nil {
+		return parsed, err
+	}
+
+	parsed.message, _ = takeJSONString(fields, jsonMessageKeys)
+	if level, ok := takeJSONString(fields, jsonLevelKeys); ok {
+		parsed.level = normalizeJSONLevel(level)
+	}
+	for _, key := range jsonTimestampKeys {
+		ts, ok := fields[key].(string)
+		if !ok {
+			continue
+		}
+		// an unparsable timestamp is left as a property, rather than lost
+		if parsed.timestamp, err = time.Parse(time.RFC3339Nano, ts); err == nil {
+			delete(fields, key)
+			break
+		}
+		if key == "@t" {
+			// "@t" is the line's own field
+			delete(fields, key)
+			fields["timestamp"] = ts
+		}
+	}
+	parsed.host, _ = takeJSONString(fields, jsonHostKeys)
+
+	if len(fields) > 0 {
+		parsed.properties = fields
+	}
+	return parsed, nil
+}
+
+// fills in the line's fields from the message, those already parsed from the
+// syslog prefix are kept.
+func (msg jsonMessage) apply(line types.ParsedLine) types.ParsedLine {
+	line.Message = msg.message
+	line.LogLevel = msg.level
+	line.Properties = msg.properties
+
+	switch {
+	case line.Host == "":
+		line.Host = msg.host
+	case msg.host != "":
+		// "host" is the line's own field
+		if line.Properties == nil {
+			line.Properties = map[string]any{}
+		}
+		line.Properties["hostname"] = msg.host
+	}
+
+	switch {
+	case line.Timestamp.IsZero():
+		line.Timestamp = msg.timestamp
+	case !msg.timestamp.IsZero():
+		line.Timestamp = pickMorePreciseTime(line.Timestamp, msg.timestamp)
+	}
+	return line
+}
+
+// removes and returns the first of `keys` with a string value
+func takeJSONString(fields map[string]any, keys []string) (string, bool) {
+	for _, key := range keys {
+		if str, ok := fields[key].(string); ok {
+			delete(fields, key)
+			return str, true
+		}
+	}
+	return "", false
+}
+
+func normalizeJSONLevel(level string) string {
+	level = strings.ToLower(strings.TrimSpace(level))
+	if normalized, ok := levelNormalizationMap[level]; ok {
+		return normalized
+	}
+	if normalized, ok := clefLevels[level]; ok {
+		return normalized
+	}
+	return level
+}
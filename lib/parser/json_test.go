@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/erobsham/reform/lib/types"
+)
+
+func TestParseLine_JSON(t *testing.T) {
+	year := time.Now().Year()
+
+	tests := []struct {
+		name string
+		line string
+		want types.ParsedLine
+	}{
+		{
+			name: "clef",
+			line: `{"@t":"2025-06-12T08:24:46.123Z","@m":"user logged in","@l":"Warning","user":"bob","port":22}`,
+			want: types.ParsedLine{
+				Timestamp:  time.Date(2025, 6, 12, 8, 24, 46, 123000000, time.UTC),
+				Message:    "user logged in",
+				LogLevel:   "warn",
+				Properties: map[string]any{"user": "bob", "port": float64(22)},
+			},
+		},
+		{
+			name: "clef level names",
+			line: `{"@m":"boom","@l":"Fatal"}`,
+			want: types.ParsedLine{Message: "boom", LogLevel: "crit"},
+		},
+		{
+			name: "syslog prefix",
+			line: `Jun 12 08:24:46 hst-name0000 app[42]: {"msg":"user logged in","level":"INFO","user":"bob"}`,
+			want: types.ParsedLine{
+				Timestamp:  time.Date(year, 6, 12, 8, 24, 46, 0, time.UTC),
+				Host:       "hst-name0000",
+				Process:    types.ProcessInfo{Name: "app", PID: 42},
+				Message:    "user logged in",
+				LogLevel:   "info",
+				Properties: map[string]any{"user": "bob"},
+			},
+		},
+		{
+			name: "syslog host is kept",
+			line: `Jun 12 08:24:46 hst-name0000 app[42]: {"msg":"hi","host":"web1"}`,
+			want: types.ParsedLine{
+				Timestamp:  time.Date(year, 6, 12, 8, 24, 46, 0, time.UTC),
+				Host:       "hst-name0000",
+				Process:    types.ProcessInfo{Name: "app", PID: 42},
+				Message:    "hi",
+				Properties: map[string]any{"hostname": "web1"},
+			},
+		},
+		{
+			name: "unparsable timestamp is a property",
+			line: `{"@t":"yesterday","@m":"hi"}`,
+			want: types.ParsedLine{
+				Message:    "hi",
+				Properties: map[string]any{"timestamp": "yesterday"},
+			},
+		},
+		{
+			name: "not an object",
+			line: `Jun 12 08:24:46 hst-name0000 app[42]: ["a","b"]`,
+			want: types.ParsedLine{
+				Timestamp: time.Date(year, 6, 12, 8, 24, 46, 0, time.UTC),
+				Host:      "hst-name0000",
+				Process:   types.ProcessInfo{Name: "app", PID: 42},
+				Message:   `["a","b"]`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseLine(tt.line)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLine() got vs want:\n  %#v\n  %#v", got, tt.want)
+			}
+		})
+	}
+}
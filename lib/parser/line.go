@@ -8,6 +8,11 @@ import (
 )
 
 func ParseLine(line string) types.ParsedLine {
+	// a line which is only a json object (ie CLEF) has no syslog prefix
+	if msg, err := parseJSONMessage(line); err == nil {
+		return msg.apply(types.ParsedLine{})
+	}
+
 	sysTimestamp, remaining, err := ParseSystemTimeStamp(line)
 	if err != nil {
 		log.DebugErr("timestamp parse error", err)
@@ -23,6 +28,14 @@ func ParseLine(line string) types.ParsedLine {
 		log.DebugErr("process info parse error", err)
 	}
 
+	if msg, err := parseJSONMessage(remaining); err == nil {
+		return msg.apply(types.ParsedLine{
+			Timestamp: withCurrentYear(sysTimestamp),
+			Host:      hostname,
+			Process:   proc,
+		})
+	}
+
 	//
 	// optional msg details
 	//
@@ -55,21 +68,8 @@ func ParseLine(line string) types.ParsedLine {
 		sysTimestamp = pickMorePreciseTime(sysTimestamp, suffixTimestamp)
 	}
 
-	if sysTimestamp.Year() == 0 {
-		sysTimestamp = time.Date(
-			time.Now().Year(),
-			sysTimestamp.Month(),
-			sysTimestamp.Day(),
-			sysTimestamp.Hour(),
-			sysTimestamp.Minute(),
-			sysTimestamp.Second(),
-			sysTimestamp.Nanosecond(),
-			sysTimestamp.Location(),
-		)
-	}
-
 	return types.ParsedLine{
-		Timestamp: sysTimestamp,
+		Timestamp: withCurrentYear(sysTimestamp),
 		Host:      hostname,
 		Process:   proc,
 		Message:   remaining,
@@ -79,6 +79,23 @@ func ParseLine(line string) types.ParsedLine {
 	}
 }
 
+// syslog timestamps don't include the year
+func withCurrentYear(ts time.Time) time.Time {
+	if ts.Year() != 0 {
+		return ts
+	}
+	return time.Date(
+		time.Now().Year(),
+		ts.Month(),
+		ts.Day(),
+		ts.Hour(),
+		ts.Minute(),
+		ts.Second(),
+		ts.Nanosecond(),
+		ts.Location(),
+	)
+}
+
 //#< Custom Error Type
 
 type ParseError string
@@ -2,6 +2,26 @@ package parser
 
 import "strings"
 
+var levelNormalizationMap = map[string]string{
+	"trace":     "debug",
+	"dbg":       "debug",
+	"debug":     "debug",
+	"debugging": "debug",
+	"inf":       "info",
+	"info":      "info",
+	"notice":    "info",
+	"warn":      "warn",
+	"wrn":       "warn",
+	"warning":   "warn",
+	"err":       "error",
+	"error":     "error",
+	"crit":      "crit",
+	"critical":  "crit",
+	"alert":     "alert",
+	"emerg":     "alert",
+	"emergency": "alert",
+}
+
 func parseLogLevel(line string) (logLevel string, remainder string, err error) {
 	const max_prefix_len = 9 + 2 // (max keylen + 2 for any 'wrappers')
 	stdLogLevels := map[string]struct{}{
@@ -23,25 +43,6 @@ func parseLogLevel(line string) (logLevel string, remainder string, err error) {
 		"emerg":     {},
 		"emergency": {},
 	}
-	levelNormalizationMap := map[string]string{
-		"trace":     "debug",
-		"dbg":       "debug",
-		"debug":     "debug",
-		"debugging": "debug",
-		"inf":       "info",
-		"info":      "info",
-		"notice":    "info",
-		"warn":      "warn",
-		"wrn":       "warn",
-		"warning":   "warn",
-		"err":       "error",
-		"error":     "error",
-		"crit":      "crit",
-		"critical":  "crit",
-		"alert":     "alert",
-		"emerg":     "alert",
-		"emergency": "alert",
-	}
 
 	line = strings.TrimSpace(line)
 	if len(line) < max_prefix_len+1 {
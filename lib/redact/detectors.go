@@ -0,0 +1,80 @@
+package redact
+
+import (
+	"net/netip"
+	"regexp"
+	"strings"
+
+	"github.com/erobsham/reform/lib/config"
+)
+
+//#< Detectors
+
+type detector struct {
+	name string
+	re   *regexp.Regexp
+	// the submatch to redact, 0 for the whole match
+	group int
+	// double checks a candidate match, when the regex alone is too loose
+	valid func(value string) bool
+}
+
+var builtinDetectors = map[string]detector{
+	// `user:password@` in any url
+	config.RedactDetector_URLPassword: {
+		re:    regexp.MustCompile(`\b[a-zA-Z][a-zA-Z0-9+.-]*://[^/\s:@]+:([^/\s@]+)@`),
+		group: 1,
+	},
+	// `Authorization: Bearer {token}`
+	config.RedactDetector_BearerToken: {
+		re:    regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`),
+		group: 1,
+	},
+	// `api_key=...` style assignments, plus a few well known token prefixes
+	config.RedactDetector_APIKey: {
+		re: regexp.MustCompile(`(?i)(?:\b(?:api[_-]?key|apikey|(?:access|auth|refresh)[_-]?token|token|secret|password|passwd|x-seq-apikey)["']?\s*[:=]\s*["']?([A-Za-z0-9\-._~+/]{8,})` +
+			`|\b((?:AKIA|ASIA)[0-9A-Z]{16}|gh[pousr]_[A-Za-z0-9]{36,}|xox[abposr]-[A-Za-z0-9-]{10,}|[sr]k_(?:live|test)_[A-Za-z0-9]{16,}))`),
+		group: -1, // whichever alternative matched
+	},
+	config.RedactDetector_Email: {
+		re: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`),
+	},
+	config.RedactDetector_MAC: {
+		re: regexp.MustCompile(`\b(?:[0-9A-Fa-f]{2}[:-]){5}[0-9A-Fa-f]{2}\b`),
+	},
+	config.RedactDetector_IP: {
+		re: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b|(?i:\b[0-9a-f]{0,4}(?::[0-9a-f]{0,4}){2,7}\b)`),
+		valid: func(value string) bool {
+			addr, err := netip.ParseAddr(value)
+			// ie `10:00:00` isn't an ipv6 address, but `fe80::1` is
+			return err == nil && (addr.Is4() || strings.Count(value, ":") >= 2)
+		},
+	},
+}
+
+// the byte ranges of `str` to redact
+func (d detector) find(str string) [][2]int {
+	ranges := [][2]int{}
+	for _, m := range d.re.FindAllStringSubmatchIndex(str, -1) {
+		start, end := m[0], m[1]
+		switch {
+		case d.group > 0:
+			start, end = m[d.group*2], m[d.group*2+1]
+		case d.group < 0:
+			// the first submatch which took part
+			for i := 2; i < len(m); i += 2 {
+				if m[i] >= 0 {
+					start, end = m[i], m[i+1]
+					break
+				}
+			}
+		}
+		if start < 0 || (d.valid != nil && !d.valid(str[start:end])) {
+			continue
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges
+}
+
+//#> Detectors
@@ -0,0 +1,173 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/types"
+)
+
+//#< Redactor
+
+// the hex digits of the HMAC kept in `hash` mode
+const hashLength = 12
+
+// New builds a Redactor from the built-in detectors and user rules in `cfg`.
+func New(cfg config.RedactCfg) (*Redactor, error) {
+	cfg = cfg.WithDefaults()
+
+	r := &Redactor{
+		mode: cfg.Mode,
+		mask: cfg.Mask,
+	}
+	for _, key := range cfg.Keys {
+		if key = normalizeKey(key); key != "" {
+			r.keys = append(r.keys, key)
+		}
+	}
+	if cfg.Mode == config.RedactMode_Hash {
+		if cfg.HashKey == "" {
+			return nil, fmt.Errorf("a hash key is required for the hash mode")
+		}
+		r.hashKey = []byte(cfg.HashKey)
+	}
+
+	for _, name := range cfg.Detectors {
+		d, known := builtinDetectors[name]
+		if !known {
+			return nil, fmt.Errorf("unknown redact detector: %q", name)
+		}
+		d.name = name
+		r.detectors = append(r.detectors, d)
+	}
+	// user rules run after the built-in ones, in name order
+	for _, name := range slices.Sorted(maps.Keys(cfg.Rules)) {
+		re, err := regexp.Compile(cfg.Rules[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex for rule %q: %w", name, err)
+		}
+		r.detectors = append(r.detectors, detector{name: name, re: re})
+	}
+
+	return r, nil
+}
+
+// Redactor replaces secrets / PII in a line's message, properties and raw text.
+type Redactor struct {
+	detectors []detector
+	// normalized property names, see `isSecretKey`
+	keys []string
+
+	mode    config.RedactMode
+	mask    string
+	hashKey []byte
+}
+
+// Redact masks everything the detectors find in `line`, plus the values of
+// properties with a secret sounding name.  the properties are copied rather
+// than changed in place, as the map may be shared with other lines / outputs.
+// a nil Redactor leaves the line alone.
+func (r *Redactor) Redact(line *types.ParsedLine) {
+	if r == nil {
+		return
+	}
+
+	line.Message = r.String(line.Message)
+	line.Raw = r.String(line.Raw)
+	if line.Properties != nil {
+		line.Properties = r.properties(line.Properties)
+	}
+}
+
+// String returns `str` with everything the detectors find replaced.
+func (r *Redactor) String(str string) string {
+	if str == "" {
+		return str
+	}
+
+	for _, d := range r.detectors {
+		ranges := d.find(str)
+		if len(ranges) == 0 {
+			continue
+		}
+
+		b := strings.Builder{}
+		prev := 0
+		for _, rng := range ranges {
+			b.WriteString(str[prev:rng[0]])
+			b.WriteString(r.replacement(d.name, str[rng[0]:rng[1]]))
+			prev = rng[1]
+		}
+		b.WriteString(str[prev:])
+		str = b.String()
+	}
+	return str
+}
+
+// a redacted copy of `props`
+func (r *Redactor) properties(props map[string]any) map[string]any {
+	redacted := make(map[string]any, len(props))
+	for key, value := range props {
+		if value != nil && r.isSecretKey(key) {
+			redacted[key] = r.replacement("key", fmt.Sprint(value))
+		} else {
+			redacted[key] = r.value(value)
+		}
+	}
+	return redacted
+}
+
+// redacts strings nested anywhere in a property value, copying any maps /
+// slices on the way
+func (r *Redactor) value(v any) any {
+	switch v := v.(type) {
+	case string:
+		return r.String(v)
+	case map[string]any:
+		return r.properties(v)
+	case []any:
+		redacted := make([]any, len(v))
+		for i, inner := range v {
+			redacted[i] = r.value(inner)
+		}
+		return redacted
+	default:
+		return v
+	}
+}
+
+// whether a property named `key` holds a secret, ie `password`, `db_password`
+// or `X-Api-Key` (with the `apikey` key)
+func (r *Redactor) isSecretKey(key string) bool {
+	key = normalizeKey(key)
+	for _, secret := range r.keys {
+		if strings.HasSuffix(key, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeKey(key string) string {
+	key = strings.ToLower(key)
+	return strings.NewReplacer("-", "", "_", "").Replace(key)
+}
+
+func (r *Redactor) replacement(name string, value string) string {
+	if r.mode != config.RedactMode_Hash {
+		return r.mask
+	}
+
+	mac := hmac.New(sha256.New, r.hashKey)
+	mac.Write([]byte(value))
+	return "[" + name + ":" + hex.EncodeToString(mac.Sum(nil))[:hashLength] + "]"
+}
+
+//#> Redactor
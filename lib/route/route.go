@@ -0,0 +1,112 @@
+package route
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/filter"
+	"github.com/erobsham/reform/lib/types"
+)
+
+//#< Router
+
+// New builds a Router for the `outputs` (by name, in the order lines are sent
+// to them).  every route's outputs must exist, and route / output names must
+// be unique.
+func New(cfgs []config.RouteCfg, outputs []string) (*Router, error) {
+	byName := map[string]int{}
+	for i, name := range outputs {
+		if _, exists := byName[name]; exists {
+			return nil, fmt.Errorf("duplicate output name: %q", name)
+		}
+		byName[name] = i
+	}
+
+	r := &Router{}
+	names := map[string]struct{}{}
+	routed := make([]bool, len(outputs))
+	for i, cfg := range cfgs {
+		rt := &route{name: cfg.Name, match: cfg.Match, continues: cfg.Continue}
+		if rt.name == "" {
+			rt.name = "route-" + strconv.Itoa(i)
+		}
+		if _, exists := names[rt.name]; exists {
+			return nil, fmt.Errorf("duplicate route name: %q", rt.name)
+		}
+		names[rt.name] = struct{}{}
+
+		for _, name := range cfg.Outputs {
+			idx, exists := byName[name]
+			if !exists {
+				return nil, fmt.Errorf("route %q has an unknown output: %q", rt.name, name)
+			}
+			rt.outputs = append(rt.outputs, idx)
+			routed[idx] = true
+		}
+		r.routes = append(r.routes, rt)
+	}
+
+	for i, isRouted := range routed {
+		if !isRouted {
+			r.unrouted = append(r.unrouted, i)
+		}
+	}
+	return r, nil
+}
+
+// Router picks which outputs get each line: the first matching route's (and
+// the later ones', while they `continue`), plus the outputs which aren't in
+// any route.
+type Router struct {
+	routes []*route
+	// indexes of the outputs which aren't in any route, they get every line
+	unrouted []int
+}
+
+type route struct {
+	name      string
+	match     *filter.Expr
+	outputs   []int
+	continues bool
+
+	lines uint64
+}
+
+// Route sets `send[i]` for each output the line goes to.
+func (r *Router) Route(line types.ParsedLine, send []bool) {
+	clear(send)
+	for _, idx := range r.unrouted {
+		send[idx] = true
+	}
+
+	for _, rt := range r.routes {
+		if !rt.match.Match(line) {
+			continue
+		}
+		rt.lines += 1
+		for _, idx := range rt.outputs {
+			send[idx] = true
+		}
+		if !rt.continues {
+			break
+		}
+	}
+}
+
+// the number of lines a route matched
+type Stats struct {
+	Name  string
+	Lines uint64
+}
+
+// Stats returns each route's counts, in order.
+func (r *Router) Stats() []Stats {
+	stats := make([]Stats, 0, len(r.routes))
+	for _, rt := range r.routes {
+		stats = append(stats, Stats{Name: rt.name, Lines: rt.lines})
+	}
+	return stats
+}
+
+//#> Router
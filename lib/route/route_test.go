@@ -0,0 +1,187 @@
+package route
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/filter"
+	"github.com/erobsham/reform/lib/types"
+)
+
+func mustParse(t *testing.T, src string) *filter.Expr {
+	t.Helper()
+	expr, err := filter.Parse(src)
+	if err != nil {
+		t.Fatalf("parse %q: %v", src, err)
+	}
+	return expr
+}
+
+func TestRouter_Route(t *testing.T) {
+	outputs := []string{"alerts", "archive", "stdout"}
+	errLine := types.ParsedLine{LogLevel: "err", Process: types.ProcessInfo{Name: "sshd"}, Message: "failed"}
+	infoLine := types.ParsedLine{LogLevel: "info", Process: types.ProcessInfo{Name: "sshd"}, Message: "accepted"}
+
+	tests := []struct {
+		name   string
+		routes func(t *testing.T) []config.RouteCfg
+		line   types.ParsedLine
+		want   []bool // alerts, archive, stdout
+		lines  []uint64
+	}{
+		{
+			name:   "no routes",
+			routes: func(t *testing.T) []config.RouteCfg { return nil },
+			line:   errLine,
+			want:   []bool{true, true, true},
+			lines:  []uint64{},
+		},
+		{
+			name: "unrouted outputs get every line",
+			routes: func(t *testing.T) []config.RouteCfg {
+				return []config.RouteCfg{
+					{Name: "errors", Match: mustParse(t, "level >= err"), Outputs: []string{"alerts"}},
+				}
+			},
+			line:  infoLine,
+			want:  []bool{false, true, true},
+			lines: []uint64{0},
+		},
+		{
+			name: "first match wins",
+			routes: func(t *testing.T) []config.RouteCfg {
+				return []config.RouteCfg{
+					{Name: "errors", Match: mustParse(t, "level >= err"), Outputs: []string{"alerts"}},
+					{Name: "sshd", Match: mustParse(t, "proc == sshd"), Outputs: []string{"archive"}},
+				}
+			},
+			line:  errLine,
+			want:  []bool{true, false, true},
+			lines: []uint64{1, 0},
+		},
+		{
+			name: "falls through to a later route",
+			routes: func(t *testing.T) []config.RouteCfg {
+				return []config.RouteCfg{
+					{Name: "errors", Match: mustParse(t, "level >= err"), Outputs: []string{"alerts"}},
+					{Name: "default", Outputs: []string{"archive"}},
+				}
+			},
+			line:  infoLine,
+			want:  []bool{false, true, true},
+			lines: []uint64{0, 1},
+		},
+		{
+			name: "continue",
+			routes: func(t *testing.T) []config.RouteCfg {
+				return []config.RouteCfg{
+					{Name: "errors", Match: mustParse(t, "level >= err"), Outputs: []string{"alerts"}, Continue: true},
+					{Name: "sshd", Match: mustParse(t, "proc == sshd"), Outputs: []string{"archive"}},
+					{Name: "default", Outputs: []string{"stdout"}},
+				}
+			},
+			line:  errLine,
+			want:  []bool{true, true, false},
+			lines: []uint64{1, 1, 0},
+		},
+		{
+			name: "no match",
+			routes: func(t *testing.T) []config.RouteCfg {
+				return []config.RouteCfg{
+					{Name: "errors", Match: mustParse(t, "level >= err"), Outputs: []string{"alerts"}},
+					{Name: "kernel", Match: mustParse(t, "proc == kernel"), Outputs: []string{"archive", "stdout"}},
+				}
+			},
+			line:  infoLine,
+			want:  []bool{false, false, false},
+			lines: []uint64{0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(tt.routes(t), outputs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			send := []bool{true, true, true}
+			r.Route(tt.line, send)
+			if !reflect.DeepEqual(send, tt.want) {
+				t.Errorf("send = %v, want %v", send, tt.want)
+			}
+
+			lines := []uint64{}
+			for _, stats := range r.Stats() {
+				lines = append(lines, stats.Lines)
+			}
+			if !reflect.DeepEqual(lines, tt.lines) {
+				t.Errorf("route lines = %v, want %v", lines, tt.lines)
+			}
+		})
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	outputs := []string{"alerts", "archive"}
+
+	tests := []struct {
+		name    string
+		routes  []config.RouteCfg
+		outputs []string
+		wantErr string
+	}{
+		{
+			name:    "duplicate output",
+			outputs: []string{"seq", "alerts", "seq"},
+			wantErr: `duplicate output name: "seq"`,
+		},
+		{
+			name: "unknown output",
+			routes: []config.RouteCfg{
+				{Name: "errors", Outputs: []string{"alert"}},
+			},
+			wantErr: `route "errors" has an unknown output: "alert"`,
+		},
+		{
+			name: "duplicate name",
+			routes: []config.RouteCfg{
+				{Name: "errors", Outputs: []string{"alerts"}},
+				{Name: "errors", Outputs: []string{"archive"}},
+			},
+			wantErr: `duplicate route name: "errors"`,
+		},
+		{
+			name: "duplicate generated name",
+			routes: []config.RouteCfg{
+				{Name: "route-1", Outputs: []string{"alerts"}},
+				{Outputs: []string{"archive"}},
+			},
+			wantErr: `duplicate route name: "route-1"`,
+		},
+		{
+			name: "unnamed routes",
+			routes: []config.RouteCfg{
+				{Outputs: []string{"alerts"}},
+				{Outputs: []string{"archive"}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names := outputs
+			if tt.outputs != nil {
+				names = tt.outputs
+			}
+			_, err := New(tt.routes, names)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
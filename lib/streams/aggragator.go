@@ -3,21 +3,46 @@ package streams
 import (
 	"context"
 	"sync"
+	"time"
+
+	"github.com/erobsham/reform/lib/parser"
+	"github.com/erobsham/reform/lib/types"
 )
 
+// in ordered mode, past this many buffered lines the oldest are let go early
+const orderedBufferLimit = 10000
+
 func NewStreamAggregator(ctx context.Context, streams []InputStream) *StreamAggregator {
+	return newStreamAggregator(ctx, streams, 0)
+}
+
+// NewOrderedStreamAggregator merges the streams by timestamp, rather than in
+// the order their lines arrive.  each line is held for up to `delay` (the
+// watermark) waiting for earlier lines from the other streams.
+func NewOrderedStreamAggregator(ctx context.Context, streams []InputStream, delay time.Duration) *StreamAggregator {
+	return newStreamAggregator(ctx, streams, delay)
+}
+
+func newStreamAggregator(ctx context.Context, streams []InputStream, delay time.Duration) *StreamAggregator {
 	newCtx, cancelFn := context.WithCancel(ctx)
 
 	a := &StreamAggregator{
 		ctx:        newCtx,
 		cancelFunc: cancelFn,
 		wg:         sync.WaitGroup{},
-		output:     make(chan string),
+		streams:    streams,
+		output:     make(chan SourceLine),
+		closed:     make(chan struct{}),
+		delay:      delay,
+	}
+	if delay > 0 {
+		a.merge = make(chan orderedLine)
+		go a.mergeLoop(newCtx)
 	}
 
 	a.wg.Add(len(streams))
-	for _, stream := range streams {
-		go a.pullInput(newCtx, stream)
+	for idx, stream := range streams {
+		go a.pullInput(newCtx, idx, stream)
 	}
 
 	go a.awaitDone()
@@ -30,10 +55,25 @@ type StreamAggregator struct {
 	cancelFunc context.CancelFunc
 	wg         sync.WaitGroup
 
-	output chan string
+	streams []InputStream
+	output  chan SourceLine
+	// closed by `Close`, once nothing is reading `output` anymore
+	closed chan struct{}
+
+	// only set in ordered mode, lines go through `mergeLoop` on their way to `output`
+	merge chan orderedLine
+	delay time.Duration
 }
 
-func (a *StreamAggregator) Next() (string, error) {
+// a line read from one of the aggregated input streams.
+type SourceLine struct {
+	Source string
+	Line   string
+	// `Line` parsed, with its `Source` and `Raw` text set
+	Parsed types.ParsedLine
+}
+
+func (a *StreamAggregator) Next() (SourceLine, error) {
 	str, ok := <-a.output
 	if !ok {
 		return str, ErrStreamClosed
@@ -42,28 +82,223 @@ func (a *StreamAggregator) Next() (string, error) {
 	}
 }
 
+// NextTimeout is `Next`, but gives up with `ErrNextTimeout` if no line arrives
+// within `timeout` (ie so the caller can do periodic work between lines).
+func (a *StreamAggregator) NextTimeout(timeout time.Duration) (SourceLine, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case str, ok := <-a.output:
+		if !ok {
+			return str, ErrStreamClosed
+		}
+		return str, nil
+	case <-timer.C:
+		return SourceLine{}, ErrNextTimeout
+	}
+}
+
+// Close stops all the input streams, blocking until each of them has
+// finished shutting down.
 func (a *StreamAggregator) Close() {
+	select {
+	case <-a.closed:
+	default:
+		close(a.closed)
+	}
 	a.cancelFunc()
+
+	wg := sync.WaitGroup{}
+	for _, stream := range a.streams {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stream.Close()
+		}()
+	}
+	wg.Wait()
+
 	a.wg.Wait()
 }
 
 func (a *StreamAggregator) awaitDone() {
 	a.wg.Wait()
-	close(a.output)
+	if a.merge != nil {
+		// `mergeLoop` closes the output once it's let go of everything
+		close(a.merge)
+	} else {
+		close(a.output)
+	}
 }
 
-func (a *StreamAggregator) pullInput(ctx context.Context, stream InputStream) {
+func (a *StreamAggregator) pullInput(ctx context.Context, idx int, stream InputStream) {
 	defer a.wg.Done()
+
+	var last time.Time
 	for {
 		val, err := stream.Next()
 		if err != nil {
+			if a.merge != nil {
+				select {
+				case <-ctx.Done():
+				case a.merge <- orderedLine{stream: idx, closed: true}:
+				}
+			}
 			return
 		}
 
+		line := SourceLine{Source: stream.Name(), Line: val, Parsed: parser.ParseLine(val)}
+		line.Parsed.Source = line.Source
+		line.Parsed.Raw = val
+
+		if a.merge == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case a.output <- line:
+			}
+			continue
+		}
+
+		// lines without a timestamp stay with the line before them
+		if !line.Parsed.Timestamp.IsZero() {
+			last = line.Parsed.Timestamp
+		}
 		select {
 		case <-ctx.Done():
 			return
-		case a.output <- val:
+		case a.merge <- orderedLine{stream: idx, line: line, timestamp: last, arrived: time.Now()}:
 		}
 	}
 }
+
+//#< Ordered Merge
+
+type orderedLine struct {
+	stream int
+	line   SourceLine
+
+	// what it's ordered by
+	timestamp time.Time
+	arrived   time.Time
+
+	// the stream has finished, there's no line
+	closed bool
+}
+
+// a k-way merge of the streams: the earliest waiting line is let go once every
+// open stream has a line waiting (so nothing earlier can still arrive), or once
+// it's waited for the watermark `delay`.  when `ctx` is done, everything still
+// waiting is let go straight away.
+func (a *StreamAggregator) mergeLoop(ctx context.Context) {
+	defer close(a.output)
+
+	queues := make([][]orderedLine, len(a.streams))
+	open := make([]bool, len(a.streams))
+	for i := range open {
+		open[i] = true
+	}
+	buffered := 0
+
+	input := a.merge
+	timer := time.NewTimer(a.delay)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		// let go of everything that's ready
+		var head *orderedLine
+		for {
+			earliest := -1
+			waiting := false
+			for i, queue := range queues {
+				if len(queue) == 0 {
+					waiting = waiting || open[i]
+					continue
+				}
+				if earliest < 0 || queue[0].timestamp.Before(queues[earliest][0].timestamp) {
+					earliest = i
+				}
+			}
+			if earliest < 0 {
+				head = nil
+				break
+			}
+
+			head = &queues[earliest][0]
+			if waiting && buffered <= orderedBufferLimit && time.Since(head.arrived) < a.delay {
+				break
+			}
+
+			select {
+			case <-ctx.Done():
+				a.drainOrdered(queues)
+				return
+			case a.output <- head.line:
+			}
+			queues[earliest] = queues[earliest][1:]
+			buffered -= 1
+		}
+
+		if input == nil && head == nil {
+			return
+		}
+
+		// wait for more lines, or for the earliest line's watermark
+		var timeout <-chan time.Time
+		if head != nil {
+			timer.Reset(time.Until(head.arrived.Add(a.delay)))
+			timeout = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			a.drainOrdered(queues)
+			return
+		case item, ok := <-input:
+			if !ok {
+				// every stream has finished
+				input = nil
+				clear(open)
+				break
+			}
+			if item.closed {
+				open[item.stream] = false
+				break
+			}
+			queues[item.stream] = append(queues[item.stream], item)
+			buffered += 1
+		case <-timeout:
+		}
+		timer.Stop()
+	}
+}
+
+// lets go of every queued line, earliest first, unless the aggregator is closed
+// (ie nothing is reading them anymore).
+func (a *StreamAggregator) drainOrdered(queues [][]orderedLine) {
+	for {
+		earliest := -1
+		for i, queue := range queues {
+			if len(queue) == 0 {
+				continue
+			}
+			if earliest < 0 || queue[0].timestamp.Before(queues[earliest][0].timestamp) {
+				earliest = i
+			}
+		}
+		if earliest < 0 {
+			return
+		}
+
+		select {
+		case <-a.closed:
+			return
+		case a.output <- queues[earliest][0].line:
+		}
+		queues[earliest] = queues[earliest][1:]
+	}
+}
+
+//#> Ordered Merge
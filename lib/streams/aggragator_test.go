@@ -0,0 +1,165 @@
+package streams
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// an input stream fed from a channel, which closes along with it
+type chanStream struct {
+	name  string
+	lines chan string
+	done  chan struct{}
+}
+
+func newChanStream(name string, lines ...string) *chanStream {
+	s := &chanStream{name: name, lines: make(chan string, len(lines)), done: make(chan struct{})}
+	for _, line := range lines {
+		s.lines <- line
+	}
+	return s
+}
+
+func (s *chanStream) Name() string { return s.name }
+
+func (s *chanStream) Next() (string, error) {
+	select {
+	case line, ok := <-s.lines:
+		if !ok {
+			return "", ErrStreamClosed
+		}
+		return line, nil
+	case <-s.done:
+		return "", ErrStreamClosed
+	}
+}
+
+func (s *chanStream) Close() {
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+}
+
+func collectMessages(t *testing.T, a *StreamAggregator, n int) []string {
+	t.Helper()
+	got := []string{}
+	for range n {
+		line, err := a.NextTimeout(time.Second * 5)
+		if err != nil {
+			t.Fatalf("after %v: %v", got, err)
+		}
+		got = append(got, line.Source+": "+line.Parsed.Message)
+	}
+	return got
+}
+
+func TestOrderedStreamAggregator_Merge(t *testing.T) {
+	web := newChanStream("web",
+		"Mar  4 05:06:01 hst-web001 nginx[10]: request one",
+		"Mar  4 05:06:04 hst-web001 nginx[10]: request two",
+		"Mar  4 05:06:05 hst-web001 nginx[10]: request three",
+	)
+	db := newChanStream("db",
+		"Mar  4 05:06:02 hst-db0001 postgres[20]: query one",
+		"Mar  4 05:06:03 hst-db0001 postgres[20]: query two",
+		"Mar  4 05:06:06 hst-db0001 postgres[20]: query three",
+	)
+	close(web.lines)
+	close(db.lines)
+
+	// a long watermark, only having a line from every source lets them go
+	a := NewOrderedStreamAggregator(context.Background(), []InputStream{web, db}, time.Hour)
+	defer a.Close()
+
+	got := collectMessages(t, a, 6)
+	want := []string{
+		"web: request one",
+		"db: query one",
+		"db: query two",
+		"web: request two",
+		"web: request three",
+		"db: query three",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got vs want:\n  %v\n  %v", got, want)
+	}
+
+	if _, err := a.Next(); err != ErrStreamClosed {
+		t.Errorf("got %v, want ErrStreamClosed", err)
+	}
+}
+
+func TestOrderedStreamAggregator_Watermark(t *testing.T) {
+	web := newChanStream("web",
+		"Mar  4 05:06:02 hst-web001 nginx[10]: request one",
+		"Mar  4 05:06:01 hst-web001 nginx[10]: request zero",
+	)
+	// nothing arrives from the db until after the watermark
+	db := newChanStream("db")
+
+	delay := time.Millisecond * 100
+	a := NewOrderedStreamAggregator(context.Background(), []InputStream{web, db}, delay)
+	defer a.Close()
+
+	start := time.Now()
+	got := collectMessages(t, a, 2)
+	if elapsed := time.Since(start); elapsed < delay {
+		t.Errorf("lines let go after %s, before the %s watermark", elapsed, delay)
+	}
+	// the order within a source is kept, even when it's out of order
+	if want := []string{"web: request one", "web: request zero"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got vs want:\n  %v\n  %v", got, want)
+	}
+
+	// lines without a timestamp keep to the line before them
+	db.lines <- "Mar  4 05:06:03 hst-db0001 postgres[20]: query one"
+	db.lines <- "  continued without a timestamp"
+	web.lines <- "Mar  4 05:06:04 hst-web001 nginx[10]: request two"
+	close(db.lines)
+	close(web.lines)
+
+	got = collectMessages(t, a, 3)
+	if got[0] != "db: query one" || got[2] != "web: request two" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestOrderedStreamAggregator_DrainOnCancel(t *testing.T) {
+	web := newChanStream("web",
+		"Mar  4 05:06:02 hst-web001 nginx[10]: request one",
+		"Mar  4 05:06:04 hst-web001 nginx[10]: request two",
+	)
+	db := newChanStream("db",
+		"Mar  4 05:06:01 hst-db0001 postgres[20]: query one",
+		"Mar  4 05:06:03 hst-db0001 postgres[20]: query two",
+	)
+	// never sends anything, so the lines above are held for the watermark
+	idle := newChanStream("idle")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	a := NewOrderedStreamAggregator(ctx, []InputStream{web, db, idle}, time.Hour)
+	defer a.Close()
+
+	// give the lines time to reach the merge, then shut down (ie on a signal)
+	time.Sleep(time.Millisecond * 100)
+	cancel()
+
+	got := collectMessages(t, a, 4)
+	want := []string{
+		"db: query one",
+		"web: request one",
+		"db: query two",
+		"web: request two",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got vs want:\n  %v\n  %v", got, want)
+	}
+
+	if _, err := a.NextTimeout(time.Second * 5); err != ErrStreamClosed {
+		t.Errorf("got %v, want ErrStreamClosed", err)
+	}
+}
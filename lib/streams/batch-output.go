@@ -0,0 +1,170 @@
+package streams
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/erobsham/reform/lib/types"
+)
+
+//#< Batch Output
+
+// batchOutput collects lines for the outputs which send them off in bulk,
+// handing a batch to `send` once it's full, or has waited long enough.
+//
+// `send` (and `drain`, called once the last batch has been sent on close, and
+// `poll`) are only ever called from the runloop, so they don't need to be safe
+// for concurrent use.  `send` reports how each batch went with `sent` / `failed`.
+type batchOutput struct {
+	deliveryReporter
+
+	ctx        context.Context
+	cancelFunc context.CancelFunc
+
+	maxEvents int
+	wait      time.Duration
+	send      func(lines []types.ParsedLine)
+	drain     func()
+
+	// called every `pollEvery` after a batch is sent, for as long as it
+	// returns true (ie there's still something to check on)
+	poll      func() (pending bool)
+	pollEvery time.Duration
+
+	lock    sync.RWMutex
+	closed  bool
+	logChan chan types.ParsedLine
+	done    chan struct{}
+}
+
+func newBatchOutput(ctx context.Context, maxEvents int, wait time.Duration, send func(lines []types.ParsedLine), drain func()) *batchOutput {
+	return newPollingBatchOutput(ctx, maxEvents, wait, send, drain, 0, nil)
+}
+
+// newPollingBatchOutput is `newBatchOutput`, which also calls `poll` between
+// batches, ie to follow up on the batches already sent when no more arrive.
+func newPollingBatchOutput(ctx context.Context, maxEvents int, wait time.Duration, send func(lines []types.ParsedLine), drain func(), pollEvery time.Duration, poll func() bool) *batchOutput {
+	ctx, cancelFn := context.WithCancel(ctx)
+	b := &batchOutput{
+		ctx:        ctx,
+		cancelFunc: cancelFn,
+
+		maxEvents: maxEvents,
+		wait:      wait,
+		send:      send,
+		drain:     drain,
+		poll:      poll,
+		pollEvery: pollEvery,
+
+		logChan: make(chan types.ParsedLine, maxEvents),
+		done:    make(chan struct{}),
+	}
+
+	go b.runloop()
+
+	return b
+}
+
+func (b *batchOutput) Output(line types.ParsedLine) error {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	if b.closed {
+		return ErrStreamClosed
+	}
+
+	select {
+	case <-b.ctx.Done():
+		return ErrStreamClosed
+	case b.logChan <- line:
+		return nil
+	}
+}
+
+// Close blocks until all the pending lines have been sent.
+func (b *batchOutput) Close() {
+	b.lock.Lock()
+	if b.closed {
+		b.lock.Unlock()
+		return
+	}
+	b.closed = true
+	close(b.logChan)
+	b.lock.Unlock()
+
+	<-b.done
+	b.cancelFunc()
+}
+
+func (b *batchOutput) runloop() {
+	defer close(b.done)
+
+	// nil until a batch is sent, and while there's nothing to poll
+	var pollTimer *time.Timer
+	var pollC <-chan time.Time
+	startPolling := func() {
+		if b.poll == nil || pollC != nil {
+			return
+		}
+		if pollTimer == nil {
+			pollTimer = time.NewTimer(b.pollEvery)
+		} else {
+			pollTimer.Reset(b.pollEvery)
+		}
+		pollC = pollTimer.C
+	}
+	defer func() {
+		if pollTimer != nil {
+			pollTimer.Stop()
+		}
+	}()
+
+	batch := make([]types.ParsedLine, 0, b.maxEvents)
+	flush := func() {
+		if len(batch) > 0 {
+			b.send(batch)
+			batch = make([]types.ParsedLine, 0, b.maxEvents)
+			startPolling()
+		}
+	}
+
+	timer := time.NewTimer(b.wait)
+	timer.Stop()
+
+	for {
+		select {
+		case <-b.ctx.Done():
+			return
+
+		case line, ok := <-b.logChan:
+			if !ok {
+				flush()
+				if b.drain != nil {
+					b.drain()
+				}
+				return
+			}
+
+			if len(batch) == 0 {
+				timer.Reset(b.wait)
+			}
+			batch = append(batch, line)
+			if len(batch) >= b.maxEvents {
+				timer.Stop()
+				flush()
+			}
+
+		case <-timer.C:
+			flush()
+
+		case <-pollC:
+			pollC = nil
+			if b.poll() {
+				startPolling()
+			}
+		}
+	}
+}
+
+//#> Batch Output
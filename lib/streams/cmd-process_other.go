@@ -0,0 +1,19 @@
+//go:build !unix
+
+package streams
+
+import (
+	"os/exec"
+	"time"
+)
+
+// process groups aren't available here, so fall back to the default
+// `exec.CommandContext` behavior of killing the process on cancel.
+func setupProcessGroup(cmd *exec.Cmd, gracePeriod time.Duration, done <-chan struct{}) {}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	cmd.Process.Kill()
+}
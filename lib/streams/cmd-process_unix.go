@@ -0,0 +1,48 @@
+//go:build unix
+
+package streams
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// run the command in its own process group, so on cancel we can signal
+// everything it spawned (ie `ssh` + friends) rather than just the leader.
+func setupProcessGroup(cmd *exec.Cmd, gracePeriod time.Duration, done <-chan struct{}) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		err := signalProcessGroup(cmd, syscall.SIGTERM)
+
+		// escalate if anything in the group ignores the SIGTERM
+		time.AfterFunc(gracePeriod, func() {
+			select {
+			case <-done:
+				// already reaped, the pgid may have been reused
+			default:
+				killProcessGroup(cmd)
+			}
+		})
+
+		return err
+	}
+}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	signalProcessGroup(cmd, syscall.SIGKILL)
+}
+
+func signalProcessGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	if cmd.Process == nil {
+		return nil
+	}
+
+	err := syscall.Kill(-cmd.Process.Pid, sig)
+	if errors.Is(err, syscall.ESRCH) {
+		// nothing left in the group
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,78 @@
+//go:build unix
+
+package streams
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCmdStream_CloseKillsProcessGroup(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "child.pid")
+
+	// spawn a background child that ignores SIGTERM, so only the
+	// process group SIGKILL escalation can clean it up.
+	script := `trap "" TERM; (trap "" TERM; sleep 60) & echo $! > ` + pidFile + `; echo "Jun 12 08:24:46 first line"; echo "Jun 12 08:24:47 second line"; sleep 60`
+
+	s := NewCmdStream(context.Background(), "test", "sh", "-c", script)
+
+	line, err := s.Next()
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if line != "Jun 12 08:24:46 first line" {
+		t.Errorf("Next() got = %q, want %q", line, "Jun 12 08:24:46 first line")
+	}
+
+	data, err := os.ReadFile(pidFile)
+	if err != nil {
+		t.Fatalf("unable to read child pid: %v", err)
+	}
+	childPid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		t.Fatalf("unable to parse child pid: %v", err)
+	}
+
+	start := time.Now()
+	s.Close()
+	if elapsed := time.Since(start); elapsed > cmdStopGracePeriod*2 {
+		t.Errorf("Close() took %v, expected less than %v", elapsed, cmdStopGracePeriod*2)
+	}
+
+	if s.ExitErr() == nil {
+		t.Errorf("ExitErr() expected an error for a killed command")
+	}
+
+	// the child gets reparented on exit, so give it a moment to be reaped
+	deadline := time.Now().Add(time.Second * 2)
+	for processAlive(childPid) {
+		if time.Now().After(deadline) {
+			t.Fatalf("child process %d still running after Close()", childPid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := s.Next(); err != ErrStreamClosed {
+		t.Errorf("Next() after Close() error = %v, want %v", err, ErrStreamClosed)
+	}
+}
+
+func processAlive(pid int) bool {
+	if syscall.Kill(pid, 0) != nil {
+		return false
+	}
+
+	// a killed, but not yet reaped process still 'exists'
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return true
+	}
+	fields := strings.Fields(string(stat))
+	return len(fields) < 3 || fields[2] != "Z"
+}
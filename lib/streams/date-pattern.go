@@ -0,0 +1,65 @@
+package streams
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fills in strftime style placeholders (ie `logs-%Y.%m.%d`) from `t`:
+//
+//	%Y year, %y 2 digit year, %m month, %d day, %j day of the year,
+//	%H hour, %M minute, %S second, %% literal '%'
+//
+// anything else is left as-is.
+func formatDatePattern(pattern string, t time.Time) string {
+	if !strings.Contains(pattern, "%") {
+		return pattern
+	}
+
+	b := strings.Builder{}
+	b.Grow(len(pattern) + 8)
+
+	pad := func(v int, width int) {
+		s := strconv.Itoa(v)
+		for range width - len(s) {
+			b.WriteByte('0')
+		}
+		b.WriteString(s)
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c != '%' || i == len(pattern)-1 {
+			b.WriteByte(c)
+			continue
+		}
+
+		i += 1
+		switch pattern[i] {
+		case 'Y':
+			pad(t.Year(), 4)
+		case 'y':
+			pad(t.Year()%100, 2)
+		case 'm':
+			pad(int(t.Month()), 2)
+		case 'd':
+			pad(t.Day(), 2)
+		case 'j':
+			pad(t.YearDay(), 3)
+		case 'H':
+			pad(t.Hour(), 2)
+		case 'M':
+			pad(t.Minute(), 2)
+		case 'S':
+			pad(t.Second(), 2)
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(pattern[i])
+		}
+	}
+
+	return b.String()
+}
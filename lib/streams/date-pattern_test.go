@@ -0,0 +1,49 @@
+package streams
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_formatDatePattern(t *testing.T) {
+	ts := time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		pattern string
+		want    string
+	}{
+		{
+			name:    "no placeholders",
+			pattern: "logs",
+			want:    "logs",
+		},
+		{
+			name:    "daily",
+			pattern: "logs-%Y.%m.%d",
+			want:    "logs-2025.03.04",
+		},
+		{
+			name:    "hourly",
+			pattern: "logs-%y%m%d-%H%M%S",
+			want:    "logs-250304-050607",
+		},
+		{
+			name:    "day of year",
+			pattern: "logs-%j",
+			want:    "logs-063",
+		},
+		{
+			name:    "escapes and unknowns",
+			pattern: "100%%-%q-%",
+			want:    "100%-%q-%",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatDatePattern(tt.pattern, ts); got != tt.want {
+				t.Errorf("formatDatePattern() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
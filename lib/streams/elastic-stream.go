@@ -0,0 +1,280 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/log"
+	"github.com/erobsham/reform/lib/types"
+)
+
+//#< Elasticsearch Stream
+
+// ElasticStream writes lines to Elasticsearch (or OpenSearch) through the
+// `_bulk` api, one document per line.
+type ElasticStream struct {
+	*batchOutput
+
+	client *http.Client
+	cfg    config.OutputElasticCfg
+	log    *slog.Logger
+
+	// reused across batches, only touched from the runloop
+	bodyBuf bytes.Buffer
+}
+
+func NewElasticStream(ctx context.Context, cfg config.OutputElasticCfg) (*ElasticStream, error) {
+	cfg = cfg.WithDefaults()
+
+	client, err := newHTTPClient(cfg.TLS, cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &ElasticStream{
+		client: client,
+		cfg:    cfg,
+		log:    log.Default().With(slog.String("url", cfg.URL)),
+	}
+	s.batchOutput = newBatchOutput(ctx, cfg.MaxBatchEvents, cfg.BatchWait, s.sendLines, nil)
+
+	return s, nil
+}
+
+// some of a bulk request's documents failed, but can be retried
+const errBulkItemsFailed StreamError = "documents failed with a retriable status"
+
+// a single document's `action\ndocument\n` pair, and the line it's from
+type bulkItem struct {
+	body []byte
+	line types.ParsedLine
+}
+
+func (s *ElasticStream) sendLines(lines []types.ParsedLine) {
+	items := make([]bulkItem, 0, len(lines))
+	for _, line := range lines {
+		body, err := s.encodeItem(line)
+		if err != nil {
+			s.log.
+				Error("unable to encode log for Elasticsearch",
+					slog.String("error", err.Error()),
+				)
+			s.failed([]types.ParsedLine{line}, err)
+			continue
+		}
+		items = append(items, bulkItem{body: body, line: line})
+	}
+
+	sent := 0
+	defer func() {
+		s.delivered(Delivery{Sent: sent})
+	}()
+
+	// one backoff schedule for the whole batch: failed requests are retried,
+	// and so are the documents rejected with a retriable status (ie `429`
+	// when the cluster is overloaded), only resending those
+	policy := retryPolicy{
+		MaxRetries: s.cfg.MaxRetries,
+		Backoff:    s.cfg.Backoff,
+		MaxBackoff: s.cfg.MaxBackoff,
+		IsRetriable: func(err error) bool {
+			return err == errBulkItemsFailed || isRetriableHTTPError(err)
+		},
+	}
+	err := retryWithBackoff(s.ctx, s.log, policy, func() error {
+		if len(items) == 0 {
+			return nil
+		}
+		retry, rejected, rejectErr, err := s.bulk(items)
+		if err != nil {
+			return err
+		}
+
+		sent += len(items) - len(retry) - len(rejected)
+		if len(rejected) > 0 {
+			s.failed(bulkItemLines(rejected), rejectErr)
+		}
+		items = retry
+		if len(retry) > 0 {
+			return errBulkItemsFailed
+		}
+		return nil
+	})
+	switch {
+	case err == nil:
+	case s.ctx.Err() != nil:
+		s.failed(bulkItemLines(items), ErrStreamClosed)
+	case err == errBulkItemsFailed:
+		s.log.
+			Error("documents still failing after retries, dropping",
+				slog.Int("numDropped", len(items)),
+			)
+		s.failed(bulkItemLines(items), ErrRetriesExhausted)
+	default:
+		s.log.
+			Error("error sending logs to Elasticsearch, dropping",
+				slog.Int("numDropped", len(items)),
+				slog.String("error", err.Error()),
+			)
+		s.failed(bulkItemLines(items), err)
+	}
+
+	if sent > 0 {
+		s.log.Info("sent logs",
+			slog.Int("numSent", sent),
+		)
+	}
+}
+
+func bulkItemLines(items []bulkItem) []types.ParsedLine {
+	lines := make([]types.ParsedLine, len(items))
+	for i, item := range items {
+		lines[i] = item.line
+	}
+	return lines
+}
+
+func (s *ElasticStream) encodeItem(line types.ParsedLine) ([]byte, error) {
+	ts := line.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	action := map[string]map[string]string{
+		// `create` rather than `index`, so it also works with data streams
+		"create": {"_index": formatDatePattern(s.cfg.Index, ts.UTC())},
+	}
+	item, err := json.Marshal(action)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc []byte
+	if s.cfg.ECS {
+		doc, err = json.Marshal(line.ECS())
+	} else {
+		doc, err = json.Marshal(line)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	item = append(item, '\n')
+	item = append(item, doc...)
+	item = append(item, '\n')
+	return item, nil
+}
+
+type bulkResponse struct {
+	Errors bool                        `json:"errors"`
+	Items  []map[string]bulkItemResult `json:"items"`
+}
+
+type bulkItemResult struct {
+	Status int `json:"status"`
+	Error  *struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+}
+
+// sends the items, returning the ones which failed, but can be retried, and
+// the ones which were rejected outright (ie mapping errors) with the first
+// rejection as `rejectErr`.
+func (s *ElasticStream) bulk(items []bulkItem) (retry []bulkItem, rejected []bulkItem, rejectErr error, err error) {
+	s.bodyBuf.Reset()
+	for _, item := range items {
+		s.bodyBuf.Write(item.body)
+	}
+
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.url(), bytes.NewReader(s.bodyBuf.Bytes()))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if s.cfg.APIKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+s.cfg.APIKey)
+	} else if s.cfg.Username != "" || s.cfg.Password != "" {
+		req.SetBasicAuth(s.cfg.Username, s.cfg.Password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, nil, nil, HTTPResponseError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			Body:       string(body),
+		}
+	}
+
+	var result bulkResponse
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	io.Copy(io.Discard, resp.Body)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("unable to parse bulk response: %w", err)
+	}
+	if !result.Errors {
+		return nil, nil, nil, nil
+	}
+	if len(result.Items) != len(items) {
+		return nil, nil, nil, fmt.Errorf("bulk response has %d items, expected %d", len(result.Items), len(items))
+	}
+
+	var firstRejection *bulkItemResult
+	for i, itemResult := range result.Items {
+		for _, r := range itemResult {
+			switch {
+			case r.Status >= 200 && r.Status <= 299:
+			case isRetriableHTTPStatus(r.Status):
+				retry = append(retry, items[i])
+			default:
+				rejected = append(rejected, items[i])
+				if firstRejection == nil {
+					firstRejection = &r
+				}
+			}
+		}
+	}
+
+	if len(rejected) > 0 {
+		rejectErr = fmt.Errorf("rejected with status %d", firstRejection.Status)
+		attrs := []any{
+			slog.Int("numDropped", len(rejected)),
+			slog.Int("status", firstRejection.Status),
+		}
+		if firstRejection.Error != nil {
+			attrs = append(attrs,
+				slog.String("type", firstRejection.Error.Type),
+				slog.String("reason", firstRejection.Error.Reason),
+			)
+			rejectErr = fmt.Errorf("rejected with status %d: %s: %s", firstRejection.Status, firstRejection.Error.Type, firstRejection.Error.Reason)
+		}
+		s.log.Error("Elasticsearch rejected documents, dropping", attrs...)
+	}
+
+	return retry, rejected, rejectErr, nil
+}
+
+func (s *ElasticStream) url() string {
+	// trim the response down to just what's needed to find failed items
+	return s.cfg.URL + "/_bulk?filter_path=errors,items.*.status,items.*.error"
+}
+
+//#> Elasticsearch Stream
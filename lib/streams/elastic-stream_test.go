@@ -0,0 +1,249 @@
+package streams
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/types"
+)
+
+// a stub `_bulk` endpoint. documents with a message containing "flaky" are
+// rejected with a 429 the first time they're seen, ones containing
+// "overloaded" every time, and ones containing "bad" are always rejected
+// with a 400.
+type stubElasticServer struct {
+	*httptest.Server
+
+	// every n'th request fails outright with a 503, when set
+	unavailableEvery int
+
+	lock     sync.Mutex
+	requests int
+	seen     map[string]int
+	indexed  map[string][]string // index -> messages
+	docs     []map[string]any
+	auth     []string
+}
+
+func newStubElasticServer() *stubElasticServer {
+	s := &stubElasticServer{seen: map[string]int{}, indexed: map[string][]string{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *stubElasticServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.requests += 1
+	s.auth = append(s.auth, r.Header.Get("Authorization"))
+	if s.unavailableEvery > 0 && s.requests%s.unavailableEvery == 0 {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	items := []map[string]any{}
+	errors := false
+
+	scanner := bufio.NewScanner(r.Body)
+	for scanner.Scan() {
+		var action map[string]map[string]string
+		json.Unmarshal(scanner.Bytes(), &action)
+		scanner.Scan()
+		var doc map[string]any
+		json.Unmarshal(scanner.Bytes(), &doc)
+
+		msg, _ := doc["@m"].(string)
+		if ecsMsg, ok := doc["message"].(string); ok {
+			msg = ecsMsg
+		}
+		s.seen[msg] += 1
+
+		status := http.StatusCreated
+		switch {
+		case strings.Contains(msg, "flaky") && s.seen[msg] == 1,
+			strings.Contains(msg, "overloaded"):
+			status = http.StatusTooManyRequests
+		case strings.Contains(msg, "bad"):
+			status = http.StatusBadRequest
+		default:
+			index := action["create"]["_index"]
+			s.indexed[index] = append(s.indexed[index], msg)
+			s.docs = append(s.docs, doc)
+		}
+		if status != http.StatusCreated {
+			errors = true
+		}
+
+		result := map[string]any{"status": status}
+		if status != http.StatusCreated {
+			result["error"] = map[string]string{"type": "test_exception", "reason": fmt.Sprint(status)}
+		}
+		items = append(items, map[string]any{"create": result})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"errors": errors, "items": items})
+}
+
+func TestElasticStream_Bulk(t *testing.T) {
+	day1 := time.Date(2025, 3, 4, 23, 0, 0, 0, time.UTC)
+	day2 := day1.Add(time.Hour * 2)
+
+	server := newStubElasticServer()
+	defer server.Close()
+
+	s, err := NewElasticStream(t.Context(), config.OutputElasticCfg{
+		HTTPOutputCfg: config.HTTPOutputCfg{
+			URL:     server.URL,
+			Backoff: time.Millisecond,
+		},
+		Index:  "logs-%Y.%m.%d",
+		APIKey: "key",
+	})
+	if err != nil {
+		t.Fatalf("NewElasticStream() error = %v", err)
+	}
+
+	sent := 0
+	failed := []string{}
+	s.OnDelivery(func(d Delivery) {
+		sent += d.Sent
+		for _, line := range d.Failed {
+			failed = append(failed, line.Message)
+		}
+	})
+
+	s.Output(types.ParsedLine{Timestamp: day1, Message: "one"})
+	s.Output(types.ParsedLine{Timestamp: day1, Message: "flaky"})
+	s.Output(types.ParsedLine{Timestamp: day1, Message: "bad"})
+	s.Output(types.ParsedLine{Timestamp: day2, Message: "two"})
+	s.Close()
+
+	wantIndexed := map[string][]string{
+		"logs-2025.03.04": {"one", "flaky"},
+		"logs-2025.03.05": {"two"},
+	}
+	if !reflect.DeepEqual(server.indexed, wantIndexed) {
+		t.Errorf("indexed got = %v, want %v", server.indexed, wantIndexed)
+	}
+
+	// only the flaky document should've been resent
+	wantSeen := map[string]int{"one": 1, "flaky": 2, "bad": 1, "two": 1}
+	if !reflect.DeepEqual(server.seen, wantSeen) {
+		t.Errorf("seen got = %v, want %v", server.seen, wantSeen)
+	}
+	if server.requests != 2 {
+		t.Errorf("got %v requests, want %v", server.requests, 2)
+	}
+	if slices.ContainsFunc(server.auth, func(a string) bool { return a != "ApiKey key" }) {
+		t.Errorf("Authorization got = %v, want %q", server.auth, "ApiKey key")
+	}
+
+	// the rejected document is reported, so it can be dead lettered
+	if sent != 3 || !reflect.DeepEqual(failed, []string{"bad"}) {
+		t.Errorf("delivery got sent = %v, failed = %v", sent, failed)
+	}
+}
+
+func TestElasticStream_ECS(t *testing.T) {
+	server := newStubElasticServer()
+	defer server.Close()
+
+	s, err := NewElasticStream(t.Context(), config.OutputElasticCfg{
+		HTTPOutputCfg: config.HTTPOutputCfg{URL: server.URL},
+		Index:         "logs",
+		ECS:           true,
+	})
+	if err != nil {
+		t.Fatalf("NewElasticStream() error = %v", err)
+	}
+	s.Output(types.ParsedLine{
+		Timestamp:  time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC),
+		Host:       "hst-name001",
+		Process:    types.ProcessInfo{PID: 12},
+		Message:    "hello",
+		LogLevel:   "warn",
+		SourceInfo: types.SourceFileInfo{Filename: "main.c", LineNumber: 890},
+	})
+	s.Close()
+
+	if len(server.docs) != 1 {
+		t.Fatalf("got %v docs, want 1", len(server.docs))
+	}
+	doc := server.docs[0]
+
+	want := map[string]any{
+		"@timestamp": "2025-03-04T05:06:07Z",
+		"message":    "hello",
+		"ecs":        map[string]any{"version": types.ECSVersion},
+		"host":       map[string]any{"name": "hst-name001"},
+		"process":    map[string]any{"pid": float64(12)},
+		"log": map[string]any{
+			"level":  "warn",
+			"origin": map[string]any{"file": map[string]any{"name": "main.c", "line": float64(890)}},
+		},
+	}
+	if !reflect.DeepEqual(doc, want) {
+		t.Errorf("doc got vs want:\n  %v\n  %v", doc, want)
+	}
+	if server.indexed["logs"] == nil {
+		t.Errorf("expected doc in index %q, got %v", "logs", server.indexed)
+	}
+}
+
+func TestElasticStream_RetriesExhausted(t *testing.T) {
+	server := newStubElasticServer()
+	server.unavailableEvery = 2
+	defer server.Close()
+
+	s, err := NewElasticStream(t.Context(), config.OutputElasticCfg{
+		HTTPOutputCfg: config.HTTPOutputCfg{
+			URL:        server.URL,
+			Backoff:    time.Millisecond,
+			MaxRetries: 2,
+		},
+		Index: "logs",
+	})
+	if err != nil {
+		t.Fatalf("NewElasticStream() error = %v", err)
+	}
+
+	var deliveries []Delivery
+	s.OnDelivery(func(d Delivery) { deliveries = append(deliveries, d) })
+
+	s.Output(types.ParsedLine{Message: "one"})
+	s.Output(types.ParsedLine{Message: "overloaded"})
+	s.Close()
+
+	// the failed requests and the retried document share one backoff
+	// schedule, rather than each retry of the document retrying the
+	// request too
+	if server.requests != 3 {
+		t.Errorf("got %d requests, want 3", server.requests)
+	}
+
+	sent, failed := 0, []string{}
+	for _, d := range deliveries {
+		sent += d.Sent
+		for _, line := range d.Failed {
+			failed = append(failed, line.Message)
+		}
+		if len(d.Failed) > 0 && d.Err != ErrRetriesExhausted {
+			t.Errorf("failed with %v, want ErrRetriesExhausted", d.Err)
+		}
+	}
+	if sent != 1 || !reflect.DeepEqual(failed, []string{"overloaded"}) {
+		t.Errorf("sent %d, failed %v", sent, failed)
+	}
+}
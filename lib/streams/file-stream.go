@@ -0,0 +1,553 @@
+package streams
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/log"
+	"github.com/erobsham/reform/lib/types"
+)
+
+//#< File Output Stream
+
+// the timestamp inserted into a rotated file's name, ie `app-2006-01-02T15-04-05.000.log`
+const rotatedFileTimeFormat = "2006-01-02T15-04-05.000"
+
+// matches the `-{rotatedFileTimeFormat}` suffix, plus the `-n` added when that name's taken
+const rotatedFileSuffixPattern = `-\d{4}-\d{2}-\d{2}T\d{2}-\d{2}-\d{2}\.\d{3}(-\d+)?`
+
+func NewOutputFile(cfg config.OutputFileCfg) (OutputStream, error) {
+	return newOutputFile(cfg, time.Now)
+}
+
+func newOutputFile(cfg config.OutputFileCfg, now func() time.Time) (*OutputFile, error) {
+	cfg = cfg.WithDefaults()
+	format, err := newLineFormatter(cfg.Format)
+	if err != nil {
+		return nil, err
+	}
+	if format == nil {
+		format = formatCLEF
+	}
+
+	o := &OutputFile{
+		cfg:       cfg,
+		format:    format,
+		now:       now,
+		logger:    log.Default().With(slog.String("path", cfg.Path)),
+		stopFlush: make(chan struct{}),
+	}
+
+	o.lock.Lock()
+	err = o.open(o.now())
+	o.lock.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	go o.flushLoop()
+	return o, nil
+}
+
+// OutputFile appends lines (CLEF by default) to a file, rotating it once it gets too big,
+// at the start of each hour / day, or whenever its (templated) path changes.
+//
+// writes are buffered, and streamed through gzip / zstd when the path ends in `.gz` / `.zst`.
+type OutputFile struct {
+	cfg    config.OutputFileCfg
+	format lineFormatter
+	now    func() time.Time
+	logger *slog.Logger
+
+	lock       sync.Mutex
+	file       *os.File
+	disk       *countingWriter // bytes that have actually reached `file`
+	compressor fileCompressor  // nil for plain files
+	buf        *bufio.Writer
+	dirty      bool      // `buf` / `compressor` hold data which hasn't been flushed yet
+	path       string    // the current file's path, with any date placeholders filled in
+	period     time.Time // start of the current rotation interval
+	closed     bool
+	stopFlush  chan struct{}
+
+	// compressing / pruning rotated files happens in the background, serialized by `cleanupLock`
+	cleanup     sync.WaitGroup
+	cleanupLock sync.Mutex
+}
+
+func (o *OutputFile) Output(line types.ParsedLine) error {
+	data, err := o.format(nil, line)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	if o.closed {
+		return ErrStreamClosed
+	}
+
+	now := o.now()
+	if o.file == nil {
+		// a previous rotation failed to open the next file, try again
+		if err := o.open(now); err != nil {
+			return err
+		}
+	} else if o.shouldRotate(now, int64(len(data))) {
+		if err := o.rotate(now); err != nil {
+			return err
+		}
+	}
+
+	o.dirty = true
+	_, err = o.buf.Write(data)
+	return err
+}
+
+func (o *OutputFile) Close() {
+	o.lock.Lock()
+	if o.closed {
+		o.lock.Unlock()
+		return
+	}
+	o.closed = true
+	close(o.stopFlush)
+	o.closeFile()
+	o.lock.Unlock()
+
+	o.cleanup.Wait()
+}
+
+func (o *OutputFile) flushLoop() {
+	ticker := time.NewTicker(o.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.stopFlush:
+			return
+		case <-ticker.C:
+		}
+
+		o.lock.Lock()
+		if err := o.flush(); err != nil {
+			o.logger.
+				Error("unable to flush outfile",
+					slog.String("error", err.Error()),
+				)
+		}
+		o.lock.Unlock()
+	}
+}
+
+// must be called with `lock` held.
+func (o *OutputFile) flush() error {
+	if o.file == nil || !o.dirty {
+		return nil
+	}
+	o.dirty = false
+
+	if err := o.buf.Flush(); err != nil {
+		return err
+	}
+	if o.compressor != nil {
+		return o.compressor.Flush()
+	}
+	return nil
+}
+
+// the file's size once everything buffered so far is written.  compressed data
+// is only counted once it's been flushed out of the compressor.
+func (o *OutputFile) size() int64 {
+	if o.compressor != nil {
+		return o.disk.n
+	}
+	return o.disk.n + int64(o.buf.Buffered())
+}
+
+func (o *OutputFile) periodStart(t time.Time) time.Time {
+	switch o.cfg.Rotate {
+	case config.RotateInterval_Hourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case config.RotateInterval_Daily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	default:
+		return time.Time{}
+	}
+}
+
+func (o *OutputFile) shouldRotate(now time.Time, pending int64) bool {
+	if formatDatePattern(o.cfg.Path, now) != o.path {
+		return true
+	}
+	if !o.periodStart(now).Equal(o.period) {
+		return true
+	}
+	size := o.size()
+	return o.cfg.MaxSize > 0 && size > 0 && size+pending > o.cfg.MaxSize
+}
+
+// opens (or creates) the file for `now`, rotating any leftovers from a previous
+// interval first.  must be called with `lock` held.
+func (o *OutputFile) open(now time.Time) error {
+	path := formatDatePattern(o.cfg.Path, now)
+	period := o.periodStart(now)
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	if o.cfg.Rotate != config.RotateInterval_None {
+		info, err := os.Stat(path)
+		if err == nil && info.Size() > 0 && o.periodStart(info.ModTime()).Before(period) {
+			o.archive(path, info.ModTime(), path)
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	o.disk = &countingWriter{w: f, n: info.Size()}
+	var w io.Writer = o.disk
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		o.compressor = gzip.NewWriter(o.disk)
+		w = o.compressor
+	case strings.HasSuffix(path, ".zst"):
+		enc, err := zstd.NewWriter(o.disk)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		o.compressor = enc
+		w = o.compressor
+	default:
+		o.compressor = nil
+	}
+
+	if o.buf == nil {
+		o.buf = bufio.NewWriterSize(w, o.cfg.BufferSize)
+	} else {
+		o.buf.Reset(w)
+	}
+
+	o.file = f
+	o.path = path
+	o.period = period
+	return nil
+}
+
+// must be called with `lock` held.
+func (o *OutputFile) rotate(now time.Time) error {
+	prevPath := o.path
+	nextPath := formatDatePattern(o.cfg.Path, now)
+	o.closeFile()
+
+	if nextPath == prevPath {
+		o.archive(prevPath, now, nextPath)
+	} else {
+		// the templated path moved on, so the previous file is already "rotated"
+		o.startCleanup(prevPath, nextPath)
+	}
+
+	return o.open(now)
+}
+
+// moves `path` out of the way to a timestamped backup, then compresses / prunes
+// in the background (leaving `current`, the file about to be written to, alone).
+func (o *OutputFile) archive(path string, t time.Time, current string) {
+	ext := fileExt(path)
+	base := strings.TrimSuffix(path, ext) + "-" + t.Format(rotatedFileTimeFormat)
+
+	backup := base + ext
+	for i := 1; fileExists(backup) || fileExists(backup+".gz"); i++ {
+		backup = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+
+	if err := os.Rename(path, backup); err != nil {
+		o.logger.
+			Error("unable to rotate output file",
+				slog.String("error", err.Error()),
+			)
+		return
+	}
+	o.startCleanup(backup, current)
+}
+
+func (o *OutputFile) startCleanup(rotated string, current string) {
+	if (!o.cfg.Compress || isCompressedFile(rotated)) && o.cfg.MaxBackups == 0 {
+		return
+	}
+
+	o.cleanup.Add(1)
+	go func() {
+		defer o.cleanup.Done()
+		o.cleanupLock.Lock()
+		defer o.cleanupLock.Unlock()
+
+		if o.cfg.Compress && !isCompressedFile(rotated) {
+			if err := gzipFile(rotated); err != nil {
+				o.logger.
+					Error("unable to compress rotated output file",
+						slog.String("file", rotated),
+						slog.String("error", err.Error()),
+					)
+			}
+		}
+		if o.cfg.MaxBackups > 0 {
+			o.pruneBackups(current)
+		}
+	}()
+}
+
+// removes the oldest rotated files, keeping `MaxBackups` of them
+func (o *OutputFile) pruneBackups(current string) {
+	// ie `logs/app.log` -> `logs/app-*.log`, and `logs/%Y-%m-%d.log` -> `logs/[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9]*.log`
+	ext := fileExt(o.cfg.Path)
+	pattern := datePatternGlob(strings.TrimSuffix(o.cfg.Path, ext)) + "*" + ext
+	if !strings.Contains(o.cfg.Path, "%") {
+		pattern = strings.TrimSuffix(o.cfg.Path, ext) + "-*" + ext
+	}
+	// the glob also matches other files (ie `app-errors.log`), which mustn't be touched
+	isBackup := backupFileRegexp(filepath.Clean(o.cfg.Path))
+
+	var backups []string
+	for _, glob := range []string{pattern, pattern + ".gz"} {
+		matches, _ := filepath.Glob(glob)
+		for _, path := range matches {
+			if isBackup.MatchString(filepath.Clean(path)) {
+				backups = append(backups, path)
+			}
+		}
+	}
+
+	type backup struct {
+		path    string
+		modTime time.Time
+	}
+	found := make([]backup, 0, len(backups))
+	for _, path := range backups {
+		if path == current || path == o.cfg.Path {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		found = append(found, backup{path, info.ModTime()})
+	}
+	if len(found) <= o.cfg.MaxBackups {
+		return
+	}
+
+	// newest first (backup names sort by their rotation time, for ties)
+	slices.SortFunc(found, func(a, b backup) int {
+		if c := b.modTime.Compare(a.modTime); c != 0 {
+			return c
+		}
+		return strings.Compare(b.path, a.path)
+	})
+	for _, b := range found[o.cfg.MaxBackups:] {
+		if err := os.Remove(b.path); err != nil {
+			o.logger.
+				Error("unable to remove old output file",
+					slog.String("file", b.path),
+					slog.String("error", err.Error()),
+				)
+		}
+	}
+}
+
+// must be called with `lock` held.
+func (o *OutputFile) closeFile() {
+	if o.file == nil {
+		return
+	}
+
+	o.dirty = false
+	err := o.buf.Flush()
+	if err == nil && o.compressor != nil {
+		err = o.compressor.Close()
+	}
+	if err == nil {
+		err = o.file.Sync()
+	}
+	if err != nil {
+		o.logger.
+			Error("unable to sync outfile",
+				slog.String("error", err.Error()),
+			)
+	}
+
+	o.file.Close()
+	o.file = nil
+}
+
+// a streaming compressor, ie `*gzip.Writer` | `*zstd.Encoder`
+type fileCompressor interface {
+	io.WriteCloser
+	Flush() error
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func isCompressedFile(path string) bool {
+	return strings.HasSuffix(path, ".gz") || strings.HasSuffix(path, ".zst")
+}
+
+// the file's extension, including the inner one for compressed files (ie `.log.gz`)
+func fileExt(path string) string {
+	ext := filepath.Ext(path)
+	if isCompressedFile(path) {
+		ext = filepath.Ext(strings.TrimSuffix(path, ext)) + ext
+	}
+	return ext
+}
+
+// matches the files rotated out of `path`, ie `app-2006-01-02T15-04-05.000.log[.gz]`.
+// with a templated path, the files it was formatted to are backups too.
+func backupFileRegexp(path string) *regexp.Regexp {
+	ext := fileExt(path)
+	suffix := "(" + rotatedFileSuffixPattern + ")"
+	if strings.Contains(path, "%") {
+		suffix += "?"
+	}
+	return regexp.MustCompile("^" + datePatternRegexp(strings.TrimSuffix(path, ext)) + suffix + regexp.QuoteMeta(ext) + `(\.gz)?$`)
+}
+
+// replaces a date pattern's placeholders with digit classes, quoting the rest
+func datePatternRegexp(pattern string) string {
+	b := strings.Builder{}
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c != '%' || i == len(pattern)-1 {
+			b.WriteString(regexp.QuoteMeta(string(c)))
+			continue
+		}
+
+		i += 1
+		switch pattern[i] {
+		case 'Y':
+			b.WriteString(`\d{4}`)
+		case 'y', 'm', 'd', 'H', 'M', 'S':
+			b.WriteString(`\d{2}`)
+		case 'j':
+			b.WriteString(`\d{3}`)
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteString(regexp.QuoteMeta("%" + string(pattern[i])))
+		}
+	}
+	return b.String()
+}
+
+// replaces a date pattern's placeholders with glob character classes
+func datePatternGlob(pattern string) string {
+	digits := func(n int) string {
+		return strings.Repeat("[0-9]", n)
+	}
+
+	b := strings.Builder{}
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c != '%' || i == len(pattern)-1 {
+			b.WriteByte(c)
+			continue
+		}
+
+		i += 1
+		switch pattern[i] {
+		case 'Y':
+			b.WriteString(digits(4))
+		case 'y', 'm', 'd', 'H', 'M', 'S':
+			b.WriteString(digits(2))
+		case 'j':
+			b.WriteString(digits(3))
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(pattern[i])
+		}
+	}
+	return b.String()
+}
+
+// compresses `path` to `path.gz`, removing the original
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := path + ".gz.tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(out)
+	_, err = io.Copy(gz, in)
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	out.Close()
+	if err == nil {
+		err = os.Rename(tmp, path+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// keep the rotation time, so pruning still orders backups correctly
+	if info, err := in.Stat(); err == nil {
+		os.Chtimes(path+".gz", info.ModTime(), info.ModTime())
+	}
+	return os.Remove(path)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+//#> File Output Stream
@@ -0,0 +1,398 @@
+package streams
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/types"
+)
+
+// a file output with a controllable clock
+func newTestOutputFile(t *testing.T, cfg config.OutputFileCfg, now *time.Time) *OutputFile {
+	t.Helper()
+
+	o, err := newOutputFile(cfg, func() time.Time { return *now })
+	if err != nil {
+		t.Fatalf("newOutputFile() error = %v", err)
+	}
+	return o
+}
+
+func dirEntries(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unable to read dir: %v", err)
+	}
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func TestOutputFile_Rotation(t *testing.T) {
+	start := time.Date(2024, time.March, 9, 23, 30, 0, 0, time.UTC)
+	line := types.ParsedLine{Message: "0123456789"}
+
+	tests := []struct {
+		name      string
+		cfg       config.OutputFileCfg
+		step      time.Duration
+		lines     int
+		wantFiles []string
+	}{
+		{
+			name:      "no rotation",
+			cfg:       config.OutputFileCfg{Path: "app.log"},
+			step:      time.Hour,
+			lines:     3,
+			wantFiles: []string{"app.log"},
+		},
+		{
+			name:  "max size",
+			cfg:   config.OutputFileCfg{Path: "app.log", MaxSize: 40},
+			lines: 5,
+			wantFiles: []string{
+				"app-2024-03-09T23-30-00.000-1.log",
+				"app-2024-03-09T23-30-00.000.log",
+				"app.log",
+			},
+		},
+		{
+			name:  "hourly",
+			cfg:   config.OutputFileCfg{Path: "app.log", Rotate: config.RotateInterval_Hourly},
+			step:  time.Minute * 20,
+			lines: 4,
+			wantFiles: []string{
+				"app-2024-03-10T00-10-00.000.log",
+				"app.log",
+			},
+		},
+		{
+			name:  "daily",
+			cfg:   config.OutputFileCfg{Path: "app.log", Rotate: config.RotateInterval_Daily},
+			step:  time.Hour * 12,
+			lines: 4,
+			wantFiles: []string{
+				"app-2024-03-10T11-30-00.000.log",
+				"app-2024-03-11T11-30-00.000.log",
+				"app.log",
+			},
+		},
+		{
+			name:  "path template",
+			cfg:   config.OutputFileCfg{Path: "%Y/%m-%d.log"},
+			step:  time.Hour * 12,
+			lines: 4,
+			wantFiles: []string{
+				"2024/03-09.log",
+				"2024/03-10.log",
+				"2024/03-11.log",
+			},
+		},
+		{
+			name:  "max backups",
+			cfg:   config.OutputFileCfg{Path: "app.log", Rotate: config.RotateInterval_Hourly, MaxBackups: 1},
+			step:  time.Hour,
+			lines: 4,
+			wantFiles: []string{
+				"app-2024-03-10T02-30-00.000.log",
+				"app.log",
+			},
+		},
+		{
+			name:  "max backups with template",
+			cfg:   config.OutputFileCfg{Path: "%Y-%m-%d.log", MaxBackups: 1},
+			step:  time.Hour * 12,
+			lines: 4,
+			wantFiles: []string{
+				"2024-03-10.log",
+				"2024-03-11.log",
+			},
+		},
+		{
+			name:  "compress",
+			cfg:   config.OutputFileCfg{Path: "app.log", Rotate: config.RotateInterval_Daily, Compress: true},
+			step:  time.Hour * 12,
+			lines: 2,
+			wantFiles: []string{
+				"app-2024-03-10T11-30-00.000.log.gz",
+				"app.log",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.cfg.Path = filepath.Join(dir, tt.cfg.Path)
+
+			now := start
+			o := newTestOutputFile(t, tt.cfg, &now)
+			for range tt.lines {
+				if err := o.Output(line); err != nil {
+					t.Fatalf("Output() error = %v", err)
+				}
+				now = now.Add(tt.step)
+			}
+			o.Close()
+
+			var got []string
+			filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+				if err == nil && !d.IsDir() {
+					rel, _ := filepath.Rel(dir, path)
+					got = append(got, filepath.ToSlash(rel))
+				}
+				return nil
+			})
+			slices.Sort(got)
+			if !slices.Equal(got, tt.wantFiles) {
+				t.Errorf("files got vs want:\n  %v\n  %v", got, tt.wantFiles)
+			}
+		})
+	}
+}
+
+func TestOutputFile_CompressedContents(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, time.March, 9, 12, 0, 0, 0, time.UTC)
+	o := newTestOutputFile(t, config.OutputFileCfg{
+		Path:     filepath.Join(dir, "app.log"),
+		MaxSize:  1,
+		Compress: true,
+	}, &now)
+
+	o.Output(types.ParsedLine{Message: "first"})
+	o.Output(types.ParsedLine{Message: "second"})
+	o.Close()
+
+	f, err := os.Open(filepath.Join(dir, "app-2024-03-09T12-00-00.000.log.gz"))
+	if err != nil {
+		t.Fatalf("missing rotated file, found %v", dirEntries(t, dir))
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("gzip.NewReader() error = %v", err)
+	}
+	rotated, _ := io.ReadAll(gz)
+	if !strings.Contains(string(rotated), `"first"`) || strings.Contains(string(rotated), `"second"`) {
+		t.Errorf("rotated file = %q", rotated)
+	}
+
+	current, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+	if !strings.Contains(string(current), `"second"`) {
+		t.Errorf("current file = %q", current)
+	}
+}
+
+func TestOutputFile_PruneKeepsOtherFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		current string
+		// oldest first
+		files     []string
+		wantFiles []string
+	}{
+		{
+			name:    "plain path",
+			path:    "app.log",
+			current: "app.log",
+			files: []string{
+				"app-2024-03-09T01-00-00.000.log",
+				"app-errors.log",
+				"app-2.log",
+				"app-2024-03-09T02-00-00.000-1.log.gz",
+			},
+			wantFiles: []string{
+				"app-2.log",
+				"app-2024-03-09T02-00-00.000-1.log.gz",
+				"app-errors.log",
+				"app.log",
+			},
+		},
+		{
+			name:    "templated path",
+			path:    "%Y-%m-%d.log",
+			current: "2024-03-10.log",
+			files: []string{
+				"2024-03-08.log",
+				"2024-03-09-errors.log",
+				"2024-03-09.log",
+			},
+			wantFiles: []string{
+				"2024-03-09-errors.log",
+				"2024-03-09.log",
+				"2024-03-10.log",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			modTime := time.Date(2024, time.March, 9, 0, 0, 0, 0, time.UTC)
+			for _, name := range tt.files {
+				path := filepath.Join(dir, name)
+				os.WriteFile(path, []byte("{}\n"), 0644)
+				os.Chtimes(path, modTime, modTime)
+				modTime = modTime.Add(time.Hour)
+			}
+
+			now := time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC)
+			o := newTestOutputFile(t, config.OutputFileCfg{Path: filepath.Join(dir, tt.path), MaxBackups: 1}, &now)
+			o.pruneBackups(filepath.Join(dir, tt.current))
+			o.Close()
+
+			if got := dirEntries(t, dir); !slices.Equal(got, tt.wantFiles) {
+				t.Errorf("files got vs want:\n  %v\n  %v", got, tt.wantFiles)
+			}
+		})
+	}
+}
+
+func TestOutputFile_RotatesStaleFileOnStart(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	os.WriteFile(path, []byte("{}\n"), 0644)
+	yesterday := time.Now().Add(-time.Hour * 24)
+	os.Chtimes(path, yesterday, yesterday)
+
+	out, err := NewOutputFile(config.OutputFileCfg{Path: path, Rotate: config.RotateInterval_Daily})
+	if err != nil {
+		t.Fatalf("NewOutputFile() error = %v", err)
+	}
+	out.Close()
+
+	if got := dirEntries(t, dir); len(got) != 2 {
+		t.Errorf("expected the stale file to be rotated, found %v", got)
+	}
+}
+
+func TestOutputFile_Buffering(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	out, err := NewOutputFile(config.OutputFileCfg{Path: path, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("NewOutputFile() error = %v", err)
+	}
+
+	out.Output(types.ParsedLine{Message: "buffered"})
+	if data, _ := os.ReadFile(path); len(data) != 0 {
+		t.Errorf("expected the line to be buffered, file = %q", data)
+	}
+
+	out.Close()
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), `"buffered"`) {
+		t.Errorf("expected the line to be flushed on close, file = %q", data)
+	}
+}
+
+func TestOutputFile_FlushInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	out, err := NewOutputFile(config.OutputFileCfg{Path: path, FlushInterval: time.Millisecond * 10})
+	if err != nil {
+		t.Fatalf("NewOutputFile() error = %v", err)
+	}
+	defer out.Close()
+
+	out.Output(types.ParsedLine{Message: "flushed"})
+
+	deadline := time.Now().Add(time.Second * 5)
+	for {
+		if data, _ := os.ReadFile(path); len(data) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("line wasn't flushed")
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+}
+
+func TestOutputFile_Compressed(t *testing.T) {
+	tests := []struct {
+		name      string
+		file      string
+		newReader func(io.Reader) (io.Reader, error)
+	}{
+		{
+			name: "gzip",
+			file: "app.log.gz",
+			newReader: func(r io.Reader) (io.Reader, error) {
+				return gzip.NewReader(r)
+			},
+		},
+		{
+			name: "zstd",
+			file: "app.log.zst",
+			newReader: func(r io.Reader) (io.Reader, error) {
+				return zstd.NewReader(r)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+
+			// reopening appends a second stream, which should read back as one
+			for _, msg := range []string{"first", "second"} {
+				out, err := NewOutputFile(config.OutputFileCfg{Path: path})
+				if err != nil {
+					t.Fatalf("NewOutputFile() error = %v", err)
+				}
+				for range 100 {
+					out.Output(types.ParsedLine{Message: msg})
+				}
+				out.Close()
+			}
+
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatalf("unable to open output: %v", err)
+			}
+			defer f.Close()
+			r, err := tt.newReader(f)
+			if err != nil {
+				t.Fatalf("unable to read output: %v", err)
+			}
+			data, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("unable to decompress output: %v", err)
+			}
+
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			if len(lines) != 200 || !strings.Contains(lines[0], `"first"`) || !strings.Contains(lines[199], `"second"`) {
+				t.Errorf("decompressed %v lines, first %q, last %q", len(lines), lines[0], lines[len(lines)-1])
+			}
+		})
+	}
+}
+
+func TestOutputFile_Format(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	out, err := NewOutputFile(config.OutputFileCfg{
+		Path:   path,
+		Format: config.OutputFormatCfg{Format: config.OutputFormat_Text, Template: "{{.Host}}: {{.Message}}"},
+	})
+	if err != nil {
+		t.Fatalf("NewOutputFile() error = %v", err)
+	}
+	out.Output(types.ParsedLine{Host: "hst-name001", Message: "first"})
+	out.Output(types.ParsedLine{Host: "hst-name002", Message: "second"})
+	out.Close()
+
+	want := "hst-name001: first\nhst-name002: second\n"
+	if data, _ := os.ReadFile(path); string(data) != want {
+		t.Errorf("file got vs want:\n  %q\n  %q", data, want)
+	}
+}
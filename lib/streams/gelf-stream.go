@@ -0,0 +1,236 @@
+package streams
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math/rand/v2"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/types"
+)
+
+//#< GELF Stream
+
+// GELFStream sends lines to Graylog (or anything else that speaks GELF 1.1).
+type GELFStream struct {
+	cfg config.OutputGELFCfg
+
+	lock   sync.Mutex
+	closed bool
+	writer *netWriter
+
+	// used when a line doesn't have a host, `host` is required
+	defaultHost string
+
+	compressBuf bytes.Buffer
+}
+
+func NewGELFStream(cfg config.OutputGELFCfg) (*GELFStream, error) {
+	cfg = cfg.WithDefaults()
+
+	writer, err := newNetWriter(cfg.NetOutputCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultHost, err := os.Hostname()
+	if err != nil {
+		defaultHost = "reform"
+	}
+
+	return &GELFStream{
+		cfg:         cfg,
+		writer:      writer,
+		defaultHost: defaultHost,
+	}, nil
+}
+
+func (s *GELFStream) Output(line types.ParsedLine) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return ErrStreamClosed
+	}
+
+	msg, err := json.Marshal(s.message(line))
+	if err != nil {
+		return err
+	}
+
+	if s.writer.isStream() {
+		// tcp frames are null delimited, and can't be compressed
+		return s.writer.write(append(msg, 0))
+	}
+
+	msg, err = s.compress(msg)
+	if err != nil {
+		return err
+	}
+	if len(msg) <= s.cfg.ChunkSize {
+		return s.writer.write(msg)
+	}
+
+	chunks, err := chunkGELFMessage(msg, s.cfg.ChunkSize, rand.Uint64())
+	if err != nil {
+		return err
+	}
+	for _, chunk := range chunks {
+		err = s.writer.write(chunk)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *GELFStream) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+	s.writer.close()
+}
+
+// GELF additional field names must match `^[\w\.\-]*$`
+var invalidGELFFieldChars = regexp.MustCompile(`[^\w.\-]`)
+
+func (s *GELFStream) message(line types.ParsedLine) map[string]any {
+	host := line.Host
+	if host == "" {
+		host = s.defaultHost
+	}
+
+	// the first line is the summary, the full message is only needed
+	// when there's more to it
+	short, _, multiline := strings.Cut(line.Message, "\n")
+
+	msg := map[string]any{
+		"version":       "1.1",
+		"host":          host,
+		"short_message": short,
+		"level":         int(line.Severity()),
+	}
+	if short == "" {
+		// `short_message` is required to be non-empty
+		msg["short_message"] = "-"
+	}
+	if multiline {
+		msg["full_message"] = line.Message
+	}
+
+	ts := line.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	msg["timestamp"] = float64(ts.UnixMicro()) / 1e6
+
+	if line.Process.Name != "" {
+		msg["_proc"] = line.Process.Name
+	}
+	if line.Process.PID != 0 {
+		msg["_pid"] = line.Process.PID
+	}
+	if line.Process.TID != 0 {
+		msg["_tid"] = line.Process.TID
+	}
+	if line.LogLevel != "" {
+		// `level` only has the numeric severity
+		msg["_log_level"] = line.LogLevel
+	}
+	if line.Source != "" {
+		msg["_source"] = line.Source
+	}
+	if line.SourceInfo.Filename != "" {
+		msg["_file"] = line.SourceInfo.Filename
+	}
+	if line.SourceInfo.LineNumber != 0 {
+		msg["_line"] = line.SourceInfo.LineNumber
+	}
+	if line.SourceInfo.Language != "" {
+		msg["_lang"] = line.SourceInfo.Language
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(line.Properties)) {
+		name := "_" + invalidGELFFieldChars.ReplaceAllString(key, "_")
+		if name == "_id" {
+			// reserved by graylog
+			name = "_id_"
+		}
+		if _, exists := msg[name]; exists {
+			continue
+		}
+		msg[name] = line.Properties[key]
+	}
+
+	return msg
+}
+
+// the returned slice is only valid until the next call
+func (s *GELFStream) compress(msg []byte) ([]byte, error) {
+	var w io.WriteCloser
+	s.compressBuf.Reset()
+	switch s.cfg.Compression {
+	case config.GELFCompression_Gzip:
+		w = gzip.NewWriter(&s.compressBuf)
+	case config.GELFCompression_Zlib:
+		w = zlib.NewWriter(&s.compressBuf)
+	default:
+		return msg, nil
+	}
+
+	_, err := w.Write(msg)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, err
+	}
+	return s.compressBuf.Bytes(), nil
+}
+
+const (
+	gelfChunkHeaderSize = 12
+	gelfMaxChunks       = 128
+)
+
+// splits a message into chunks of at most `chunkSize` bytes, each prefixed with:
+//
+//	magic bytes (0x1e 0x0f) | message id (8 bytes) | sequence number | sequence count
+func chunkGELFMessage(msg []byte, chunkSize int, id uint64) ([][]byte, error) {
+	dataSize := chunkSize - gelfChunkHeaderSize
+	count := (len(msg) + dataSize - 1) / dataSize
+	if count > gelfMaxChunks {
+		return nil, fmt.Errorf("gelf message too large: %d bytes needs %d chunks, max is %d", len(msg), count, gelfMaxChunks)
+	}
+
+	chunks := make([][]byte, 0, count)
+	for seq := range count {
+		data := msg[seq*dataSize : min((seq+1)*dataSize, len(msg))]
+
+		chunk := make([]byte, 0, gelfChunkHeaderSize+len(data))
+		chunk = append(chunk, 0x1e, 0x0f)
+		for i := 7; i >= 0; i-- {
+			chunk = append(chunk, byte(id>>(i*8)))
+		}
+		chunk = append(chunk, byte(seq), byte(count))
+		chunk = append(chunk, data...)
+
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+//#> GELF Stream
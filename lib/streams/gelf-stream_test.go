@@ -0,0 +1,259 @@
+package streams
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/types"
+)
+
+func TestGELFStream_message(t *testing.T) {
+	s := &GELFStream{defaultHost: "reform-host"}
+
+	tests := []struct {
+		name string
+		line types.ParsedLine
+		want map[string]any
+	}{
+		{
+			name: "all fields",
+			line: types.ParsedLine{
+				Timestamp:  time.Date(2025, 3, 4, 5, 6, 7, 250000000, time.UTC),
+				Host:       "hst-name001",
+				Process:    types.ProcessInfo{Name: "acme", PID: 12},
+				Message:    "first line\nsecond line",
+				LogLevel:   "warn",
+				SourceInfo: types.SourceFileInfo{Filename: "main.c", LineNumber: 890},
+				Properties: map[string]any{"user id": "bob", "id": 1},
+			},
+			want: map[string]any{
+				"version":       "1.1",
+				"host":          "hst-name001",
+				"short_message": "first line",
+				"full_message":  "first line\nsecond line",
+				"timestamp":     1741064767.25,
+				"level":         4,
+				"_proc":         "acme",
+				"_pid":          uint64(12),
+				"_log_level":    "warn",
+				"_file":         "main.c",
+				"_line":         uint64(890),
+				"_user_id":      "bob",
+				"_id_":          1,
+			},
+		},
+		{
+			name: "defaults",
+			line: types.ParsedLine{
+				Timestamp: time.Unix(10, 0),
+			},
+			want: map[string]any{
+				"version":       "1.1",
+				"host":          "reform-host",
+				"short_message": "-",
+				"timestamp":     float64(10),
+				"level":         6,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.message(tt.line); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("message() got vs want:\n  %v\n  %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_chunkGELFMessage(t *testing.T) {
+	msg := bytes.Repeat([]byte("0123456789"), 10)
+
+	chunks, err := chunkGELFMessage(msg, 42, 0x0102030405060708)
+	if err != nil {
+		t.Fatalf("chunkGELFMessage() error = %v", err)
+	}
+	if len(chunks) != 4 {
+		t.Fatalf("got %v chunks, want %v", len(chunks), 4)
+	}
+
+	joined := []byte{}
+	for i, chunk := range chunks {
+		header := []byte{0x1e, 0x0f, 1, 2, 3, 4, 5, 6, 7, 8, byte(i), 4}
+		if !bytes.Equal(chunk[:12], header) {
+			t.Errorf("chunk %v header = %v, want %v", i, chunk[:12], header)
+		}
+		if len(chunk) > 42 {
+			t.Errorf("chunk %v is %v bytes, max %v", i, len(chunk), 42)
+		}
+		joined = append(joined, chunk[12:]...)
+	}
+	if !bytes.Equal(joined, msg) {
+		t.Errorf("reassembled = %q, want %q", joined, msg)
+	}
+
+	_, err = chunkGELFMessage(bytes.Repeat(msg, 100), 64, 0)
+	if err == nil {
+		t.Errorf("expected error for a message needing more than %v chunks", gelfMaxChunks)
+	}
+}
+
+// reads datagrams until a complete (possibly chunked) message arrives
+func readGELFDatagram(t *testing.T, conn net.PacketConn) []byte {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	chunks := map[byte][]byte{}
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("ReadFrom() error = %v", err)
+		}
+		data := bytes.Clone(buf[:n])
+		if len(data) < 2 || data[0] != 0x1e || data[1] != 0x0f {
+			return data
+		}
+
+		chunks[data[10]] = data[12:]
+		if count := int(data[11]); len(chunks) == count {
+			msg := []byte{}
+			for i := range count {
+				msg = append(msg, chunks[byte(i)]...)
+			}
+			return msg
+		}
+	}
+}
+
+func TestGELFStream_UDP(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression config.GELFCompression
+		chunkSize   int
+	}{
+		{
+			name: "plain",
+		},
+		{
+			name:      "chunked",
+			chunkSize: 100,
+		},
+		{
+			name:        "gzip",
+			compression: config.GELFCompression_Gzip,
+		},
+		{
+			name:        "zlib chunked",
+			compression: config.GELFCompression_Zlib,
+			chunkSize:   64,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("ListenPacket() error = %v", err)
+			}
+			defer conn.Close()
+
+			s, err := NewGELFStream(config.OutputGELFCfg{
+				NetOutputCfg: config.NetOutputCfg{Address: conn.LocalAddr().String()},
+				Compression:  tt.compression,
+				ChunkSize:    tt.chunkSize,
+			})
+			if err != nil {
+				t.Fatalf("NewGELFStream() error = %v", err)
+			}
+			defer s.Close()
+
+			message := strings.Repeat("a long message ", 20)
+			if err := s.Output(types.ParsedLine{Host: "hst-name001", Message: message}); err != nil {
+				t.Fatalf("Output() error = %v", err)
+			}
+
+			data := readGELFDatagram(t, conn)
+			var reader io.Reader
+			switch tt.compression {
+			case config.GELFCompression_Gzip:
+				reader, err = gzip.NewReader(bytes.NewReader(data))
+			case config.GELFCompression_Zlib:
+				reader, err = zlib.NewReader(bytes.NewReader(data))
+			default:
+				reader = bytes.NewReader(data)
+			}
+			if err != nil {
+				t.Fatalf("unable to decompress: %v", err)
+			}
+
+			var got map[string]any
+			if err := json.NewDecoder(reader).Decode(&got); err != nil {
+				t.Fatalf("unable to decode message: %v", err)
+			}
+			if got["short_message"] != message || got["host"] != "hst-name001" {
+				t.Errorf("got message %v", got)
+			}
+		})
+	}
+}
+
+func TestGELFStream_TCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer listener.Close()
+
+	received := make(chan []string)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(received)
+			return
+		}
+		defer conn.Close()
+
+		msgs := []string{}
+		reader := bufio.NewReader(conn)
+		for {
+			frame, err := reader.ReadBytes(0)
+			if err != nil {
+				break
+			}
+			var msg map[string]any
+			json.Unmarshal(frame[:len(frame)-1], &msg)
+			msgs = append(msgs, msg["short_message"].(string))
+		}
+		received <- msgs
+	}()
+
+	s, err := NewGELFStream(config.OutputGELFCfg{
+		NetOutputCfg: config.NetOutputCfg{
+			Address:  listener.Addr().String(),
+			Protocol: config.NetProtocol_TCP,
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewGELFStream() error = %v", err)
+	}
+	for _, msg := range []string{"one", "two", "three"} {
+		if err := s.Output(types.ParsedLine{Message: msg}); err != nil {
+			t.Fatalf("Output() error = %v", err)
+		}
+	}
+	s.Close()
+
+	want := []string{"one", "two", "three"}
+	if got := <-received; !reflect.DeepEqual(got, want) {
+		t.Errorf("received = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,139 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/erobsham/reform/lib/config"
+)
+
+//#< HTTP Client
+
+func newHTTPClient(tlsCfg config.TLSCfg, timeout time.Duration) (*http.Client, error) {
+	tlsConfig, err := newTLSConfig(tlsCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 http.ProxyFromEnvironment,
+			IdleConnTimeout:       time.Second * 10,
+			ResponseHeaderTimeout: timeout,
+			ExpectContinueTimeout: time.Millisecond * 250,
+			TLSHandshakeTimeout:   time.Second * 5,
+			TLSClientConfig:       tlsConfig,
+		},
+		Timeout: timeout,
+	}, nil
+}
+
+type retryPolicy struct {
+	MaxRetries int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// defaults to `isRetriableHTTPError`
+	IsRetriable func(err error) bool
+}
+
+// calls `fn` until it succeeds, returns a non-retriable error, or runs out of
+// retries. waits with exponential backoff between attempts, unless the server
+// asked us to wait a specific amount of time (ie `429` / `503` w/ `Retry-After`).
+func retryWithBackoff(ctx context.Context, logger *slog.Logger, policy retryPolicy, fn func() error) error {
+	backoff := policy.Backoff
+	isRetriable := policy.IsRetriable
+	if isRetriable == nil {
+		isRetriable = isRetriableHTTPError
+	}
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if attempt >= policy.MaxRetries || !isRetriable(err) {
+			return err
+		}
+
+		wait := backoff
+		var respErr HTTPResponseError
+		if errors.As(err, &respErr) && respErr.RetryAfter > 0 {
+			// the server's asked for longer than we'd wait, so it's capped
+			wait = min(respErr.RetryAfter, policy.MaxBackoff)
+		}
+		backoff = min(backoff*2, policy.MaxBackoff)
+
+		logger.
+			Warn("request failed, retrying",
+				slog.Int("attempt", attempt+1),
+				slog.Duration("wait", wait),
+				slog.String("error", err.Error()),
+			)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+//#> HTTP Client
+
+//#< HTTP Errors
+
+type HTTPResponseError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	Body       string
+}
+
+func (e HTTPResponseError) Error() string {
+	return fmt.Sprintf("unexpected response status: %v body:%s", e.StatusCode, e.Body)
+}
+
+func isRetriableHTTPError(err error) bool {
+	var respErr HTTPResponseError
+	if !errors.As(err, &respErr) {
+		// connection refused, timeouts, etc.
+		return !errors.Is(err, context.Canceled)
+	}
+
+	return isRetriableHTTPStatus(respErr.StatusCode)
+}
+
+func isRetriableHTTPStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusRequestTimeout,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// handles both forms of the header: `Retry-After: 120` | `Retry-After: Fri, 31 Dec 1999 23:59:59 GMT`
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(secs, 0)) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+//#> HTTP Errors
@@ -8,6 +8,7 @@ import (
 	"log/slog"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/erobsham/reform/lib/log"
 	"github.com/erobsham/reform/lib/parser"
@@ -15,24 +16,41 @@ import (
 
 const (
 	ErrStreamClosed StreamError = "stream closed"
+	ErrNextTimeout  StreamError = "timed out waiting for the next line"
 )
 
 type InputStream interface {
+	Name() string
 	Next() (string, error)
+	Close()
 }
 
-func NewCmdStream(ctx context.Context, streamName string, command string, args ...string) CmdStream {
+//#< Cmd Input Stream
+
+// how long a command gets to exit after being asked to stop (SIGTERM)
+// before its whole process group is killed.
+const cmdStopGracePeriod = time.Second * 3
+
+func NewCmdStream(ctx context.Context, streamName string, command string, args ...string) *CmdStream {
 	if args == nil {
 		args = []string{}
 	}
 
-	cmd := exec.Command(command, args...)
+	ctx, cancelFn := context.WithCancel(ctx)
+
+	done := make(chan struct{})
 
-	s := CmdStream{
-		name:   streamName,
-		ctx:    ctx,
-		cmd:    cmd,
-		output: make(chan string),
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.WaitDelay = cmdStopGracePeriod
+	setupProcessGroup(cmd, cmdStopGracePeriod, done)
+
+	s := &CmdStream{
+		name:       streamName,
+		ctx:        ctx,
+		cancelFunc: cancelFn,
+		cmd:        cmd,
+		output:     make(chan string),
+		done:       done,
 	}
 
 	go s.runloop()
@@ -41,13 +59,20 @@ func NewCmdStream(ctx context.Context, streamName string, command string, args .
 }
 
 type CmdStream struct {
-	name   string
-	ctx    context.Context
-	cmd    *exec.Cmd
-	output chan string
+	name       string
+	ctx        context.Context
+	cancelFunc context.CancelFunc
+	cmd        *exec.Cmd
+	output     chan string
+
+	// closed once the process has been reaped and `exitErr` is set.
+	done    chan struct{}
+	exitErr error
 }
 
-func (s CmdStream) Next() (string, error) {
+func (s *CmdStream) Name() string { return s.name }
+
+func (s *CmdStream) Next() (string, error) {
 	val, ok := <-s.output
 
 	if !ok {
@@ -57,34 +82,55 @@ func (s CmdStream) Next() (string, error) {
 	}
 }
 
-func (s CmdStream) runloop() {
+// Close stops the command (and any children it spawned), blocking until
+// the process has been reaped.
+func (s *CmdStream) Close() {
+	s.cancelFunc()
+	<-s.done
+}
+
+// ExitErr blocks until the command has exited, and returns the error (if any)
+// reported from waiting on it.
+func (s *CmdStream) ExitErr() error {
+	<-s.done
+	return s.exitErr
+}
+
+func (s *CmdStream) runloop() {
+	defer close(s.done)
 	defer close(s.output)
 
 	pipe, err := s.cmd.StdoutPipe()
 	if err != nil {
+		s.exitErr = err
+		return
+	}
+	errPipe, err := s.cmd.StderrPipe()
+	if err != nil {
+		s.exitErr = err
 		return
 	}
-	errPipe, _ := s.cmd.StderrPipe()
 
 	reader := bufio.NewReader(pipe)
-	errReader := bufio.NewReader(errPipe)
 
 	err = s.cmd.Start()
 	if err != nil {
+		log.Default().
+			Error("unable to start cmd",
+				slog.String("name", s.name),
+				slog.String("error", err.Error()),
+			)
+		s.exitErr = err
 		return
 	}
 
+	stderrDone := make(chan struct{})
+	go s.logStderr(errPipe, stderrDone)
+
 outer:
 	for {
 		line, err := readNextLine(reader)
 		if err != nil && !errors.Is(err, io.EOF) {
-			errOut, _ := errReader.ReadBytes('\n')
-			if len(errOut) > 0 {
-				log.Default().
-					Error("cmd err",
-						slog.String("error", string(errOut)),
-					)
-			}
 			break
 		}
 
@@ -99,8 +145,75 @@ outer:
 			}
 		}
 	}
+
+	// we're no longer reading, so make sure the command gets stopped if it
+	// hasn't already exited on its own.
+	s.cancelFunc()
+
+	// the pipes must be fully read before calling `Wait()`
+	<-stderrDone
+	s.exitErr = s.cmd.Wait()
+
+	// the command may have spawned children into its process group which
+	// outlive it -- make sure none of them are left behind.
+	killProcessGroup(s.cmd)
+
+	s.logExitStatus()
+}
+
+func (s *CmdStream) logStderr(errPipe io.Reader, done chan<- struct{}) {
+	defer close(done)
+
+	scanner := bufio.NewScanner(errPipe)
+	for scanner.Scan() {
+		log.Default().
+			Error("cmd err",
+				slog.String("name", s.name),
+				slog.String("error", scanner.Text()),
+			)
+	}
 }
 
+func (s *CmdStream) logExitStatus() {
+	if s.exitErr == nil {
+		log.Default().
+			Info("cmd exited",
+				slog.String("name", s.name),
+				slog.Int("exit_code", 0),
+			)
+		return
+	}
+
+	if errors.Is(s.exitErr, context.Canceled) {
+		// exited cleanly after we asked it to stop
+		log.Default().
+			Info("cmd stopped",
+				slog.String("name", s.name),
+			)
+		return
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(s.exitErr, &exitErr) {
+		// killed by a signal shows up as `exit_code=-1`
+		log.Default().
+			Info("cmd exited",
+				slog.String("name", s.name),
+				slog.Int("exit_code", exitErr.ExitCode()),
+				slog.String("status", exitErr.String()),
+			)
+		return
+	}
+
+	log.Default().
+		Error("cmd exited",
+			slog.String("name", s.name),
+			slog.String("error", s.exitErr.Error()),
+		)
+}
+
+//#> Cmd Input Stream
+
 func readNextLine(pipe *bufio.Reader) (string, error) {
 	var line string
 	for {
@@ -0,0 +1,68 @@
+package streams
+
+import (
+	"encoding/json"
+	"text/template"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/types"
+)
+
+//#< Line Formats
+
+// appends a single formatted line (without a trailing newline) to `b`
+type lineFormatter func(b []byte, line types.ParsedLine) ([]byte, error)
+
+// returns nil for `OutputFormat_Default`, leaving it to the output to use its own format
+func newLineFormatter(cfg config.OutputFormatCfg) (lineFormatter, error) {
+	switch cfg.Format {
+	case config.OutputFormat_CLEF:
+		return formatCLEF, nil
+	case config.OutputFormat_ECSJSON:
+		return formatECSJSON, nil
+	case config.OutputFormat_Logfmt:
+		return formatLogfmt, nil
+	case config.OutputFormat_Text:
+		tmpl, err := template.New("format").Option("missingkey=zero").Parse(cfg.Template)
+		if err != nil {
+			return nil, err
+		}
+		return func(b []byte, line types.ParsedLine) ([]byte, error) {
+			w := appendWriter{b}
+			err := tmpl.Execute(&w, line)
+			return w.b, err
+		}, nil
+	default:
+		return nil, nil
+	}
+}
+
+func formatCLEF(b []byte, line types.ParsedLine) ([]byte, error) {
+	data, err := json.Marshal(line)
+	return append(b, data...), err
+}
+
+func formatECSJSON(b []byte, line types.ParsedLine) ([]byte, error) {
+	data, err := json.Marshal(line.ECS())
+	return append(b, data...), err
+}
+
+func formatLogfmt(b []byte, line types.ParsedLine) ([]byte, error) {
+	return line.AppendLogfmt(b), nil
+}
+
+// whether the format writes json, rather than plain text
+func isJSONFormat(format config.OutputFormat) bool {
+	return format == config.OutputFormat_CLEF || format == config.OutputFormat_ECSJSON
+}
+
+type appendWriter struct {
+	b []byte
+}
+
+func (w *appendWriter) Write(p []byte) (int, error) {
+	w.b = append(w.b, p...)
+	return len(p), nil
+}
+
+//#> Line Formats
@@ -0,0 +1,76 @@
+package streams
+
+import (
+	"testing"
+	"time"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/types"
+)
+
+func Test_newLineFormatter(t *testing.T) {
+	line := types.ParsedLine{
+		Timestamp: time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC),
+		Host:      "hst-name001",
+		Process:   types.ProcessInfo{Name: "acme", PID: 12},
+		Message:   "hello there",
+		LogLevel:  "warn",
+	}
+
+	tests := []struct {
+		name    string
+		cfg     config.OutputFormatCfg
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "clef",
+			cfg:  config.OutputFormatCfg{Format: config.OutputFormat_CLEF},
+			want: `{"@t":"2025-03-04T05:06:07Z","host":"hst-name001","proc":{"Name":"acme","PID":12,"TID":0},"@m":"hello there","@l":"warn"}`,
+		},
+		{
+			name: "ecs-json",
+			cfg:  config.OutputFormatCfg{Format: config.OutputFormat_ECSJSON},
+			want: `{"@timestamp":"2025-03-04T05:06:07Z","ecs":{"version":"8.11.0"},"host":{"name":"hst-name001"},"log":{"level":"warn"},"message":"hello there","process":{"name":"acme","pid":12}}`,
+		},
+		{
+			name: "logfmt",
+			cfg:  config.OutputFormatCfg{Format: config.OutputFormat_Logfmt},
+			want: `time=2025-03-04T05:06:07Z host=hst-name001 proc=acme pid=12 level=warn msg="hello there"`,
+		},
+		{
+			name: "text",
+			cfg:  config.OutputFormatCfg{Format: config.OutputFormat_Text, Template: config.DefaultTextTemplate},
+			want: `2025-03-04T05:06:07.000Z warn hst-name001 acme: hello there`,
+		},
+		{
+			name:    "text with a bad field",
+			cfg:     config.OutputFormatCfg{Format: config.OutputFormat_Text, Template: "{{.Nope}}"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := newLineFormatter(tt.cfg)
+			if err != nil {
+				t.Fatalf("newLineFormatter() error = %v", err)
+			}
+
+			got, err := format([]byte("prefix:"), line)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("format() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && string(got) != "prefix:"+tt.want {
+				t.Errorf("format() got vs want:\n  %s\n  %s", got, "prefix:"+tt.want)
+			}
+		})
+	}
+}
+
+func Test_newLineFormatter_Default(t *testing.T) {
+	format, err := newLineFormatter(config.OutputFormatCfg{})
+	if err != nil || format != nil {
+		t.Errorf("newLineFormatter() = %p, %v, want nil", format, err)
+	}
+}
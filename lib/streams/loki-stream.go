@@ -0,0 +1,421 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/s2"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/log"
+	"github.com/erobsham/reform/lib/types"
+)
+
+//#< Loki Stream
+
+type LokiStream struct {
+	*batchOutput
+
+	client *http.Client
+	cfg    config.OutputLokiCfg
+	log    *slog.Logger
+	format lineFormatter // nil sends the plain message
+
+	// reused across batches, only touched from the runloop
+	bodyBuf   bytes.Buffer
+	encodeBuf []byte
+}
+
+func NewLokiStream(ctx context.Context, cfg config.OutputLokiCfg) (*LokiStream, error) {
+	cfg = cfg.WithDefaults()
+
+	client, err := newHTTPClient(cfg.TLS, cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	format, err := newLineFormatter(cfg.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &LokiStream{
+		client: client,
+		cfg:    cfg,
+		format: format,
+		log:    log.Default().With(slog.String("url", cfg.URL)),
+	}
+	s.batchOutput = newBatchOutput(ctx, cfg.MaxBatchEvents, cfg.BatchWait, s.sendLines, nil)
+
+	return s, nil
+}
+
+func (s *LokiStream) sendLines(lines []types.ParsedLine) {
+	streams := s.groupStreams(lines)
+
+	var body []byte
+	var contentType string
+	var err error
+	switch s.cfg.Encoding {
+	case config.LokiEncoding_JSON:
+		body, err = s.encodeJSON(streams)
+		contentType = "application/json"
+	default:
+		body = s.encodeProtobuf(streams)
+		contentType = "application/x-protobuf"
+	}
+	if err != nil {
+		s.log.
+			Error("unable to encode logs for Loki",
+				slog.Int("numDropped", len(lines)),
+				slog.String("error", err.Error()),
+			)
+		s.failed(lines, err)
+		return
+	}
+
+	policy := retryPolicy{
+		MaxRetries: s.cfg.MaxRetries,
+		Backoff:    s.cfg.Backoff,
+		MaxBackoff: s.cfg.MaxBackoff,
+	}
+	err = retryWithBackoff(s.ctx, s.log, policy, func() error {
+		return s.push(body, contentType)
+	})
+	if err != nil {
+		s.log.
+			Error("error sending logs to Loki, dropping",
+				slog.Int("numDropped", len(lines)),
+				slog.String("error", err.Error()),
+			)
+		s.failed(lines, err)
+		return
+	}
+	s.sent(lines)
+
+	s.log.Info("sent logs",
+		slog.Int("numSent", len(lines)),
+		slog.Int("numStreams", len(streams)),
+	)
+}
+
+func (s *LokiStream) push(body []byte, contentType string) error {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.url(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s.cfg.TenantID != "" {
+		req.Header.Set("X-Scope-OrgID", s.cfg.TenantID)
+	}
+	if s.cfg.Username != "" || s.cfg.Password != "" {
+		req.SetBasicAuth(s.cfg.Username, s.cfg.Password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return HTTPResponseError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			Body:       string(body),
+		}
+	}
+	io.Copy(io.Discard, resp.Body)
+
+	return nil
+}
+
+func (s *LokiStream) url() string {
+	return s.cfg.URL + "/loki/api/v1/push"
+}
+
+//#> Loki Stream
+
+//#< Loki Streams
+
+type lokiLabel struct {
+	name  string
+	value string
+}
+
+type lokiEntry struct {
+	timestamp time.Time
+	line      string
+	metadata  []lokiLabel
+}
+
+// the lines which share a label set
+type lokiStream struct {
+	labels  []lokiLabel
+	entries []lokiEntry
+}
+
+// sorts the lines into streams by their labels, keeping the streams in the
+// order they were first seen.
+func (s *LokiStream) groupStreams(lines []types.ParsedLine) []*lokiStream {
+	streams := []*lokiStream{}
+	byKey := map[string]*lokiStream{}
+
+	for _, line := range lines {
+		labels := s.labelsFor(line)
+		key := formatLokiLabels(labels)
+
+		stream, exists := byKey[key]
+		if !exists {
+			stream = &lokiStream{labels: labels}
+			byKey[key] = stream
+			streams = append(streams, stream)
+		}
+
+		ts := line.Timestamp
+		if ts.IsZero() {
+			// loki requires a timestamp, so fall back to when we saw it
+			ts = time.Now()
+		}
+
+		entry := lokiEntry{timestamp: ts, line: line.Message}
+		if s.format != nil {
+			// falls back to the plain message if the template fails
+			formatted, err := s.format(nil, line)
+			if err != nil {
+				s.log.
+					Error("unable to format log for Loki",
+						slog.String("error", err.Error()),
+					)
+			} else {
+				entry.line = string(formatted)
+			}
+		}
+		if !s.cfg.DisableStructuredMetadata {
+			entry.metadata = s.metadataFor(line)
+		}
+		stream.entries = append(stream.entries, entry)
+	}
+
+	for _, stream := range streams {
+		slices.SortStableFunc(stream.entries, func(a, b lokiEntry) int {
+			return a.timestamp.Compare(b.timestamp)
+		})
+	}
+
+	return streams
+}
+
+func (s *LokiStream) labelsFor(line types.ParsedLine) []lokiLabel {
+	labels := make([]lokiLabel, 0, len(s.cfg.Labels)+len(s.cfg.StaticLabels))
+	for name, value := range s.cfg.StaticLabels {
+		labels = append(labels, lokiLabel{lokiLabelName(name), value})
+	}
+	for _, name := range s.cfg.Labels {
+		if value, ok := line.Field(name); ok {
+			labels = append(labels, lokiLabel{lokiLabelName(name), value})
+		}
+	}
+
+	if len(labels) == 0 {
+		// loki rejects streams without any labels
+		labels = append(labels, lokiLabel{"job", "reform"})
+	}
+
+	// static labels were added first, so they win over any duplicates
+	slices.SortStableFunc(labels, func(a, b lokiLabel) int {
+		return strings.Compare(a.name, b.name)
+	})
+	return slices.CompactFunc(labels, func(a, b lokiLabel) bool {
+		return a.name == b.name
+	})
+}
+
+// everything other than the message and labels gets sent along as
+// structured metadata, so it can still be filtered on without adding to
+// the stream cardinality.
+func (s *LokiStream) metadataFor(line types.ParsedLine) []lokiLabel {
+	metadata := []lokiLabel{}
+	for _, name := range types.FixedFields {
+		if name == types.Field_Message || slices.Contains(s.cfg.Labels, name) {
+			continue
+		}
+		if value, ok := line.Field(name); ok {
+			metadata = append(metadata, lokiLabel{name, value})
+		}
+	}
+	for name := range line.Properties {
+		if slices.Contains(s.cfg.Labels, name) {
+			continue
+		}
+		value, _ := line.Field(name)
+		metadata = append(metadata, lokiLabel{lokiLabelName(name), value})
+	}
+
+	slices.SortFunc(metadata, func(a, b lokiLabel) int {
+		return strings.Compare(a.name, b.name)
+	})
+	return metadata
+}
+
+// label names are limited to `[a-zA-Z_][a-zA-Z0-9_]*`
+func lokiLabelName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		isValid := c == '_' ||
+			(c >= 'a' && c <= 'z') ||
+			(c >= 'A' && c <= 'Z') ||
+			(i > 0 && c >= '0' && c <= '9')
+		if !isValid {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+// formats labels as a selector ie `{host="hst-name001", job="reform"}`,
+// which is what the protobuf api expects.
+func formatLokiLabels(labels []lokiLabel) string {
+	b := strings.Builder{}
+	b.WriteByte('{')
+	for i, label := range labels {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(label.name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(label.value))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+//#> Loki Streams
+
+//#< Loki Encoding
+
+// {"streams":[{"stream":{"host":"..."},"values":[["<unix nanos>","<line>",{"pid":"..."}]]}]}
+func (s *LokiStream) encodeJSON(streams []*lokiStream) ([]byte, error) {
+	type jsonStream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][]any           `json:"values"`
+	}
+
+	req := struct {
+		Streams []jsonStream `json:"streams"`
+	}{Streams: make([]jsonStream, 0, len(streams))}
+
+	for _, stream := range streams {
+		js := jsonStream{
+			Stream: make(map[string]string, len(stream.labels)),
+			Values: make([][]any, 0, len(stream.entries)),
+		}
+		for _, label := range stream.labels {
+			js.Stream[label.name] = label.value
+		}
+
+		for _, entry := range stream.entries {
+			value := []any{strconv.FormatInt(entry.timestamp.UnixNano(), 10), entry.line}
+			if len(entry.metadata) > 0 {
+				metadata := make(map[string]string, len(entry.metadata))
+				for _, m := range entry.metadata {
+					metadata[m.name] = m.value
+				}
+				value = append(value, metadata)
+			}
+			js.Values = append(js.Values, value)
+		}
+
+		req.Streams = append(req.Streams, js)
+	}
+
+	s.bodyBuf.Reset()
+	err := json.NewEncoder(&s.bodyBuf).Encode(req)
+	if err != nil {
+		return nil, err
+	}
+	return s.bodyBuf.Bytes(), nil
+}
+
+// field numbers from loki's `push.proto`
+const (
+	lokiPushRequest_Streams protowire.Number = 1
+
+	lokiStream_Labels  protowire.Number = 1
+	lokiStream_Entries protowire.Number = 2
+
+	lokiEntry_Timestamp          protowire.Number = 1
+	lokiEntry_Line               protowire.Number = 2
+	lokiEntry_StructuredMetadata protowire.Number = 3
+
+	lokiLabelPair_Name  protowire.Number = 1
+	lokiLabelPair_Value protowire.Number = 2
+
+	timestamp_Seconds protowire.Number = 1
+	timestamp_Nanos   protowire.Number = 2
+)
+
+// encodes a snappy compressed `logproto.PushRequest`, by hand -- it's small
+// enough that it isn't worth pulling in loki's generated types.
+//
+// the returned slice is only valid until the next call
+func (s *LokiStream) encodeProtobuf(streams []*lokiStream) []byte {
+	var req, stream, entry, msg []byte
+	for _, st := range streams {
+		stream = stream[:0]
+		stream = protowire.AppendTag(stream, lokiStream_Labels, protowire.BytesType)
+		stream = protowire.AppendString(stream, formatLokiLabels(st.labels))
+
+		for _, e := range st.entries {
+			entry = entry[:0]
+
+			msg = msg[:0]
+			msg = protowire.AppendTag(msg, timestamp_Seconds, protowire.VarintType)
+			msg = protowire.AppendVarint(msg, uint64(e.timestamp.Unix()))
+			if nanos := e.timestamp.Nanosecond(); nanos != 0 {
+				msg = protowire.AppendTag(msg, timestamp_Nanos, protowire.VarintType)
+				msg = protowire.AppendVarint(msg, uint64(nanos))
+			}
+			entry = protowire.AppendTag(entry, lokiEntry_Timestamp, protowire.BytesType)
+			entry = protowire.AppendBytes(entry, msg)
+
+			entry = protowire.AppendTag(entry, lokiEntry_Line, protowire.BytesType)
+			entry = protowire.AppendString(entry, e.line)
+
+			for _, m := range e.metadata {
+				msg = msg[:0]
+				msg = protowire.AppendTag(msg, lokiLabelPair_Name, protowire.BytesType)
+				msg = protowire.AppendString(msg, m.name)
+				msg = protowire.AppendTag(msg, lokiLabelPair_Value, protowire.BytesType)
+				msg = protowire.AppendString(msg, m.value)
+
+				entry = protowire.AppendTag(entry, lokiEntry_StructuredMetadata, protowire.BytesType)
+				entry = protowire.AppendBytes(entry, msg)
+			}
+
+			stream = protowire.AppendTag(stream, lokiStream_Entries, protowire.BytesType)
+			stream = protowire.AppendBytes(stream, entry)
+		}
+
+		req = protowire.AppendTag(req, lokiPushRequest_Streams, protowire.BytesType)
+		req = protowire.AppendBytes(req, stream)
+	}
+
+	s.encodeBuf = s2.EncodeSnappy(s.encodeBuf[:cap(s.encodeBuf)], req)
+	return s.encodeBuf
+}
+
+//#> Loki Encoding
@@ -0,0 +1,342 @@
+package streams
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/s2"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/types"
+)
+
+// the parts of a pushed stream the tests care about, decoded from either encoding
+type pushedLokiStream struct {
+	Labels  string
+	Entries []pushedLokiEntry
+}
+
+type pushedLokiEntry struct {
+	Timestamp int64
+	Line      string
+	Metadata  map[string]string
+}
+
+type stubLokiServer struct {
+	*httptest.Server
+
+	lock         sync.Mutex
+	streams      []pushedLokiStream
+	contentTypes []string
+	tenants      []string
+	errs         []error
+}
+
+func newStubLokiServer() *stubLokiServer {
+	s := &stubLokiServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *stubLokiServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+
+	var streams []pushedLokiStream
+	var err error
+	switch r.Header.Get("Content-Type") {
+	case "application/json":
+		streams, err = decodeLokiJSON(body)
+	case "application/x-protobuf":
+		streams, err = decodeLokiProtobuf(body)
+	default:
+		err = fmt.Errorf("unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+	}
+	if err != nil {
+		s.errs = append(s.errs, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.streams = append(s.streams, streams...)
+	s.contentTypes = append(s.contentTypes, r.Header.Get("Content-Type"))
+	s.tenants = append(s.tenants, r.Header.Get("X-Scope-OrgID"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decodeLokiJSON(body []byte) ([]pushedLokiStream, error) {
+	req := struct {
+		Streams []struct {
+			Stream map[string]string `json:"stream"`
+			Values [][]any           `json:"values"`
+		} `json:"streams"`
+	}{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+
+	streams := []pushedLokiStream{}
+	for _, st := range req.Streams {
+		labels := []lokiLabel{}
+		for k, v := range st.Stream {
+			labels = append(labels, lokiLabel{k, v})
+		}
+		slices.SortFunc(labels, func(a, b lokiLabel) int { return strings.Compare(a.name, b.name) })
+
+		stream := pushedLokiStream{Labels: formatLokiLabels(labels)}
+		for _, value := range st.Values {
+			ts, _ := strconv.ParseInt(value[0].(string), 10, 64)
+			entry := pushedLokiEntry{Timestamp: ts, Line: value[1].(string)}
+			if len(value) > 2 {
+				entry.Metadata = map[string]string{}
+				for k, v := range value[2].(map[string]any) {
+					entry.Metadata[k] = v.(string)
+				}
+			}
+			stream.Entries = append(stream.Entries, entry)
+		}
+		streams = append(streams, stream)
+	}
+	return streams, nil
+}
+
+// walks the fields of a protobuf message, calling `fn` for each one
+func walkProtobuf(data []byte, fn func(num protowire.Number, typ protowire.Type, value []byte, varint uint64)) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			fn(num, typ, nil, v)
+			data = data[n:]
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			fn(num, typ, nil, v)
+			data = data[n:]
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			fn(num, typ, v, 0)
+			data = data[n:]
+		default:
+			return fmt.Errorf("unexpected wire type %v", typ)
+		}
+	}
+	return nil
+}
+
+func decodeLokiProtobuf(body []byte) ([]pushedLokiStream, error) {
+	data, err := s2.Decode(nil, body)
+	if err != nil {
+		return nil, err
+	}
+
+	streams := []pushedLokiStream{}
+	err = walkProtobuf(data, func(_ protowire.Number, _ protowire.Type, streamData []byte, _ uint64) {
+		stream := pushedLokiStream{}
+		walkProtobuf(streamData, func(num protowire.Number, _ protowire.Type, value []byte, _ uint64) {
+			switch num {
+			case lokiStream_Labels:
+				stream.Labels = string(value)
+			case lokiStream_Entries:
+				entry := pushedLokiEntry{}
+				walkProtobuf(value, func(num protowire.Number, _ protowire.Type, value []byte, _ uint64) {
+					switch num {
+					case lokiEntry_Timestamp:
+						var secs, nanos uint64
+						walkProtobuf(value, func(num protowire.Number, _ protowire.Type, _ []byte, v uint64) {
+							if num == timestamp_Seconds {
+								secs = v
+							} else {
+								nanos = v
+							}
+						})
+						entry.Timestamp = time.Unix(int64(secs), int64(nanos)).UnixNano()
+					case lokiEntry_Line:
+						entry.Line = string(value)
+					case lokiEntry_StructuredMetadata:
+						var name, val string
+						walkProtobuf(value, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) {
+							if num == lokiLabelPair_Name {
+								name = string(v)
+							} else {
+								val = string(v)
+							}
+						})
+						if entry.Metadata == nil {
+							entry.Metadata = map[string]string{}
+						}
+						entry.Metadata[name] = val
+					}
+				})
+				stream.Entries = append(stream.Entries, entry)
+			}
+		})
+		streams = append(streams, stream)
+	})
+	return streams, err
+}
+
+func TestLokiStream_Push(t *testing.T) {
+	t0 := time.Date(2025, 3, 4, 5, 6, 7, 8000, time.UTC)
+
+	lines := []types.ParsedLine{
+		{
+			Timestamp: t0.Add(time.Second),
+			Host:      "hst-name001",
+			Process:   types.ProcessInfo{Name: "kernel", PID: 12},
+			Message:   "second",
+			LogLevel:  "warn",
+			Source:    "lab",
+		},
+		{
+			Timestamp:  t0,
+			Host:       "hst-name001",
+			Message:    "first",
+			Source:     "lab",
+			Properties: map[string]any{"request.id": "abc", "retries": 3},
+		},
+		{
+			Timestamp: t0,
+			Host:      "hst-name002",
+			Message:   "other host",
+			Source:    "lab",
+		},
+	}
+
+	wantStreams := []pushedLokiStream{
+		{
+			Labels: `{host="hst-name001", job="reform", source="lab"}`,
+			Entries: []pushedLokiEntry{
+				{Timestamp: t0.UnixNano(), Line: "first", Metadata: map[string]string{"request_id": "abc", "retries": "3"}},
+				{Timestamp: t0.Add(time.Second).UnixNano(), Line: "second", Metadata: map[string]string{"level": "warn", "pid": "12", "proc": "kernel"}},
+			},
+		},
+		{
+			Labels: `{host="hst-name002", job="reform", source="lab"}`,
+			Entries: []pushedLokiEntry{
+				{Timestamp: t0.UnixNano(), Line: "other host"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		encoding        config.LokiEncoding
+		wantContentType string
+	}{
+		{
+			name:            "protobuf",
+			encoding:        config.LokiEncoding_Protobuf,
+			wantContentType: "application/x-protobuf",
+		},
+		{
+			name:            "json",
+			encoding:        config.LokiEncoding_JSON,
+			wantContentType: "application/json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newStubLokiServer()
+			defer server.Close()
+
+			s, err := NewLokiStream(t.Context(), config.OutputLokiCfg{
+				HTTPOutputCfg: config.HTTPOutputCfg{URL: server.URL},
+				TenantID:      "tenant-1",
+				Encoding:      tt.encoding,
+			})
+			if err != nil {
+				t.Fatalf("NewLokiStream() error = %v", err)
+			}
+			for _, line := range lines {
+				s.Output(line)
+			}
+			s.Close()
+
+			if len(server.errs) > 0 {
+				t.Fatalf("server errors = %v", server.errs)
+			}
+			if !reflect.DeepEqual(server.streams, wantStreams) {
+				t.Errorf("pushed streams got = %+v, want %+v", server.streams, wantStreams)
+			}
+			for i, contentType := range server.contentTypes {
+				if contentType != tt.wantContentType {
+					t.Errorf("Content-Type = %q, want %q", contentType, tt.wantContentType)
+				}
+				if server.tenants[i] != "tenant-1" {
+					t.Errorf("X-Scope-OrgID = %q, want %q", server.tenants[i], "tenant-1")
+				}
+			}
+		})
+	}
+}
+
+func TestLokiStream_Batching(t *testing.T) {
+	server := newStubLokiServer()
+	defer server.Close()
+
+	s, err := NewLokiStream(t.Context(), config.OutputLokiCfg{
+		HTTPOutputCfg: config.HTTPOutputCfg{
+			URL:            server.URL,
+			MaxBatchEvents: 2,
+			BatchWait:      time.Hour,
+		},
+		Labels:                    []string{types.Field_Level},
+		StaticLabels:              map[string]string{},
+		DisableStructuredMetadata: true,
+	})
+	if err != nil {
+		t.Fatalf("NewLokiStream() error = %v", err)
+	}
+
+	for i := range 5 {
+		s.Output(types.ParsedLine{Message: strconv.Itoa(i), LogLevel: "info", Host: "ignored"})
+	}
+	s.Close()
+
+	if len(server.contentTypes) != 3 {
+		t.Errorf("got %v pushes, want %v", len(server.contentTypes), 3)
+	}
+	count := 0
+	for _, stream := range server.streams {
+		if stream.Labels != `{level="info"}` {
+			t.Errorf("labels = %v, want %v", stream.Labels, `{level="info"}`)
+		}
+		for _, entry := range stream.Entries {
+			if entry.Metadata != nil {
+				t.Errorf("unexpected structured metadata: %v", entry.Metadata)
+			}
+			count += 1
+		}
+	}
+	if count != 5 {
+		t.Errorf("got %v entries, want %v", count, 5)
+	}
+}
@@ -0,0 +1,92 @@
+package streams
+
+import (
+	"crypto/tls"
+	"net"
+	"time"
+
+	"github.com/erobsham/reform/lib/config"
+)
+
+//#< Network Writer
+
+// netWriter writes to a udp / tcp / tls socket, (re)connecting as needed.
+// it isn't safe for concurrent use.
+type netWriter struct {
+	cfg       config.NetOutputCfg
+	tlsConfig *tls.Config
+
+	conn net.Conn
+}
+
+func newNetWriter(cfg config.NetOutputCfg) (*netWriter, error) {
+	cfg = cfg.WithDefaults()
+	w := &netWriter{cfg: cfg}
+
+	if cfg.Protocol == config.NetProtocol_TLS {
+		tlsConfig, err := newTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		if tlsConfig.ServerName == "" {
+			host, _, _ := net.SplitHostPort(cfg.Address)
+			tlsConfig.ServerName = host
+		}
+		w.tlsConfig = tlsConfig
+	}
+
+	return w, nil
+}
+
+func (w *netWriter) connect() error {
+	dialer := &net.Dialer{Timeout: w.cfg.Timeout}
+
+	var conn net.Conn
+	var err error
+	switch w.cfg.Protocol {
+	case config.NetProtocol_TLS:
+		conn, err = tls.DialWithDialer(dialer, "tcp", w.cfg.Address, w.tlsConfig)
+	case config.NetProtocol_TCP:
+		conn, err = dialer.Dial("tcp", w.cfg.Address)
+	default:
+		conn, err = dialer.Dial("udp", w.cfg.Address)
+	}
+	if err != nil {
+		return err
+	}
+
+	w.conn = conn
+	return nil
+}
+
+// writes `data` as a single datagram (udp), or onto the stream (tcp / tls).
+// on failure the connection is dropped, so the next write reconnects.
+func (w *netWriter) write(data []byte) error {
+	if w.conn == nil {
+		err := w.connect()
+		if err != nil {
+			return err
+		}
+	}
+
+	w.conn.SetWriteDeadline(time.Now().Add(w.cfg.Timeout))
+	_, err := w.conn.Write(data)
+	if err != nil {
+		w.close()
+		return err
+	}
+	return nil
+}
+
+func (w *netWriter) isStream() bool {
+	return w.cfg.Protocol != config.NetProtocol_UDP
+}
+
+func (w *netWriter) close() {
+	if w.conn != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+}
+
+//#> Network Writer
@@ -0,0 +1,653 @@
+package streams
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/log"
+	"github.com/erobsham/reform/lib/types"
+)
+
+//#< OTLP Stream
+
+// OTLPStream exports lines as OpenTelemetry `LogRecord`s over OTLP/HTTP.
+type OTLPStream struct {
+	*batchOutput
+
+	client *http.Client
+	cfg    config.OutputOTLPCfg
+	log    *slog.Logger
+
+	// reused across batches, only touched from the runloop
+	bodyBuf  bytes.Buffer
+	protoBuf []byte
+	gzipBuf  bytes.Buffer
+	gzipper  *gzip.Writer
+}
+
+func NewOTLPStream(ctx context.Context, cfg config.OutputOTLPCfg) (*OTLPStream, error) {
+	cfg = cfg.WithDefaults()
+
+	client, err := newHTTPClient(cfg.TLS, cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &OTLPStream{
+		client: client,
+		cfg:    cfg,
+		log:    log.Default().With(slog.String("url", cfg.URL)),
+	}
+	if cfg.Gzip {
+		s.gzipper = gzip.NewWriter(&s.gzipBuf)
+	}
+	s.batchOutput = newBatchOutput(ctx, cfg.MaxBatchEvents, cfg.BatchWait, s.sendLines, nil)
+
+	return s, nil
+}
+
+func (s *OTLPStream) sendLines(lines []types.ParsedLine) {
+	resources := s.groupResources(lines, time.Now())
+
+	var body []byte
+	var contentType string
+	var err error
+	switch s.cfg.Encoding {
+	case config.OTLPEncoding_JSON:
+		body, err = s.encodeJSON(resources)
+		contentType = "application/json"
+	default:
+		body = s.encodeProtobuf(resources)
+		contentType = "application/x-protobuf"
+	}
+	if err == nil && s.gzipper != nil {
+		body, err = s.compress(body)
+	}
+	if err != nil {
+		s.log.
+			Error("unable to encode logs for OTLP",
+				slog.Int("numDropped", len(lines)),
+				slog.String("error", err.Error()),
+			)
+		s.failed(lines, err)
+		return
+	}
+
+	policy := retryPolicy{
+		MaxRetries:  s.cfg.MaxRetries,
+		Backoff:     s.cfg.Backoff,
+		MaxBackoff:  s.cfg.MaxBackoff,
+		IsRetriable: isRetriableOTLPError,
+	}
+	var rejected int64
+	err = retryWithBackoff(s.ctx, s.log, policy, func() error {
+		var err error
+		rejected, err = s.export(body, contentType)
+		return err
+	})
+	if err != nil {
+		s.log.
+			Error("error exporting logs over OTLP, dropping",
+				slog.Int("numDropped", len(lines)),
+				slog.String("error", err.Error()),
+			)
+		s.failed(lines, err)
+		return
+	}
+
+	delivery := Delivery{Sent: len(lines) - int(rejected)}
+	if rejected > 0 {
+		// the collector doesn't say which records it rejected
+		delivery.Lost = int(rejected)
+		delivery.Err = fmt.Errorf("collector rejected %d log records", rejected)
+	}
+	s.delivered(delivery)
+
+	s.log.Info("sent logs",
+		slog.Int("numSent", len(lines)-int(rejected)),
+	)
+}
+
+// posts the request, returning the number of records the collector rejected
+func (s *OTLPStream) export(body []byte, contentType string) (int64, error) {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.url(), bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s.gzipper != nil {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, HTTPResponseError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			Body:       string(respBody[:min(len(respBody), 4096)]),
+		}
+	}
+
+	rejected, msg := parseOTLPPartialSuccess(respBody, resp.Header.Get("Content-Type"))
+	if rejected > 0 || msg != "" {
+		// the collector accepted the request, resending won't help
+		s.log.
+			Warn("OTLP collector rejected some log records",
+				slog.Int64("numRejected", rejected),
+				slog.String("error", msg),
+			)
+	}
+
+	return rejected, nil
+}
+
+func (s *OTLPStream) url() string {
+	if strings.HasSuffix(s.cfg.URL, "/v1/logs") {
+		return s.cfg.URL
+	}
+	return s.cfg.URL + "/v1/logs"
+}
+
+// the returned slice is only valid until the next call
+func (s *OTLPStream) compress(body []byte) ([]byte, error) {
+	s.gzipBuf.Reset()
+	s.gzipper.Reset(&s.gzipBuf)
+
+	_, err := s.gzipper.Write(body)
+	if err != nil {
+		return nil, err
+	}
+	err = s.gzipper.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.gzipBuf.Bytes(), nil
+}
+
+// the OTLP spec only allows retrying a few statuses (and connection errors)
+// see: https://opentelemetry.io/docs/specs/otlp/#retryable-response-codes
+func isRetriableOTLPError(err error) bool {
+	var respErr HTTPResponseError
+	if !errors.As(err, &respErr) {
+		return !errors.Is(err, context.Canceled)
+	}
+
+	switch respErr.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+//#> OTLP Stream
+
+//#< OTLP Records
+
+const otlpScopeName = "reform"
+
+// semantic convention attribute names
+const (
+	otlpAttr_HostName       = "host.name"
+	otlpAttr_ServiceName    = "service.name"
+	otlpAttr_ProcessName    = "process.executable.name"
+	otlpAttr_ProcessPID     = "process.pid"
+	otlpAttr_ThreadID       = "thread.id"
+	otlpAttr_CodeFilePath   = "code.file.path"
+	otlpAttr_CodeLineNumber = "code.line.number"
+)
+
+type otlpAttr struct {
+	key   string
+	value any
+}
+
+type otlpRecord struct {
+	timeNanos     uint64
+	observedNanos uint64
+	severity      int
+	severityText  string
+	body          string
+	attrs         []otlpAttr
+}
+
+// the records from the same source + host
+type otlpResource struct {
+	attrs   []otlpAttr
+	records []otlpRecord
+}
+
+func (s *OTLPStream) groupResources(lines []types.ParsedLine, observed time.Time) []*otlpResource {
+	resources := []*otlpResource{}
+	byKey := map[string]*otlpResource{}
+
+	for _, line := range lines {
+		key := line.Source + "\x00" + line.Host
+		resource, exists := byKey[key]
+		if !exists {
+			resource = &otlpResource{attrs: s.resourceAttrs(line)}
+			byKey[key] = resource
+			resources = append(resources, resource)
+		}
+
+		record := otlpRecord{
+			observedNanos: uint64(observed.UnixNano()),
+			severity:      types.OTelSeverity(line.LogLevel),
+			severityText:  line.LogLevel,
+			body:          line.Message,
+			attrs:         recordAttrs(line),
+		}
+		if !line.Timestamp.IsZero() {
+			// left unset if unknown, the observed time stands in for it
+			record.timeNanos = uint64(line.Timestamp.UnixNano())
+		}
+		resource.records = append(resource.records, record)
+	}
+
+	return resources
+}
+
+func (s *OTLPStream) resourceAttrs(line types.ParsedLine) []otlpAttr {
+	serviceName := line.Source
+	if serviceName == "" {
+		serviceName = otlpScopeName
+	}
+
+	attrs := []otlpAttr{{otlpAttr_ServiceName, serviceName}}
+	if line.Host != "" {
+		attrs = append(attrs, otlpAttr{otlpAttr_HostName, line.Host})
+	}
+	for _, key := range slices.Sorted(maps.Keys(s.cfg.ResourceAttributes)) {
+		if key == otlpAttr_ServiceName || key == otlpAttr_HostName {
+			continue
+		}
+		attrs = append(attrs, otlpAttr{key, s.cfg.ResourceAttributes[key]})
+	}
+	return attrs
+}
+
+func recordAttrs(line types.ParsedLine) []otlpAttr {
+	attrs := []otlpAttr{}
+	if line.Process.Name != "" {
+		attrs = append(attrs, otlpAttr{otlpAttr_ProcessName, line.Process.Name})
+	}
+	if line.Process.PID != 0 {
+		attrs = append(attrs, otlpAttr{otlpAttr_ProcessPID, int64(line.Process.PID)})
+	}
+	if line.Process.TID != 0 {
+		attrs = append(attrs, otlpAttr{otlpAttr_ThreadID, int64(line.Process.TID)})
+	}
+	if line.SourceInfo.Filename != "" {
+		attrs = append(attrs, otlpAttr{otlpAttr_CodeFilePath, line.SourceInfo.Filename})
+	}
+	if line.SourceInfo.LineNumber != 0 {
+		attrs = append(attrs, otlpAttr{otlpAttr_CodeLineNumber, int64(line.SourceInfo.LineNumber)})
+	}
+	for _, key := range slices.Sorted(maps.Keys(line.Properties)) {
+		attrs = append(attrs, otlpAttr{key, line.Properties[key]})
+	}
+	return attrs
+}
+
+//#> OTLP Records
+
+//#< OTLP Encoding
+
+// field numbers from opentelemetry-proto's `logs.proto` / `common.proto`
+const (
+	otlpRequest_ResourceLogs protowire.Number = 1
+
+	otlpResourceLogs_Resource  protowire.Number = 1
+	otlpResourceLogs_ScopeLogs protowire.Number = 2
+	otlpResource_Attributes    protowire.Number = 1
+
+	otlpScopeLogs_Scope      protowire.Number = 1
+	otlpScopeLogs_LogRecords protowire.Number = 2
+	otlpScope_Name           protowire.Number = 1
+
+	otlpLogRecord_TimeUnixNano         protowire.Number = 1
+	otlpLogRecord_SeverityNumber       protowire.Number = 2
+	otlpLogRecord_SeverityText         protowire.Number = 3
+	otlpLogRecord_Body                 protowire.Number = 5
+	otlpLogRecord_Attributes           protowire.Number = 6
+	otlpLogRecord_ObservedTimeUnixNano protowire.Number = 11
+
+	otlpKeyValue_Key   protowire.Number = 1
+	otlpKeyValue_Value protowire.Number = 2
+
+	otlpAnyValue_String protowire.Number = 1
+	otlpAnyValue_Bool   protowire.Number = 2
+	otlpAnyValue_Int    protowire.Number = 3
+	otlpAnyValue_Double protowire.Number = 4
+	otlpAnyValue_Array  protowire.Number = 5
+	otlpAnyValue_KVList protowire.Number = 6
+
+	// both `ArrayValue` and `KeyValueList` only have a `values` field
+	otlpList_Values protowire.Number = 1
+
+	otlpResponse_PartialSuccess protowire.Number = 1
+	otlpPartialSuccess_Rejected protowire.Number = 1
+	otlpPartialSuccess_ErrorMsg protowire.Number = 2
+)
+
+// appends a length delimited sub-message built by `fn`
+func appendProtoMessage(b []byte, num protowire.Number, fn func(b []byte) []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, fn(nil))
+}
+
+// encodes an `ExportLogsServiceRequest`
+//
+// the returned slice is only valid until the next call
+func (s *OTLPStream) encodeProtobuf(resources []*otlpResource) []byte {
+	req := s.protoBuf[:0]
+	for _, resource := range resources {
+		req = appendProtoMessage(req, otlpRequest_ResourceLogs, func(b []byte) []byte {
+			b = appendProtoMessage(b, otlpResourceLogs_Resource, func(b []byte) []byte {
+				return appendOTLPAttrs(b, otlpResource_Attributes, resource.attrs)
+			})
+			return appendProtoMessage(b, otlpResourceLogs_ScopeLogs, func(b []byte) []byte {
+				b = appendProtoMessage(b, otlpScopeLogs_Scope, func(b []byte) []byte {
+					b = protowire.AppendTag(b, otlpScope_Name, protowire.BytesType)
+					return protowire.AppendString(b, otlpScopeName)
+				})
+				for _, record := range resource.records {
+					b = appendProtoMessage(b, otlpScopeLogs_LogRecords, func(b []byte) []byte {
+						return appendOTLPRecord(b, record)
+					})
+				}
+				return b
+			})
+		})
+	}
+	s.protoBuf = req
+	return req
+}
+
+func appendOTLPRecord(b []byte, record otlpRecord) []byte {
+	if record.timeNanos != 0 {
+		b = protowire.AppendTag(b, otlpLogRecord_TimeUnixNano, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, record.timeNanos)
+	}
+	b = protowire.AppendTag(b, otlpLogRecord_ObservedTimeUnixNano, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, record.observedNanos)
+	if record.severity != 0 {
+		b = protowire.AppendTag(b, otlpLogRecord_SeverityNumber, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(record.severity))
+	}
+	if record.severityText != "" {
+		b = protowire.AppendTag(b, otlpLogRecord_SeverityText, protowire.BytesType)
+		b = protowire.AppendString(b, record.severityText)
+	}
+	b = appendProtoMessage(b, otlpLogRecord_Body, func(b []byte) []byte {
+		return appendOTLPValue(b, record.body)
+	})
+	return appendOTLPAttrs(b, otlpLogRecord_Attributes, record.attrs)
+}
+
+func appendOTLPAttrs(b []byte, num protowire.Number, attrs []otlpAttr) []byte {
+	for _, attr := range attrs {
+		b = appendProtoMessage(b, num, func(b []byte) []byte {
+			b = protowire.AppendTag(b, otlpKeyValue_Key, protowire.BytesType)
+			b = protowire.AppendString(b, attr.key)
+			return appendProtoMessage(b, otlpKeyValue_Value, func(b []byte) []byte {
+				return appendOTLPValue(b, attr.value)
+			})
+		})
+	}
+	return b
+}
+
+// appends the fields of an `AnyValue`
+func appendOTLPValue(b []byte, value any) []byte {
+	switch v := value.(type) {
+	case nil:
+		return b
+	case string:
+		b = protowire.AppendTag(b, otlpAnyValue_String, protowire.BytesType)
+		return protowire.AppendString(b, v)
+	case bool:
+		b = protowire.AppendTag(b, otlpAnyValue_Bool, protowire.VarintType)
+		return protowire.AppendVarint(b, protowire.EncodeBool(v))
+	case float64:
+		b = protowire.AppendTag(b, otlpAnyValue_Double, protowire.Fixed64Type)
+		return protowire.AppendFixed64(b, math.Float64bits(v))
+	case []any:
+		return appendProtoMessage(b, otlpAnyValue_Array, func(b []byte) []byte {
+			for _, item := range v {
+				b = appendProtoMessage(b, otlpList_Values, func(b []byte) []byte {
+					return appendOTLPValue(b, item)
+				})
+			}
+			return b
+		})
+	case map[string]any:
+		return appendProtoMessage(b, otlpAnyValue_KVList, func(b []byte) []byte {
+			attrs := []otlpAttr{}
+			for _, key := range slices.Sorted(maps.Keys(v)) {
+				attrs = append(attrs, otlpAttr{key, v[key]})
+			}
+			return appendOTLPAttrs(b, otlpList_Values, attrs)
+		})
+	}
+
+	if i, ok := otlpInt(value); ok {
+		b = protowire.AppendTag(b, otlpAnyValue_Int, protowire.VarintType)
+		return protowire.AppendVarint(b, uint64(i))
+	}
+
+	b = protowire.AppendTag(b, otlpAnyValue_String, protowire.BytesType)
+	return protowire.AppendString(b, fmt.Sprint(value))
+}
+
+func otlpInt(value any) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return int64(v), true
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+// OTLP/JSON uses camelCase field names, and 64 bit ints as strings
+func (s *OTLPStream) encodeJSON(resources []*otlpResource) ([]byte, error) {
+	resourceLogs := make([]map[string]any, 0, len(resources))
+	for _, resource := range resources {
+		records := make([]map[string]any, 0, len(resource.records))
+		for _, record := range resource.records {
+			r := map[string]any{
+				"observedTimeUnixNano": strconv.FormatUint(record.observedNanos, 10),
+				"body":                 otlpJSONValue(record.body),
+				"attributes":           otlpJSONAttrs(record.attrs),
+			}
+			if record.timeNanos != 0 {
+				r["timeUnixNano"] = strconv.FormatUint(record.timeNanos, 10)
+			}
+			if record.severity != 0 {
+				r["severityNumber"] = record.severity
+			}
+			if record.severityText != "" {
+				r["severityText"] = record.severityText
+			}
+			records = append(records, r)
+		}
+
+		resourceLogs = append(resourceLogs, map[string]any{
+			"resource": map[string]any{"attributes": otlpJSONAttrs(resource.attrs)},
+			"scopeLogs": []map[string]any{{
+				"scope":      map[string]any{"name": otlpScopeName},
+				"logRecords": records,
+			}},
+		})
+	}
+
+	s.bodyBuf.Reset()
+	err := json.NewEncoder(&s.bodyBuf).Encode(map[string]any{"resourceLogs": resourceLogs})
+	if err != nil {
+		return nil, err
+	}
+	return s.bodyBuf.Bytes(), nil
+}
+
+func otlpJSONAttrs(attrs []otlpAttr) []map[string]any {
+	kvs := make([]map[string]any, 0, len(attrs))
+	for _, attr := range attrs {
+		kvs = append(kvs, map[string]any{"key": attr.key, "value": otlpJSONValue(attr.value)})
+	}
+	return kvs
+}
+
+func otlpJSONValue(value any) map[string]any {
+	switch v := value.(type) {
+	case nil:
+		return map[string]any{}
+	case string:
+		return map[string]any{"stringValue": v}
+	case bool:
+		return map[string]any{"boolValue": v}
+	case float64:
+		return map[string]any{"doubleValue": v}
+	case []any:
+		values := make([]map[string]any, 0, len(v))
+		for _, item := range v {
+			values = append(values, otlpJSONValue(item))
+		}
+		return map[string]any{"arrayValue": map[string]any{"values": values}}
+	case map[string]any:
+		attrs := []otlpAttr{}
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			attrs = append(attrs, otlpAttr{key, v[key]})
+		}
+		return map[string]any{"kvlistValue": map[string]any{"values": otlpJSONAttrs(attrs)}}
+	}
+
+	if i, ok := otlpInt(value); ok {
+		return map[string]any{"intValue": strconv.FormatInt(i, 10)}
+	}
+	return map[string]any{"stringValue": fmt.Sprint(value)}
+}
+
+// pulls the `partial_success` out of an `ExportLogsServiceResponse`
+func parseOTLPPartialSuccess(body []byte, contentType string) (rejected int64, msg string) {
+	if len(body) == 0 {
+		return 0, ""
+	}
+
+	if strings.HasPrefix(contentType, "application/json") {
+		resp := struct {
+			PartialSuccess struct {
+				// int64 as a string, but some collectors send a number
+				RejectedLogRecords json.RawMessage `json:"rejectedLogRecords"`
+				ErrorMessage       string          `json:"errorMessage"`
+			} `json:"partialSuccess"`
+		}{}
+		if json.Unmarshal(body, &resp) != nil {
+			return 0, ""
+		}
+		rejected, _ = strconv.ParseInt(strings.Trim(string(resp.PartialSuccess.RejectedLogRecords), `"`), 10, 64)
+		return rejected, resp.PartialSuccess.ErrorMessage
+	}
+
+	partial := consumeProtoField(body, otlpResponse_PartialSuccess)
+	if partial == nil {
+		return 0, ""
+	}
+	for len(partial) > 0 {
+		num, typ, n := protowire.ConsumeTag(partial)
+		if n < 0 {
+			break
+		}
+		partial = partial[n:]
+
+		switch {
+		case num == otlpPartialSuccess_Rejected && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(partial)
+			if n < 0 {
+				return rejected, msg
+			}
+			rejected = int64(v)
+			partial = partial[n:]
+		case num == otlpPartialSuccess_ErrorMsg && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(partial)
+			if n < 0 {
+				return rejected, msg
+			}
+			msg = string(v)
+			partial = partial[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, partial)
+			if n < 0 {
+				return rejected, msg
+			}
+			partial = partial[n:]
+		}
+	}
+	return rejected, msg
+}
+
+// returns the contents of the first length delimited field `num`
+func consumeProtoField(data []byte, num protowire.Number) []byte {
+	for len(data) > 0 {
+		fieldNum, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil
+		}
+		data = data[n:]
+
+		if fieldNum == num && typ == protowire.BytesType {
+			v, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return nil
+			}
+			return v
+		}
+
+		n = protowire.ConsumeFieldValue(fieldNum, typ, data)
+		if n < 0 {
+			return nil
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+//#> OTLP Encoding
@@ -0,0 +1,389 @@
+package streams
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/types"
+)
+
+// the parts of an exported resource the tests care about, decoded from either encoding
+type exportedOTLPResource struct {
+	Attrs   map[string]string
+	Records []exportedOTLPRecord
+}
+
+type exportedOTLPRecord struct {
+	Time         uint64
+	Severity     int
+	SeverityText string
+	Body         string
+	Attrs        map[string]string
+}
+
+type stubOTLPCollector struct {
+	*httptest.Server
+
+	failures   int
+	failStatus int
+
+	lock      sync.Mutex
+	requests  int
+	paths     []string
+	resources []exportedOTLPResource
+	errs      []error
+}
+
+func newStubOTLPCollector(failures int, failStatus int) *stubOTLPCollector {
+	s := &stubOTLPCollector{failures: failures, failStatus: failStatus}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *stubOTLPCollector) handle(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.requests += 1
+	if s.requests <= s.failures {
+		w.WriteHeader(s.failStatus)
+		return
+	}
+	s.paths = append(s.paths, r.URL.Path)
+
+	var reader io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			s.errs = append(s.errs, err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		reader = gz
+	}
+	body, _ := io.ReadAll(reader)
+
+	var resources []exportedOTLPResource
+	var err error
+	switch r.Header.Get("Content-Type") {
+	case "application/json":
+		resources, err = decodeOTLPJSON(body)
+	case "application/x-protobuf":
+		resources, err = decodeOTLPProtobuf(body)
+	default:
+		err = fmt.Errorf("unexpected Content-Type: %q", r.Header.Get("Content-Type"))
+	}
+	if err != nil {
+		s.errs = append(s.errs, err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	s.resources = append(s.resources, resources...)
+	w.WriteHeader(http.StatusOK)
+}
+
+func decodeOTLPJSON(body []byte) ([]exportedOTLPResource, error) {
+	type jsonKV struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+	req := struct {
+		ResourceLogs []struct {
+			Resource struct {
+				Attributes []jsonKV `json:"attributes"`
+			} `json:"resource"`
+			ScopeLogs []struct {
+				LogRecords []struct {
+					TimeUnixNano   string         `json:"timeUnixNano"`
+					SeverityNumber int            `json:"severityNumber"`
+					SeverityText   string         `json:"severityText"`
+					Body           map[string]any `json:"body"`
+					Attributes     []jsonKV       `json:"attributes"`
+				} `json:"logRecords"`
+			} `json:"scopeLogs"`
+		} `json:"resourceLogs"`
+	}{}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+
+	attrs := func(kvs []jsonKV) map[string]string {
+		m := map[string]string{}
+		for _, kv := range kvs {
+			for _, v := range kv.Value {
+				m[kv.Key] = fmt.Sprint(v)
+			}
+		}
+		return m
+	}
+
+	resources := []exportedOTLPResource{}
+	for _, rl := range req.ResourceLogs {
+		resource := exportedOTLPResource{Attrs: attrs(rl.Resource.Attributes)}
+		for _, sl := range rl.ScopeLogs {
+			for _, lr := range sl.LogRecords {
+				ts, _ := strconv.ParseUint(lr.TimeUnixNano, 10, 64)
+				resource.Records = append(resource.Records, exportedOTLPRecord{
+					Time:         ts,
+					Severity:     lr.SeverityNumber,
+					SeverityText: lr.SeverityText,
+					Body:         fmt.Sprint(lr.Body["stringValue"]),
+					Attrs:        attrs(lr.Attributes),
+				})
+			}
+		}
+		resources = append(resources, resource)
+	}
+	return resources, nil
+}
+
+func decodeOTLPProtobuf(body []byte) ([]exportedOTLPResource, error) {
+	anyValue := func(data []byte) string {
+		var str string
+		walkProtobuf(data, func(num protowire.Number, _ protowire.Type, value []byte, v uint64) {
+			switch num {
+			case otlpAnyValue_String:
+				str = string(value)
+			case otlpAnyValue_Bool:
+				str = strconv.FormatBool(v != 0)
+			case otlpAnyValue_Int:
+				str = strconv.FormatInt(int64(v), 10)
+			case otlpAnyValue_Double:
+				str = fmt.Sprint(math.Float64frombits(v))
+			}
+		})
+		return str
+	}
+	keyValue := func(data []byte, into map[string]string) {
+		var key, value string
+		walkProtobuf(data, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) {
+			if num == otlpKeyValue_Key {
+				key = string(v)
+			} else {
+				value = anyValue(v)
+			}
+		})
+		into[key] = value
+	}
+
+	resources := []exportedOTLPResource{}
+	err := walkProtobuf(body, func(_ protowire.Number, _ protowire.Type, rlData []byte, _ uint64) {
+		resource := exportedOTLPResource{Attrs: map[string]string{}}
+		walkProtobuf(rlData, func(num protowire.Number, _ protowire.Type, value []byte, _ uint64) {
+			switch num {
+			case otlpResourceLogs_Resource:
+				walkProtobuf(value, func(_ protowire.Number, _ protowire.Type, kv []byte, _ uint64) {
+					keyValue(kv, resource.Attrs)
+				})
+			case otlpResourceLogs_ScopeLogs:
+				walkProtobuf(value, func(num protowire.Number, _ protowire.Type, lrData []byte, _ uint64) {
+					if num != otlpScopeLogs_LogRecords {
+						return
+					}
+					record := exportedOTLPRecord{Attrs: map[string]string{}}
+					walkProtobuf(lrData, func(num protowire.Number, _ protowire.Type, value []byte, v uint64) {
+						switch num {
+						case otlpLogRecord_TimeUnixNano:
+							record.Time = v
+						case otlpLogRecord_SeverityNumber:
+							record.Severity = int(v)
+						case otlpLogRecord_SeverityText:
+							record.SeverityText = string(value)
+						case otlpLogRecord_Body:
+							record.Body = anyValue(value)
+						case otlpLogRecord_Attributes:
+							keyValue(value, record.Attrs)
+						}
+					})
+					resource.Records = append(resource.Records, record)
+				})
+			}
+		})
+		resources = append(resources, resource)
+	})
+	return resources, err
+}
+
+func TestOTLPStream_Export(t *testing.T) {
+	ts := time.Date(2025, 3, 4, 5, 6, 7, 8, time.UTC)
+
+	lines := []types.ParsedLine{
+		{
+			Timestamp:  ts,
+			Host:       "hst-name001",
+			Process:    types.ProcessInfo{Name: "acme", PID: 12},
+			Message:    "hello",
+			LogLevel:   "warn",
+			SourceInfo: types.SourceFileInfo{Filename: "main.c", LineNumber: 890},
+			Source:     "lab",
+			Properties: map[string]any{"user": "bob", "retries": float64(3)},
+		},
+		{
+			Host:    "hst-name002",
+			Message: "no timestamp",
+			Source:  "lab",
+		},
+	}
+
+	wantResources := []exportedOTLPResource{
+		{
+			Attrs: map[string]string{"service.name": "lab", "host.name": "hst-name001", "deployment.environment": "test"},
+			Records: []exportedOTLPRecord{{
+				Time:         uint64(ts.UnixNano()),
+				Severity:     types.OTelSeverity_Warn,
+				SeverityText: "warn",
+				Body:         "hello",
+				Attrs: map[string]string{
+					"process.executable.name": "acme",
+					"process.pid":             "12",
+					"code.file.path":          "main.c",
+					"code.line.number":        "890",
+					"user":                    "bob",
+					"retries":                 "3",
+				},
+			}},
+		},
+		{
+			Attrs: map[string]string{"service.name": "lab", "host.name": "hst-name002", "deployment.environment": "test"},
+			Records: []exportedOTLPRecord{{
+				Body:  "no timestamp",
+				Attrs: map[string]string{},
+			}},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		encoding   config.OTLPEncoding
+		gzip       bool
+		failures   int
+		failStatus int
+		want       []exportedOTLPResource
+	}{
+		{
+			name:     "protobuf",
+			encoding: config.OTLPEncoding_Protobuf,
+			want:     wantResources,
+		},
+		{
+			name:     "json",
+			encoding: config.OTLPEncoding_JSON,
+			want:     wantResources,
+		},
+		{
+			name:     "gzip",
+			encoding: config.OTLPEncoding_Protobuf,
+			gzip:     true,
+			want:     wantResources,
+		},
+		{
+			name:       "retry after 503",
+			failures:   1,
+			failStatus: http.StatusServiceUnavailable,
+			want:       wantResources,
+		},
+		{
+			name:       "no retry after 500",
+			failures:   1,
+			failStatus: http.StatusInternalServerError,
+			want:       nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newStubOTLPCollector(tt.failures, tt.failStatus)
+			defer server.Close()
+
+			s, err := NewOTLPStream(t.Context(), config.OutputOTLPCfg{
+				HTTPOutputCfg:      config.HTTPOutputCfg{URL: server.URL, Backoff: time.Millisecond},
+				Encoding:           tt.encoding,
+				Gzip:               tt.gzip,
+				ResourceAttributes: map[string]string{"deployment.environment": "test"},
+			})
+			if err != nil {
+				t.Fatalf("NewOTLPStream() error = %v", err)
+			}
+			for _, line := range lines {
+				s.Output(line)
+			}
+			s.Close()
+
+			if len(server.errs) > 0 {
+				t.Fatalf("server errors = %v", server.errs)
+			}
+			if !reflect.DeepEqual(server.resources, tt.want) {
+				t.Errorf("exported got vs want:\n  %+v\n  %+v", server.resources, tt.want)
+			}
+			for _, path := range server.paths {
+				if path != "/v1/logs" {
+					t.Errorf("path = %v, want %v", path, "/v1/logs")
+				}
+			}
+		})
+	}
+}
+
+func Test_parseOTLPPartialSuccess(t *testing.T) {
+	var partial []byte
+	partial = protowire.AppendTag(partial, otlpPartialSuccess_Rejected, protowire.VarintType)
+	partial = protowire.AppendVarint(partial, 2)
+	partial = protowire.AppendTag(partial, otlpPartialSuccess_ErrorMsg, protowire.BytesType)
+	partial = protowire.AppendString(partial, "too old")
+	var resp []byte
+	resp = protowire.AppendTag(resp, otlpResponse_PartialSuccess, protowire.BytesType)
+	resp = protowire.AppendBytes(resp, partial)
+
+	tests := []struct {
+		name         string
+		body         []byte
+		contentType  string
+		wantRejected int64
+		wantMsg      string
+	}{
+		{
+			name:        "empty",
+			contentType: "application/x-protobuf",
+		},
+		{
+			name:         "protobuf",
+			body:         resp,
+			contentType:  "application/x-protobuf",
+			wantRejected: 2,
+			wantMsg:      "too old",
+		},
+		{
+			name:         "json",
+			body:         []byte(`{"partialSuccess":{"rejectedLogRecords":"2","errorMessage":"too old"}}`),
+			contentType:  "application/json",
+			wantRejected: 2,
+			wantMsg:      "too old",
+		},
+		{
+			name:        "json success",
+			body:        []byte(`{}`),
+			contentType: "application/json; charset=utf-8",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rejected, msg := parseOTLPPartialSuccess(tt.body, tt.contentType)
+			if rejected != tt.wantRejected || msg != tt.wantMsg {
+				t.Errorf("parseOTLPPartialSuccess() = %v, %q, want %v, %q", rejected, msg, tt.wantRejected, tt.wantMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,597 @@
+package streams
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/log"
+	"github.com/erobsham/reform/lib/types"
+)
+
+//#< Queued Output Stream
+
+// NewQueuedOutput wraps `out` with its own bounded queue + worker goroutine,
+// so a slow (or stalled) output doesn't hold up any other outputs.
+//
+// `onFatal` is called if the output fails while using the `fail-fast` error policy.
+//
+// an `AsyncOutputStream` reports the lines it sent / gave up on later, so they're
+// counted (and dead lettered, or with the `retry` policy, queued to be resent) then.
+func NewQueuedOutput(name string, out OutputStream, cfg config.OutputQueueCfg, errCfg config.OutputErrorCfg, onFatal func(error)) (*QueuedOutput, error) {
+	cfg = cfg.WithDefaults()
+	errCfg = errCfg.WithDefaults()
+
+	async, isAsync := out.(AsyncOutputStream)
+
+	q := &QueuedOutput{
+		name:    name,
+		out:     out,
+		cfg:     cfg,
+		errCfg:  errCfg,
+		onFatal: onFatal,
+		queue:   make([]types.ParsedLine, 0, cfg.Size),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.lock)
+
+	if errCfg.DeadLetterPath != "" {
+		f, err := os.OpenFile(errCfg.DeadLetterPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		q.deadLetter = f
+	}
+
+	if cfg.Overflow == config.OverflowPolicy_SpillToDisk {
+		path := cfg.SpillPath
+		if path == "" {
+			// a unique file, so instances with an output of the same name don't share one
+			f, err := os.CreateTemp("", "reform-"+name+"-*.spill")
+			if err != nil {
+				q.closeDeadLetter()
+				return nil, err
+			}
+			path = f.Name()
+			f.Close()
+		}
+
+		spill, err := newSpillFile(path)
+		if err != nil {
+			q.closeDeadLetter()
+			return nil, err
+		}
+		q.spill = spill
+	}
+
+	if isAsync {
+		q.async = true
+		async.OnDelivery(q.handleDelivery)
+	}
+
+	go q.runloop()
+
+	return q, nil
+}
+
+type QueuedOutput struct {
+	name  string
+	out   OutputStream
+	cfg   config.OutputQueueCfg
+	async bool
+
+	errCfg     config.OutputErrorCfg
+	onFatal    func(error)
+	deadLetter *os.File
+
+	lock     sync.Mutex
+	cond     *sync.Cond // signaled whenever the queue changes
+	queue    []types.ParsedLine
+	spill    *spillFile
+	closed   bool
+	fatalErr error
+
+	// lines an async output gave up on, to be resent at `retryAt` (`retry` policy)
+	retry        []types.ParsedLine
+	retryAt      time.Time
+	retryAttempt int
+
+	stats   OutputStats
+	closing chan struct{}
+	done    chan struct{}
+}
+
+type OutputStats struct {
+	Sent         uint64
+	Failed       uint64
+	Dropped      uint64
+	Spilled      uint64
+	DeadLettered uint64
+
+	// the last line sent failed
+	Degraded bool
+}
+
+func (q *QueuedOutput) Name() string { return q.name }
+
+func (q *QueuedOutput) Stats() OutputStats {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.stats
+}
+
+// Output queues `line`, only returning an error once the output can't take
+// any more lines (ie it's closed, or failed under the `fail-fast` policy).
+// a line that can't be spilled to disk is handled like one the output failed
+// to send, by the error policy.
+func (q *QueuedOutput) Output(line types.ParsedLine) error {
+	spillErr, err := q.enqueue(line)
+	if spillErr != nil {
+		q.handleFailure([]types.ParsedLine{line}, 0, fmt.Errorf("unable to spill to disk: %w", spillErr))
+	}
+	return err
+}
+
+func (q *QueuedOutput) enqueue(line types.ParsedLine) (spillErr error, err error) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	if q.fatalErr != nil {
+		return nil, q.fatalErr
+	}
+	if q.closed {
+		return nil, ErrStreamClosed
+	}
+
+	// once lines are spilled, everything goes through the spill
+	// until it's been drained, to keep them in order.
+	spilling := q.spill != nil && q.spill.pending > 0
+
+	if len(q.queue) < q.cfg.Size && !spilling {
+		q.push(line)
+		return nil, nil
+	}
+
+	switch q.cfg.Overflow {
+	case config.OverflowPolicy_DropNewest:
+		q.stats.Dropped += 1
+
+	case config.OverflowPolicy_DropOldest:
+		q.queue = q.queue[1:]
+		q.stats.Dropped += 1
+		q.push(line)
+
+	case config.OverflowPolicy_SpillToDisk:
+		err := q.spill.write(line)
+		if err != nil {
+			return err, nil
+		}
+		q.stats.Spilled += 1
+		q.cond.Broadcast()
+
+	default:
+		for len(q.queue) >= q.cfg.Size && !q.closed {
+			q.cond.Wait()
+		}
+		if q.closed {
+			return nil, ErrStreamClosed
+		}
+		q.push(line)
+	}
+
+	return nil, nil
+}
+
+// Close stops accepting new lines, blocks until everything queued has been
+// handed off to the wrapped output, then closes it.
+func (q *QueuedOutput) Close() {
+	q.lock.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.closing)
+	}
+	q.cond.Broadcast()
+	q.lock.Unlock()
+
+	<-q.done
+
+	q.out.Close()
+	if q.spill != nil {
+		q.spill.close()
+	}
+	q.closeDeadLetter()
+}
+
+func (q *QueuedOutput) push(line types.ParsedLine) {
+	q.queue = append(q.queue, line)
+	q.cond.Broadcast()
+}
+
+func (q *QueuedOutput) runloop() {
+	defer close(q.done)
+
+	for {
+		line, ok := q.pop()
+		if !ok {
+			return
+		}
+
+		q.lock.Lock()
+		failed := q.fatalErr != nil
+		q.lock.Unlock()
+		if failed {
+			// a `fail-fast` output doesn't get any more lines, but
+			// they can still be saved off.
+			q.writeDeadLetter(line)
+			continue
+		}
+
+		err := q.deliver(line)
+		if err != nil {
+			q.handleFailure([]types.ParsedLine{line}, 0, err)
+		} else if !q.async {
+			q.handleSuccess(1)
+		}
+	}
+}
+
+func (q *QueuedOutput) deliver(line types.ParsedLine) error {
+	err := q.out.Output(line)
+	if err == nil || q.errCfg.Policy != config.ErrorPolicy_Retry {
+		return err
+	}
+
+	backoff := time.Duration(q.errCfg.Backoff)
+	for attempt := 1; attempt <= q.errCfg.MaxRetries; attempt++ {
+		log.Default().
+			Debug("retrying output",
+				slog.String("name", q.name),
+				slog.Int("attempt", attempt),
+				slog.Duration("backoff", backoff),
+				slog.String("error", err.Error()),
+			)
+
+		select {
+		case <-q.closing:
+			// don't hold up shutting down
+			return err
+		case <-time.After(backoff):
+		}
+
+		err = q.out.Output(line)
+		if err == nil {
+			return nil
+		}
+
+		backoff = min(backoff*2, time.Duration(q.errCfg.MaxBackoff))
+	}
+
+	return err
+}
+
+// called from the async output as its batches are sent / given up on
+func (q *QueuedOutput) handleDelivery(d Delivery) {
+	err := d.Err
+	if err == nil {
+		err = ErrDeliveryFailed
+	}
+
+	failed := d.Failed
+	if len(failed) > 0 && q.scheduleRetry(failed, err) {
+		failed = nil
+	} else if len(failed) == 0 && d.Lost == 0 && d.Sent > 0 {
+		q.lock.Lock()
+		q.retryAttempt = 0
+		q.lock.Unlock()
+	}
+
+	if d.Sent > 0 {
+		q.handleSuccess(d.Sent)
+	}
+	if len(failed) > 0 || d.Lost > 0 {
+		q.handleFailure(failed, d.Lost, err)
+	}
+}
+
+// with the `retry` policy, queues the lines an async output gave up on to be
+// output again after a backoff.  the output has already retried them itself,
+// so these are counted per output rather than per line: once it's failed
+// `MaxRetries` times in a row (or is closing), they're handled as failures.
+func (q *QueuedOutput) scheduleRetry(lines []types.ParsedLine, err error) bool {
+	if q.errCfg.Policy != config.ErrorPolicy_Retry {
+		return false
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if q.closed || q.retryAttempt >= q.errCfg.MaxRetries {
+		return false
+	}
+
+	backoff := time.Duration(q.errCfg.Backoff)
+	for range q.retryAttempt {
+		backoff = min(backoff*2, time.Duration(q.errCfg.MaxBackoff))
+	}
+	q.retryAttempt += 1
+	q.retry = append(q.retry, lines...)
+	q.retryAt = time.Now().Add(backoff)
+
+	log.Default().
+		Debug("retrying output",
+			slog.String("name", q.name),
+			slog.Int("attempt", q.retryAttempt),
+			slog.Int("lines", len(lines)),
+			slog.Duration("backoff", backoff),
+			slog.String("error", err.Error()),
+		)
+
+	// wakes `pop` up once they're due
+	time.AfterFunc(backoff, func() {
+		q.lock.Lock()
+		q.cond.Broadcast()
+		q.lock.Unlock()
+	})
+	return true
+}
+
+// `lost` counts failed lines which aren't in `lines`, so can't be dead lettered
+func (q *QueuedOutput) handleFailure(lines []types.ParsedLine, lost int, err error) {
+	failFast := q.errCfg.Policy == config.ErrorPolicy_FailFast
+
+	q.lock.Lock()
+	q.stats.Failed += uint64(len(lines) + lost)
+	q.stats.Dropped += uint64(lost)
+	wasDegraded := q.stats.Degraded
+	q.stats.Degraded = true
+	// only the first failure is fatal, ie not the batches which were already in flight
+	var fatalErr error
+	if failFast && q.fatalErr == nil {
+		q.fatalErr = fmt.Errorf("output %q failed: %w", q.name, err)
+		fatalErr = q.fatalErr
+		q.cond.Broadcast()
+	}
+	q.lock.Unlock()
+
+	for _, line := range lines {
+		q.writeDeadLetter(line)
+	}
+
+	if failFast {
+		if fatalErr == nil {
+			return
+		}
+
+		log.Default().
+			Error("output failed",
+				slog.String("name", q.name),
+				slog.String("error", err.Error()),
+			)
+		if q.onFatal != nil {
+			q.onFatal(fatalErr)
+		}
+		return
+	}
+
+	if !wasDegraded {
+		log.Default().
+			Warn("output degraded",
+				slog.String("name", q.name),
+				slog.String("error", err.Error()),
+			)
+	} else {
+		log.Default().
+			Debug("error writing to output",
+				slog.String("name", q.name),
+				slog.String("error", err.Error()),
+			)
+	}
+}
+
+func (q *QueuedOutput) handleSuccess(sent int) {
+	q.lock.Lock()
+	q.stats.Sent += uint64(sent)
+	wasDegraded := q.stats.Degraded
+	q.stats.Degraded = false
+	q.lock.Unlock()
+
+	if wasDegraded {
+		log.Default().
+			Info("output recovered",
+				slog.String("name", q.name),
+			)
+	}
+}
+
+func (q *QueuedOutput) writeDeadLetter(line types.ParsedLine) {
+	if q.deadLetter == nil {
+		q.lock.Lock()
+		q.stats.Dropped += 1
+		q.lock.Unlock()
+		return
+	}
+
+	data, err := json.Marshal(line)
+	if err == nil {
+		_, err = q.deadLetter.Write(append(data, '\n'))
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	if err != nil {
+		log.Default().
+			Error("unable to write dead letter",
+				slog.String("name", q.name),
+				slog.String("error", err.Error()),
+			)
+		q.stats.Dropped += 1
+		return
+	}
+	q.stats.DeadLettered += 1
+}
+
+func (q *QueuedOutput) closeDeadLetter() {
+	if q.deadLetter == nil {
+		return
+	}
+
+	err := q.deadLetter.Sync()
+	if err != nil {
+		log.Default().
+			Error("unable to sync dead letter file",
+				slog.String("name", q.name),
+				slog.String("error", err.Error()),
+			)
+	}
+	q.deadLetter.Close()
+}
+
+// blocks until a line is available, returning `false` once the queue has
+// been closed and fully drained.
+func (q *QueuedOutput) pop() (types.ParsedLine, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	for {
+		// lines being retried go first, once they're due.  they're not
+		// waited on when closing, so as not to hold up shutting down.
+		if len(q.retry) > 0 && (q.closed || !time.Now().Before(q.retryAt)) {
+			line := q.retry[0]
+			q.retry = q.retry[1:]
+			return line, true
+		}
+
+		if len(q.queue) == 0 && q.spill != nil && q.spill.pending > 0 {
+			q.refillFromSpill()
+		}
+
+		if len(q.queue) > 0 {
+			line := q.queue[0]
+			q.queue = q.queue[1:]
+			q.cond.Broadcast()
+			return line, true
+		}
+
+		if q.closed {
+			return types.ParsedLine{}, false
+		}
+
+		q.cond.Wait()
+	}
+}
+
+func (q *QueuedOutput) refillFromSpill() {
+	lines, err := q.spill.read(q.cfg.Size)
+	if err != nil {
+		log.Default().
+			Error("error reading spilled lines, discarding spill",
+				slog.String("name", q.name),
+				slog.Int("discarded", q.spill.pending),
+				slog.String("error", err.Error()),
+			)
+		q.stats.Dropped += uint64(q.spill.pending)
+		q.spill.reset()
+	}
+	q.queue = append(q.queue, lines...)
+}
+
+//#> Queued Output Stream
+
+//#< Spill File
+
+type spillFile struct {
+	path   string
+	writer *os.File
+	reader *os.File
+	buf    *bufio.Reader
+
+	// number of lines written, but not yet read back
+	pending int
+}
+
+func newSpillFile(path string) (*spillFile, error) {
+	writer, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := os.Open(path)
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+
+	return &spillFile{
+		path:   path,
+		writer: writer,
+		reader: reader,
+		buf:    bufio.NewReader(reader),
+	}, nil
+}
+
+// the CLEF json leaves out which input the line came from
+type spilledLine struct {
+	Line   types.ParsedLine `json:"line"`
+	Source string           `json:"source,omitempty"`
+}
+
+func (s *spillFile) write(line types.ParsedLine) error {
+	data, err := json.Marshal(spilledLine{Line: line, Source: line.Source})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.writer.Write(append(data, '\n'))
+	if err != nil {
+		return err
+	}
+
+	s.pending += 1
+	return nil
+}
+
+func (s *spillFile) read(maxLines int) ([]types.ParsedLine, error) {
+	lines := []types.ParsedLine{}
+	for s.pending > 0 && len(lines) < maxLines {
+		data, err := s.buf.ReadBytes('\n')
+		if err != nil {
+			return lines, err
+		}
+
+		var spilled spilledLine
+		err = json.Unmarshal(data, &spilled)
+		if err != nil {
+			return lines, err
+		}
+
+		spilled.Line.Source = spilled.Source
+		lines = append(lines, spilled.Line)
+		s.pending -= 1
+	}
+
+	if s.pending == 0 {
+		// fully caught up, so we can start over at the top of the file
+		s.reset()
+	}
+
+	return lines, nil
+}
+
+func (s *spillFile) reset() {
+	s.pending = 0
+	s.writer.Truncate(0)
+	s.reader.Seek(0, 0)
+	s.buf.Reset(s.reader)
+}
+
+func (s *spillFile) close() {
+	s.writer.Close()
+	s.reader.Close()
+	os.Remove(s.path)
+}
+
+//#> Spill File
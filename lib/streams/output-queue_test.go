@@ -0,0 +1,441 @@
+package streams
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/types"
+)
+
+// an output that blocks until `release` is closed, recording what it's sent.
+type gatedOutput struct {
+	release chan struct{}
+
+	lock  sync.Mutex
+	lines []string
+}
+
+func (o *gatedOutput) Output(line types.ParsedLine) error {
+	<-o.release
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.lines = append(o.lines, line.Message)
+	return nil
+}
+func (o *gatedOutput) Close() {}
+
+func TestQueuedOutput_Overflow(t *testing.T) {
+	msgs := []string{"1", "2", "3", "4", "5", "6"}
+
+	tests := []struct {
+		name        string
+		overflow    config.OverflowPolicy
+		wantLines   []string
+		wantDropped uint64
+		wantSpilled uint64
+	}{
+		{
+			// the 1st line is taken by the worker, the queue holds 2 more.
+			name:        "drop newest",
+			overflow:    config.OverflowPolicy_DropNewest,
+			wantLines:   []string{"1", "2", "3"},
+			wantDropped: 3,
+		},
+		{
+			name:        "drop oldest",
+			overflow:    config.OverflowPolicy_DropOldest,
+			wantLines:   []string{"1", "5", "6"},
+			wantDropped: 3,
+		},
+		{
+			name:        "spill to disk",
+			overflow:    config.OverflowPolicy_SpillToDisk,
+			wantLines:   msgs,
+			wantSpilled: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &gatedOutput{release: make(chan struct{})}
+			q, err := NewQueuedOutput("test", out, config.OutputQueueCfg{
+				Size:      2,
+				Overflow:  tt.overflow,
+				SpillPath: filepath.Join(t.TempDir(), "test.spill"),
+			}, config.OutputErrorCfg{}, nil)
+			if err != nil {
+				t.Fatalf("NewQueuedOutput() error = %v", err)
+			}
+
+			for i, msg := range msgs {
+				if err := q.Output(types.ParsedLine{Message: msg}); err != nil {
+					t.Fatalf("Output() error = %v", err)
+				}
+				if i == 0 {
+					waitForWorker(q)
+				}
+			}
+
+			close(out.release)
+			q.Close()
+
+			if !reflect.DeepEqual(out.lines, tt.wantLines) {
+				t.Errorf("output got = %v, want %v", out.lines, tt.wantLines)
+			}
+
+			stats := q.Stats()
+			if stats.Dropped != tt.wantDropped {
+				t.Errorf("Stats().Dropped = %v, want %v", stats.Dropped, tt.wantDropped)
+			}
+			if stats.Spilled != tt.wantSpilled {
+				t.Errorf("Stats().Spilled = %v, want %v", stats.Spilled, tt.wantSpilled)
+			}
+			if stats.Sent != uint64(len(tt.wantLines)) {
+				t.Errorf("Stats().Sent = %v, want %v", stats.Sent, len(tt.wantLines))
+			}
+		})
+	}
+}
+
+// wait for the worker to pull the first line off of the queue, so the
+// overflow behavior is deterministic.
+func waitForWorker(q *QueuedOutput) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for len(q.queue) > 0 {
+		q.cond.Wait()
+	}
+}
+
+// an output which fails the first `failures` calls.
+type flakyOutput struct {
+	failures int
+	calls    int
+	lines    []string
+}
+
+func (o *flakyOutput) Output(line types.ParsedLine) error {
+	o.calls += 1
+	if o.calls <= o.failures {
+		return errors.New("flaky")
+	}
+	o.lines = append(o.lines, line.Message)
+	return nil
+}
+func (o *flakyOutput) Close() {}
+
+func TestQueuedOutput_ErrorPolicy(t *testing.T) {
+	tests := []struct {
+		name           string
+		policy         config.ErrorPolicy
+		failures       int
+		wantLines      []string
+		wantDeadLetter []string
+		wantFatal      bool
+	}{
+		{
+			name:           "degrade",
+			policy:         config.ErrorPolicy_Degrade,
+			failures:       1,
+			wantLines:      []string{"2", "3"},
+			wantDeadLetter: []string{"1"},
+		},
+		{
+			name:      "retry",
+			policy:    config.ErrorPolicy_Retry,
+			failures:  2,
+			wantLines: []string{"1", "2", "3"},
+		},
+		{
+			name:           "retries exhausted",
+			policy:         config.ErrorPolicy_Retry,
+			failures:       4,
+			wantLines:      []string{"2", "3"},
+			wantDeadLetter: []string{"1"},
+		},
+		{
+			name:           "fail fast",
+			policy:         config.ErrorPolicy_FailFast,
+			failures:       1,
+			wantDeadLetter: []string{"1"},
+			wantFatal:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deadLetterPath := filepath.Join(t.TempDir(), "dead.letter")
+
+			var fatalErr error
+			out := &flakyOutput{failures: tt.failures}
+			q, err := NewQueuedOutput("test", out, config.OutputQueueCfg{}, config.OutputErrorCfg{
+				Policy:         tt.policy,
+				MaxRetries:     3,
+				Backoff:        config.Duration(time.Millisecond),
+				DeadLetterPath: deadLetterPath,
+			}, func(err error) { fatalErr = err })
+			if err != nil {
+				t.Fatalf("NewQueuedOutput() error = %v", err)
+			}
+
+			for i, msg := range []string{"1", "2", "3"} {
+				q.Output(types.ParsedLine{Message: msg})
+				if i == 0 {
+					// make sure the first line has been handled
+					waitForWorker(q)
+					for q.Stats().Sent+q.Stats().Failed == 0 {
+						time.Sleep(time.Millisecond)
+					}
+				}
+			}
+			q.Close()
+
+			if !reflect.DeepEqual(out.lines, tt.wantLines) {
+				t.Errorf("output got = %v, want %v", out.lines, tt.wantLines)
+			}
+			if (fatalErr != nil) != tt.wantFatal {
+				t.Errorf("onFatal() got = %v, wantFatal %v", fatalErr, tt.wantFatal)
+			}
+
+			deadLetters := []string{}
+			data, _ := os.ReadFile(deadLetterPath)
+			for l := range strings.SplitSeq(strings.TrimSpace(string(data)), "\n") {
+				if l == "" {
+					continue
+				}
+				var line types.ParsedLine
+				if err := json.Unmarshal([]byte(l), &line); err != nil {
+					t.Fatalf("unable to parse dead letter: %v", err)
+				}
+				deadLetters = append(deadLetters, line.Message)
+			}
+			if len(deadLetters) != 0 || len(tt.wantDeadLetter) != 0 {
+				if !reflect.DeepEqual(deadLetters, tt.wantDeadLetter) {
+					t.Errorf("dead letters got = %v, want %v", deadLetters, tt.wantDeadLetter)
+				}
+			}
+		})
+	}
+}
+
+// an async output which only reports how lines went when the test says so
+type asyncOutput struct {
+	deliveryReporter
+
+	lock  sync.Mutex
+	lines []types.ParsedLine
+}
+
+func (o *asyncOutput) Output(line types.ParsedLine) error {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	o.lines = append(o.lines, line)
+	return nil
+}
+func (o *asyncOutput) Close() {}
+
+func (o *asyncOutput) waitFor(n int) []types.ParsedLine {
+	for {
+		o.lock.Lock()
+		lines := o.lines
+		o.lock.Unlock()
+		if len(lines) >= n {
+			return lines
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestQueuedOutput_AsyncDelivery(t *testing.T) {
+	deadLetterPath := filepath.Join(t.TempDir(), "dead.letter")
+
+	fatals := 0
+	out := &asyncOutput{}
+	q, err := NewQueuedOutput("test", out, config.OutputQueueCfg{}, config.OutputErrorCfg{
+		Policy:         config.ErrorPolicy_FailFast,
+		DeadLetterPath: deadLetterPath,
+	}, func(err error) { fatals += 1 })
+	if err != nil {
+		t.Fatalf("NewQueuedOutput() error = %v", err)
+	}
+
+	for _, msg := range []string{"1", "2", "3", "4"} {
+		q.Output(types.ParsedLine{Message: msg})
+	}
+	lines := out.waitFor(4)
+
+	// queued isn't sent
+	if stats := q.Stats(); stats.Sent != 0 {
+		t.Errorf("Stats().Sent = %v before any were delivered", stats.Sent)
+	}
+
+	out.sent(lines[:1])
+	out.delivered(Delivery{Failed: lines[1:3], Lost: 1, Err: errors.New("batch rejected")})
+	// a batch which was already in flight
+	out.failed(lines[3:], errors.New("batch rejected"))
+
+	if err := q.Output(types.ParsedLine{Message: "5"}); err == nil {
+		t.Errorf("Output() after failing fast didn't error")
+	}
+	q.Close()
+
+	stats := q.Stats()
+	if stats.Sent != 1 || stats.Failed != 4 || stats.Dropped != 1 || stats.DeadLettered != 3 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+	if fatals != 1 {
+		t.Errorf("onFatal() called %d times, want 1", fatals)
+	}
+
+	data, _ := os.ReadFile(deadLetterPath)
+	if got := strings.Count(string(data), "\n"); got != 3 {
+		t.Errorf("%d dead letters, want 3:\n%s", got, data)
+	}
+}
+
+func TestQueuedOutput_AsyncRetry(t *testing.T) {
+	out := &asyncOutput{}
+	q, err := NewQueuedOutput("test", out, config.OutputQueueCfg{}, config.OutputErrorCfg{
+		Policy:     config.ErrorPolicy_Retry,
+		MaxRetries: 2,
+		Backoff:    config.Duration(time.Millisecond * 10),
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewQueuedOutput() error = %v", err)
+	}
+
+	q.Output(types.ParsedLine{Message: "1"})
+	q.Output(types.ParsedLine{Message: "2"})
+	lines := out.waitFor(2)
+
+	// the batch failed, so it's output again after the backoff
+	out.failed(lines, errors.New("batch rejected"))
+	lines = out.waitFor(4)
+	if lines[2].Message != "1" || lines[3].Message != "2" {
+		t.Fatalf("resent %+v", lines[2:])
+	}
+
+	// and again, until it's failed `MaxRetries` times in a row
+	out.failed(lines[2:], errors.New("batch rejected"))
+	lines = out.waitFor(6)
+	out.failed(lines[4:5], errors.New("batch rejected"))
+	out.sent(lines[5:])
+
+	q.Close()
+
+	stats := q.Stats()
+	if stats.Sent != 1 || stats.Failed != 1 || stats.Dropped != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+	if len(out.lines) != 6 {
+		t.Errorf("output %d lines, want 6", len(out.lines))
+	}
+}
+
+func TestQueuedOutput_DefaultSpillPath(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+
+	// ie two instances of reform, each with a `seq` output
+	queues := []*QueuedOutput{}
+	for range 2 {
+		q, err := NewQueuedOutput("seq", &flakyOutput{}, config.OutputQueueCfg{
+			Overflow: config.OverflowPolicy_SpillToDisk,
+		}, config.OutputErrorCfg{}, nil)
+		if err != nil {
+			t.Fatalf("NewQueuedOutput() error = %v", err)
+		}
+		queues = append(queues, q)
+	}
+
+	first, second := queues[0].spill.path, queues[1].spill.path
+	if first == second {
+		t.Errorf("both queues spill to %s", first)
+	}
+	if filepath.Dir(first) != dir || !strings.HasPrefix(filepath.Base(first), "reform-seq-") {
+		t.Errorf("unexpected spill path %s", first)
+	}
+
+	for _, q := range queues {
+		q.Close()
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("spill files left behind: %v", entries)
+	}
+}
+
+func TestQueuedOutput_SpillFailure(t *testing.T) {
+	dir := t.TempDir()
+	out := &gatedOutput{release: make(chan struct{})}
+	q, err := NewQueuedOutput("test", out, config.OutputQueueCfg{
+		Size:      1,
+		Overflow:  config.OverflowPolicy_SpillToDisk,
+		SpillPath: filepath.Join(dir, "test.spill"),
+	}, config.OutputErrorCfg{
+		DeadLetterPath: filepath.Join(dir, "dead.jsonl"),
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewQueuedOutput() error = %v", err)
+	}
+
+	// ie the disk has gone away
+	q.spill.writer.Close()
+
+	for i, msg := range []string{"1", "2", "3"} {
+		// the line that can't be spilled goes through the error policy,
+		// the output can still take more lines
+		if err := q.Output(types.ParsedLine{Message: msg}); err != nil {
+			t.Fatalf("Output(%q) error = %v", msg, err)
+		}
+		if i == 0 {
+			waitForWorker(q)
+		}
+	}
+
+	close(out.release)
+	q.Close()
+
+	if want := []string{"1", "2"}; !reflect.DeepEqual(out.lines, want) {
+		t.Errorf("output got = %v, want %v", out.lines, want)
+	}
+	stats := q.Stats()
+	if stats.Failed != 1 || stats.DeadLettered != 1 {
+		t.Errorf("Stats() = %+v, want 1 failed + dead lettered", stats)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "dead.jsonl")); !strings.Contains(string(data), `"@m":"3"`) {
+		t.Errorf("dead letters = %s", data)
+	}
+}
+
+func TestSpillFile_KeepsSource(t *testing.T) {
+	spill, err := newSpillFile(filepath.Join(t.TempDir(), "test.spill"))
+	if err != nil {
+		t.Fatalf("newSpillFile() error = %v", err)
+	}
+	defer spill.close()
+
+	want := []types.ParsedLine{
+		{Message: "1", Source: "lab"},
+		{Message: "2", Properties: map[string]any{"source": "app.go"}},
+	}
+	for _, line := range want {
+		if err := spill.write(line); err != nil {
+			t.Fatalf("write() error = %v", err)
+		}
+	}
+
+	got, err := spill.read(len(want))
+	if err != nil {
+		t.Fatalf("read() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("read() got vs want:\n  %+v\n  %+v", got, want)
+	}
+}
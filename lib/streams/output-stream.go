@@ -1,267 +1,114 @@
 package streams
 
 import (
-	"bytes"
-	"context"
-	"crypto/tls"
-	"encoding/json"
 	"fmt"
-	"io"
-	"log/slog"
-	"net/http"
 	"os"
 	"sync"
-	"time"
 
-	"github.com/erobsham/reform/lib/log"
+	"github.com/erobsham/reform/lib/config"
 	"github.com/erobsham/reform/lib/types"
 )
 
+// reasons async outputs give up on lines, see `Delivery`
+const (
+	ErrRetriesExhausted StreamError = "still failing after retries"
+	ErrNotAcknowledged  StreamError = "never acknowledged"
+	ErrLineTooLarge     StreamError = "line too large to send"
+	ErrDeliveryFailed   StreamError = "delivery failed"
+)
+
 type OutputStream interface {
 	Output(line types.ParsedLine) error
 	Close()
 }
 
-//#< File Output Stream
-
-func NewOutputFile(file string) (OutputStream, error) {
-	f, err := os.OpenFile(file, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
-	if err != nil {
-		return nil, err
-	}
-	return &OutputFile{file: f}, nil
-}
-
-type OutputFile struct {
-	file *os.File
-	lock sync.RWMutex
+// AsyncOutputStream is an output which only queues lines in `Output`, and
+// sends them on later (ie in batches).  how each send went is reported to the
+// func set with `OnDelivery`, which is called before any lines are output.
+type AsyncOutputStream interface {
+	OutputStream
+	OnDelivery(report func(Delivery))
 }
 
-func (o *OutputFile) Output(line types.ParsedLine) error {
-	o.lock.RLock()
-	defer o.lock.RUnlock()
-
-	if o.file == nil {
-		return ErrStreamClosed
-	}
+// Delivery is the outcome of an async output sending some lines on.
+type Delivery struct {
+	Sent int
 
-	encoder := json.NewEncoder(o.file)
-	err := encoder.Encode(line)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	// the lines which were given up on, and why
+	Failed []types.ParsedLine
+	Err    error
+	// failed lines which can't be told apart from the sent ones (ie when the
+	// server only says how many it rejected), or which are no longer held
+	Lost int
 }
 
-func (o *OutputFile) Close() {
-	o.lock.Lock()
-	defer o.lock.Unlock()
-	if o.file != nil {
-		err := o.file.Sync()
-		if err != nil {
-			log.Default().
-				Error("unable to sync outfile",
-					slog.String("error", err.Error()),
-				)
-		}
-
-		o.file.Close()
-		o.file = nil
-	}
+// embedded by the async outputs to implement `OnDelivery`
+type deliveryReporter struct {
+	reportLock sync.Mutex
+	report     func(Delivery)
 }
 
-//#> File Output Stream
-
-//#< Stdout Stream
-
-type StdoutStream struct {
-	counter uint64
-}
-
-func (o *StdoutStream) Output(line types.ParsedLine) error {
-	fmt.Printf("%05d: %s \n", o.counter, line)
-	o.counter += 1
-	return nil
-}
-
-func (o StdoutStream) Close() {}
-
-//#> Stdout Stream
-
-//#< Seq Server Stream
-
-type SeqStream struct {
-	ctx    context.Context
-	client *http.Client
-
-	host   string
-	apiKey string
-
-	logChan chan types.ParsedLine
-
-	wakeChan chan struct{}
-	sendChan chan struct{}
-}
-
-func NewSeqStream(ctx context.Context, host string, apiKey string) OutputStream {
-	client := http.Client{
-		Transport: &http.Transport{
-			IdleConnTimeout:       time.Second * 10,
-			ResponseHeaderTimeout: time.Millisecond * 250,
-			ExpectContinueTimeout: time.Millisecond * 250,
-			TLSHandshakeTimeout:   time.Millisecond * 250,
-			TLSClientConfig: &tls.Config{
-				// allow talking HTTPS via self-signed certs
-				InsecureSkipVerify: true,
-			},
-		},
-		Timeout: time.Millisecond * 500,
-	}
-
-	s := &SeqStream{
-		ctx:    ctx,
-		client: &client,
-
-		host:   host,
-		apiKey: apiKey,
-
-		logChan:  make(chan types.ParsedLine, 64),
-		sendChan: make(chan struct{}, 1),
-		wakeChan: make(chan struct{}, 1),
-	}
-	// preload chan so we can immediately take it when
-	// we get out first message to log.
-	s.sendChan <- struct{}{}
-
-	go s.runloop()
-
-	return s
+func (r *deliveryReporter) OnDelivery(report func(Delivery)) {
+	r.reportLock.Lock()
+	defer r.reportLock.Unlock()
+	r.report = report
 }
 
-func (s *SeqStream) Output(line types.ParsedLine) error {
-
-	// block on adding the line to logChan
-	select {
-	case <-s.ctx.Done():
-		return ErrStreamClosed
-	case s.logChan <- line:
-	}
+func (r *deliveryReporter) delivered(d Delivery) {
+	r.reportLock.Lock()
+	report := r.report
+	r.reportLock.Unlock()
 
-	// don't block on signaling the wake up
-	select {
-	case <-s.ctx.Done():
-		return ErrStreamClosed
-	case s.wakeChan <- struct{}{}:
-		return nil
-	default:
-		return nil
+	if report != nil {
+		report(d)
 	}
 }
 
-func (s *SeqStream) Close() {
-
-	// block for any pending sends to finish
-	<-s.sendChan
-	if len(s.logChan) > 0 {
-		s.sendChan <- struct{}{}
-		s.gatherAndSendLogs()
-	}
-	close(s.logChan)
-	close(s.sendChan)
+func (r *deliveryReporter) sent(lines []types.ParsedLine) {
+	r.delivered(Delivery{Sent: len(lines)})
 }
 
-func (s *SeqStream) runloop() {
-	for {
-		select {
-		case <-s.ctx.Done():
-			return
-		case <-s.wakeChan:
-		}
-
-		s.gatherAndSendLogs()
-	}
+func (r *deliveryReporter) failed(lines []types.ParsedLine, err error) {
+	r.delivered(Delivery{Failed: lines, Err: err})
 }
 
-func (s *SeqStream) gatherAndSendLogs() {
-	lines := []types.ParsedLine{}
-gather:
-	for {
-		select {
-		case line := <-s.logChan:
-			lines = append(lines, line)
-		default:
-			break gather
-		}
-	}
+//#< Stdout Stream
 
-send:
-	for {
-		select {
-		case <-s.sendChan:
-			break send
-		case line := <-s.logChan:
-			lines = append(lines, line)
-		}
+func NewStdoutStream(cfg config.OutputStdoutCfg) (OutputStream, error) {
+	if cfg.Terminal {
+		return NewTerminalStream(cfg), nil
 	}
 
-	err := s.sendLogs(lines)
+	format, err := newLineFormatter(cfg.Format)
 	if err != nil {
-		log.Default().
-			Error("error sending logs to Seq",
-				slog.String("error", err.Error()),
-			)
+		return nil, err
 	}
+	return &StdoutStream{format: format}, nil
 }
 
-func (s *SeqStream) sendLogs(lines []types.ParsedLine) error {
-	// signal we're done sending
-	defer func() { s.sendChan <- struct{}{} }()
+// StdoutStream prints a numbered summary of each line, or the configured format
+type StdoutStream struct {
+	counter uint64
+	format  lineFormatter
+	buf     []byte
+}
 
-	if len(lines) == 0 {
+func (o *StdoutStream) Output(line types.ParsedLine) error {
+	if o.format == nil {
+		fmt.Printf("%05d: %s \n", o.counter, line)
+		o.counter += 1
 		return nil
 	}
 
-	buf := &bytes.Buffer{}
-	encoder := json.NewEncoder(buf)
-	for _, line := range lines {
-		encoder.Encode(line)
-	}
-
-	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.url(), buf)
-	if err != nil {
-		return err
-	}
-
-	// From the '[CLEF](https://clef-json.org/)' standard:
-	// `ContentType: application/vnd.serilog.clef`
-	// `X-Seq-ApiKey: {api key}`
-
-	req.Header.Set("ContentType", "application/vnd.serilog.clef")
-	if s.apiKey != "" {
-		req.Header.Set("X-Seq-ApiKey", s.apiKey)
-	}
-
-	resp, err := s.client.Do(req)
+	data, err := o.format(o.buf[:0], line)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("unexpected response status: %v body:%s", resp.StatusCode, string(body))
-	}
-
-	log.Default().Info("sent logs",
-		slog.Int("numSent", len(lines)),
-	)
-
-	return nil
+	o.buf = append(data, '\n')
+	_, err = os.Stdout.Write(o.buf)
+	return err
 }
 
-func (s *SeqStream) url() string {
-	return "http://" + s.host + "/ingest/clef"
-}
+func (o StdoutStream) Close() {}
 
-//#>
+//#> Stdout Stream
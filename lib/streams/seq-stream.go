@@ -0,0 +1,578 @@
+package streams
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/log"
+	"github.com/erobsham/reform/lib/types"
+)
+
+//#< Seq Server Stream
+
+type SeqStream struct {
+	deliveryReporter
+
+	ctx        context.Context
+	cancelFunc context.CancelFunc
+	client     *http.Client
+	cfg        config.OutputSeqCfg
+	log        *slog.Logger
+
+	lock    sync.RWMutex
+	closed  bool
+	logChan chan types.ParsedLine
+	closing chan struct{}
+	done    chan struct{}
+
+	// nil when spooling is disabled
+	spool *seqSpool
+
+	// reused across batches, only touched from the runloop
+	lineBuf  bytes.Buffer
+	batchBuf bytes.Buffer
+	encoder  *json.Encoder
+	gzipBuf  bytes.Buffer
+	gzipper  *gzip.Writer
+}
+
+func NewSeqStream(ctx context.Context, cfg config.OutputSeqCfg) (*SeqStream, error) {
+	cfg = cfg.WithDefaults()
+
+	client, err := newHTTPClient(cfg.TLS, cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// there can be any number of Seq outputs, so tag the logs with which one it is
+	logger := log.Default().With(slog.String("url", cfg.URL))
+
+	if cfg.TLS.InsecureSkipVerify {
+		logger.Warn("seq certificate verification disabled")
+	}
+
+	var spool *seqSpool
+	if cfg.SpoolDir != "" {
+		spool, err = newSeqSpool(cfg.SpoolDir, cfg.MaxSpoolBytes)
+		if err != nil {
+			return nil, err
+		}
+		if spool.pending() > 0 {
+			logger.
+				Info("found spooled Seq batches, will resend",
+					slog.String("dir", cfg.SpoolDir),
+					slog.Int("batches", spool.pending()),
+				)
+		}
+	}
+
+	ctx, cancelFn := context.WithCancel(ctx)
+	s := &SeqStream{
+		ctx:        ctx,
+		cancelFunc: cancelFn,
+		client:     client,
+		cfg:        cfg,
+		log:        logger,
+
+		logChan: make(chan types.ParsedLine, cfg.MaxBatchEvents),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+
+		spool: spool,
+	}
+	s.encoder = json.NewEncoder(&s.lineBuf)
+	if cfg.Gzip {
+		s.gzipper = gzip.NewWriter(&s.gzipBuf)
+	}
+
+	go s.runloop()
+
+	return s, nil
+}
+
+func (s *SeqStream) Output(line types.ParsedLine) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if s.closed {
+		return ErrStreamClosed
+	}
+
+	select {
+	case <-s.ctx.Done():
+		return ErrStreamClosed
+	case s.logChan <- line:
+		return nil
+	}
+}
+
+// Close blocks until all the pending logs have been sent (or spooled).
+func (s *SeqStream) Close() {
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.closing)
+	close(s.logChan)
+	s.lock.Unlock()
+
+	<-s.done
+	s.cancelFunc()
+}
+
+func (s *SeqStream) runloop() {
+	defer close(s.done)
+
+	backoff := s.cfg.Backoff
+	nextSpoolAttempt := time.Now()
+
+	for {
+		var spoolRetry <-chan time.Time
+		if s.spool != nil && s.spool.pending() > 0 {
+			spoolRetry = time.After(time.Until(nextSpoolAttempt))
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return
+
+		case line, ok := <-s.logChan:
+			if !ok {
+				// try one last time to clear out the spool, but
+				// anything left will be picked up on the next run.
+				if s.spool != nil && s.spool.pending() > 0 {
+					s.drainSpool()
+				}
+				return
+			}
+
+			s.sendLines(s.gatherLines(line))
+
+		case <-spoolRetry:
+			if s.drainSpool() {
+				backoff = s.cfg.Backoff
+			} else {
+				nextSpoolAttempt = time.Now().Add(backoff)
+				backoff = min(backoff*2, s.cfg.MaxBackoff)
+			}
+		}
+	}
+}
+
+// gathers up any lines that are immediately available, up to the batch limit.
+func (s *SeqStream) gatherLines(first types.ParsedLine) []types.ParsedLine {
+	lines := []types.ParsedLine{first}
+	for len(lines) < s.cfg.MaxBatchEvents {
+		select {
+		case line, ok := <-s.logChan:
+			if !ok {
+				return lines
+			}
+			lines = append(lines, line)
+		default:
+			return lines
+		}
+	}
+	return lines
+}
+
+type seqBatch struct {
+	body  []byte
+	count int
+	// the lines in `body`, nil once it's been read back from the spool
+	lines []types.ParsedLine
+}
+
+// encodes the lines as CLEF and delivers them in batches that fit within
+// the configured limits.
+//
+// only ever called from the runloop, so the encoding buffers are reused
+// across batches rather than allocated for every post.
+func (s *SeqStream) sendLines(lines []types.ParsedLine) {
+	s.batchBuf.Reset()
+	batched := []types.ParsedLine{}
+
+	for _, line := range lines {
+		s.lineBuf.Reset()
+		err := s.encoder.Encode(line)
+		if err != nil {
+			s.log.
+				Error("unable to encode log for Seq",
+					slog.String("error", err.Error()),
+				)
+			s.failed([]types.ParsedLine{line}, err)
+			continue
+		}
+
+		if s.lineBuf.Len() > s.cfg.MaxBatchBytes {
+			s.log.
+				Error("log too large to send to Seq, dropping",
+					slog.Int("bytes", s.lineBuf.Len()),
+					slog.Int("max_batch_bytes", s.cfg.MaxBatchBytes),
+				)
+			s.failed([]types.ParsedLine{line}, ErrLineTooLarge)
+			continue
+		}
+
+		if s.batchBuf.Len()+s.lineBuf.Len() > s.cfg.MaxBatchBytes || len(batched) >= s.cfg.MaxBatchEvents {
+			s.deliver(seqBatch{body: s.batchBuf.Bytes(), count: len(batched), lines: batched})
+			s.batchBuf.Reset()
+			batched = []types.ParsedLine{}
+		}
+
+		s.batchBuf.Write(s.lineBuf.Bytes())
+		batched = append(batched, line)
+	}
+
+	if len(batched) > 0 {
+		s.deliver(seqBatch{body: s.batchBuf.Bytes(), count: len(batched), lines: batched})
+	}
+}
+
+func (s *SeqStream) deliver(batch seqBatch) {
+	if s.spool != nil && s.spool.pending() > 0 {
+		// the server's been unreachable, so queue up behind the
+		// existing spool to keep everything in order.
+		s.spoolBatch(batch)
+		return
+	}
+
+	retries := s.cfg.MaxRetries
+	if s.spool != nil && s.isClosing() {
+		// don't hold up shutting down when we can just spool it
+		retries = 0
+	}
+
+	err := s.sendWithRetry(batch, retries)
+	if err == nil {
+		s.sent(batch.lines)
+		return
+	}
+
+	var respErr HTTPResponseError
+	if errors.As(err, &respErr) && respErr.StatusCode == http.StatusRequestEntityTooLarge && batch.count > 1 {
+		// the server has a lower limit than we're configured for, try again with smaller batches.
+		first, second := splitSeqBatch(batch)
+		s.deliver(first)
+		s.deliver(second)
+		return
+	}
+
+	if s.spool != nil && isRetriableHTTPError(err) {
+		s.spoolBatch(batch)
+		return
+	}
+
+	s.log.
+		Error("error sending logs to Seq, dropping",
+			slog.Int("numDropped", batch.count),
+			slog.String("error", err.Error()),
+		)
+	s.failed(batch.lines, err)
+}
+
+func (s *SeqStream) sendWithRetry(batch seqBatch, retries int) error {
+	policy := retryPolicy{
+		MaxRetries: retries,
+		Backoff:    s.cfg.Backoff,
+		MaxBackoff: s.cfg.MaxBackoff,
+	}
+	return retryWithBackoff(s.ctx, s.log, policy, func() error {
+		return s.sendLogs(batch)
+	})
+}
+
+func (s *SeqStream) sendLogs(batch seqBatch) error {
+	body := batch.body
+	if s.gzipper != nil {
+		var err error
+		body, err = s.compress(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.url(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	// From the '[CLEF](https://clef-json.org/)' standard:
+	// `Content-Type: application/vnd.serilog.clef`
+	// `X-Seq-ApiKey: {api key}`
+
+	req.Header.Set("Content-Type", "application/vnd.serilog.clef")
+	if s.gzipper != nil {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if s.cfg.APIKey != "" {
+		req.Header.Set("X-Seq-ApiKey", s.cfg.APIKey)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return HTTPResponseError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			Body:       string(body),
+		}
+	}
+	io.Copy(io.Discard, resp.Body)
+
+	s.log.Info("sent logs",
+		slog.Int("numSent", batch.count),
+	)
+
+	return nil
+}
+
+// the returned slice is only valid until the next call
+func (s *SeqStream) compress(body []byte) ([]byte, error) {
+	s.gzipBuf.Reset()
+	s.gzipper.Reset(&s.gzipBuf)
+
+	_, err := s.gzipper.Write(body)
+	if err != nil {
+		return nil, err
+	}
+	err = s.gzipper.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.gzipBuf.Bytes(), nil
+}
+
+func (s *SeqStream) url() string {
+	return s.cfg.URL + "/ingest/clef"
+}
+
+func (s *SeqStream) isClosing() bool {
+	select {
+	case <-s.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+func splitSeqBatch(batch seqBatch) (seqBatch, seqBatch) {
+	half := batch.count / 2
+
+	idx := 0
+	for range half {
+		idx += bytes.IndexByte(batch.body[idx:], '\n') + 1
+	}
+
+	first := seqBatch{body: batch.body[:idx], count: half}
+	second := seqBatch{body: batch.body[idx:], count: batch.count - half}
+	if batch.lines != nil {
+		first.lines, second.lines = batch.lines[:half], batch.lines[half:]
+	}
+	return first, second
+}
+
+//#> Seq Server Stream
+
+//#< Seq Spool
+
+func (s *SeqStream) spoolBatch(batch seqBatch) {
+	err := s.spool.write(batch)
+	if err != nil {
+		s.log.
+			Error("unable to spool logs for Seq, dropping",
+				slog.Int("numDropped", batch.count),
+				slog.String("error", err.Error()),
+			)
+		s.failed(batch.lines, err)
+		return
+	}
+
+	s.log.
+		Debug("spooled logs for Seq",
+			slog.Int("numSpooled", batch.count),
+			slog.Int("batches", s.spool.pending()),
+		)
+}
+
+// resends spooled batches (oldest first), returning `false` if the server
+// still isn't accepting them.
+func (s *SeqStream) drainSpool() bool {
+	for s.spool.pending() > 0 {
+		path, batch, err := s.spool.oldest()
+		if err != nil {
+			s.log.
+				Error("unable to read spooled Seq batch, discarding",
+					slog.String("path", path),
+					slog.String("error", err.Error()),
+				)
+			s.spool.remove(path)
+			continue
+		}
+
+		err = s.sendLogs(batch)
+		if err != nil && isRetriableHTTPError(err) {
+			s.log.
+				Debug("Seq still unavailable",
+					slog.Int("batches", s.spool.pending()),
+					slog.String("error", err.Error()),
+				)
+			return false
+		}
+		// batches left by a previous run weren't output by this one, so
+		// they're only logged rather than counted in this run's stats
+		previousRun := s.spool.fromPreviousRun(path)
+		if err != nil {
+			// the server will never accept it, don't let it block the rest
+			s.log.
+				Error("Seq rejected spooled batch, discarding",
+					slog.String("path", path),
+					slog.Int("numDropped", batch.count),
+					slog.Bool("previousRun", previousRun),
+					slog.String("error", err.Error()),
+				)
+			if !previousRun {
+				// only the count is kept in the spool, not the lines
+				s.delivered(Delivery{Lost: batch.count, Err: err})
+			}
+		} else if previousRun {
+			s.log.Info("resent logs spooled by a previous run",
+				slog.Int("numSent", batch.count),
+			)
+		} else {
+			s.delivered(Delivery{Sent: batch.count})
+		}
+
+		s.spool.remove(path)
+	}
+
+	s.log.Info("finished resending spooled Seq batches")
+	return true
+}
+
+const seqSpoolSuffix = ".clef"
+
+// a directory of batches (one per file) which failed to send, named so
+// they sort in the order they were written.
+type seqSpool struct {
+	dir      string
+	maxBytes int64
+
+	files   []string
+	size    int64
+	counter uint64
+
+	// the files which were already in the spool when it was opened
+	previous map[string]bool
+}
+
+func newSeqSpool(dir string, maxBytes int64) (*seqSpool, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	spool := &seqSpool{dir: dir, maxBytes: maxBytes, previous: map[string]bool{}}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), seqSpoolSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		spool.files = append(spool.files, entry.Name())
+		spool.size += info.Size()
+		spool.previous[entry.Name()] = true
+	}
+	slices.Sort(spool.files)
+
+	return spool, nil
+}
+
+func (s *seqSpool) pending() int { return len(s.files) }
+
+func (s *seqSpool) fromPreviousRun(name string) bool { return s.previous[name] }
+
+func (s *seqSpool) write(batch seqBatch) error {
+	if s.size+int64(len(batch.body)) > s.maxBytes {
+		return fmt.Errorf("spool is full (%d bytes)", s.size)
+	}
+
+	s.counter += 1
+	name := fmt.Sprintf("%020d-%06d-%d%s", time.Now().UnixNano(), s.counter%1000000, batch.count, seqSpoolSuffix)
+
+	// write + rename, so a crash never leaves a partial batch behind
+	tmpPath := filepath.Join(s.dir, name+".tmp")
+	err := os.WriteFile(tmpPath, batch.body, 0644)
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	err = os.Rename(tmpPath, filepath.Join(s.dir, name))
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	s.files = append(s.files, name)
+	s.size += int64(len(batch.body))
+	return nil
+}
+
+func (s *seqSpool) oldest() (string, seqBatch, error) {
+	name := s.files[0]
+	path := filepath.Join(s.dir, name)
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return name, seqBatch{}, err
+	}
+
+	return name, seqBatch{body: body, count: bytes.Count(body, []byte{'\n'})}, nil
+}
+
+func (s *seqSpool) remove(name string) {
+	idx := slices.Index(s.files, name)
+	if idx == -1 {
+		return
+	}
+	s.files = slices.Delete(s.files, idx, idx+1)
+	delete(s.previous, name)
+
+	path := filepath.Join(s.dir, name)
+	if info, err := os.Stat(path); err == nil {
+		s.size -= info.Size()
+	}
+	os.Remove(path)
+}
+
+//#> Seq Spool
@@ -0,0 +1,322 @@
+package streams
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/types"
+)
+
+// a stub Seq server which fails the first `failures` requests with `failStatus`
+type stubSeqServer struct {
+	*httptest.Server
+
+	failures   int
+	failStatus int
+	// sent with the failures, defaults to "0"
+	retryAfter string
+
+	lock      sync.Mutex
+	requests  int
+	batches   [][]string
+	apiKeys   []string
+	encodings []string
+}
+
+func newStubSeqServer(failures int, failStatus int) *stubSeqServer {
+	s := &stubSeqServer{failures: failures, failStatus: failStatus}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *stubSeqServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.requests += 1
+	if s.requests <= s.failures {
+		retryAfter := s.retryAfter
+		if retryAfter == "" {
+			retryAfter = "0"
+		}
+		w.Header().Set("Retry-After", retryAfter)
+		w.WriteHeader(s.failStatus)
+		return
+	}
+
+	var reader io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		reader = gz
+	}
+	s.encodings = append(s.encodings, r.Header.Get("Content-Encoding"))
+
+	body, _ := io.ReadAll(reader)
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	s.batches = append(s.batches, lines)
+	s.apiKeys = append(s.apiKeys, r.Header.Get("X-Seq-ApiKey"))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *stubSeqServer) received() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	count := 0
+	for _, batch := range s.batches {
+		count += len(batch)
+	}
+	return count
+}
+
+func TestSeqStream_Delivery(t *testing.T) {
+	tests := []struct {
+		name           string
+		failures       int
+		failStatus     int
+		maxBatchEvents int
+		gzip           bool
+		lines          int
+		wantReceived   int
+	}{
+		{
+			name:         "simple",
+			lines:        5,
+			wantReceived: 5,
+		},
+		{
+			name:         "retry after 503",
+			failures:     2,
+			failStatus:   http.StatusServiceUnavailable,
+			lines:        3,
+			wantReceived: 3,
+		},
+		{
+			name:         "retry after 429",
+			failures:     1,
+			failStatus:   http.StatusTooManyRequests,
+			lines:        3,
+			wantReceived: 3,
+		},
+		{
+			name:         "no retry after 400",
+			failures:     1,
+			failStatus:   http.StatusBadRequest,
+			lines:        1,
+			wantReceived: 0,
+		},
+		{
+			name:           "batch limit",
+			maxBatchEvents: 2,
+			lines:          7,
+			wantReceived:   7,
+		},
+		{
+			name:           "gzip",
+			maxBatchEvents: 3,
+			gzip:           true,
+			lines:          7,
+			wantReceived:   7,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newStubSeqServer(tt.failures, tt.failStatus)
+			defer server.Close()
+
+			s, err := NewSeqStream(context.Background(), config.OutputSeqCfg{
+				URL:            server.URL,
+				APIKey:         "key",
+				MaxBatchEvents: tt.maxBatchEvents,
+				Gzip:           tt.gzip,
+				Backoff:        time.Millisecond,
+			})
+			if err != nil {
+				t.Fatalf("NewSeqStream() error = %v", err)
+			}
+
+			for i := range tt.lines {
+				s.Output(types.ParsedLine{Message: strings.Repeat("x", i)})
+			}
+			s.Close()
+
+			if got := server.received(); got != tt.wantReceived {
+				t.Errorf("server received %v lines, want %v", got, tt.wantReceived)
+			}
+			for _, batch := range server.batches {
+				if tt.maxBatchEvents > 0 && len(batch) > tt.maxBatchEvents {
+					t.Errorf("batch of %v lines exceeds limit of %v", len(batch), tt.maxBatchEvents)
+				}
+			}
+			for _, encoding := range server.encodings {
+				if (encoding == "gzip") != tt.gzip {
+					t.Errorf("Content-Encoding = %q, gzip %v", encoding, tt.gzip)
+				}
+			}
+			for _, key := range server.apiKeys {
+				if key != "key" {
+					t.Errorf("X-Seq-ApiKey = %q, want %q", key, "key")
+				}
+			}
+		})
+	}
+}
+
+func TestSeqStream_SpoolSurvivesRestart(t *testing.T) {
+	spoolDir := t.TempDir()
+
+	down := newStubSeqServer(1000, http.StatusServiceUnavailable)
+	s, err := NewSeqStream(context.Background(), config.OutputSeqCfg{
+		URL:        down.URL,
+		MaxRetries: -1,
+		SpoolDir:   spoolDir,
+	})
+	if err != nil {
+		t.Fatalf("NewSeqStream() error = %v", err)
+	}
+	for range 4 {
+		s.Output(types.ParsedLine{Message: "spooled"})
+	}
+	s.Close()
+	down.Close()
+
+	entries, _ := os.ReadDir(spoolDir)
+	if len(entries) == 0 {
+		t.Fatalf("expected spooled batches in %v", spoolDir)
+	}
+
+	up := newStubSeqServer(0, 0)
+	defer up.Close()
+	s, err = NewSeqStream(context.Background(), config.OutputSeqCfg{
+		URL:      up.URL,
+		SpoolDir: spoolDir,
+	})
+	if err != nil {
+		t.Fatalf("NewSeqStream() error = %v", err)
+	}
+	// the lines weren't output by this run, so aren't reported as its own
+	reported := 0
+	s.OnDelivery(func(d Delivery) { reported += d.Sent + len(d.Failed) + d.Lost })
+	s.Close()
+
+	if reported != 0 {
+		t.Errorf("reported %v lines from the previous run's spool", reported)
+	}
+
+	if got := up.received(); got != 4 {
+		t.Errorf("server received %v spooled lines, want %v", got, 4)
+	}
+	entries, _ = os.ReadDir(spoolDir)
+	if len(entries) != 0 {
+		t.Errorf("expected spool to be empty, found %v files", len(entries))
+	}
+}
+
+func TestSeqStream_RetryAfterCapped(t *testing.T) {
+	// asks for an hour, which is capped at the `MaxBackoff`
+	server := newStubSeqServer(1, http.StatusServiceUnavailable)
+	server.retryAfter = "3600"
+	defer server.Close()
+
+	s, err := NewSeqStream(context.Background(), config.OutputSeqCfg{
+		URL:        server.URL,
+		Backoff:    time.Millisecond,
+		MaxBackoff: time.Millisecond * 10,
+	})
+	if err != nil {
+		t.Fatalf("NewSeqStream() error = %v", err)
+	}
+
+	start := time.Now()
+	s.Output(types.ParsedLine{Message: "hello"})
+	s.Close()
+
+	if elapsed := time.Since(start); elapsed > time.Second*5 {
+		t.Errorf("waited %s for the Retry-After", elapsed)
+	}
+	if got := server.received(); got != 1 {
+		t.Errorf("server received %v lines, want 1", got)
+	}
+}
+
+func Test_splitSeqBatch(t *testing.T) {
+	batch := seqBatch{body: []byte("a\nb\nc\n"), count: 3}
+
+	first, second := splitSeqBatch(batch)
+	if !bytes.Equal(first.body, []byte("a\n")) || first.count != 1 {
+		t.Errorf("splitSeqBatch() first = %q (%v)", first.body, first.count)
+	}
+	if !bytes.Equal(second.body, []byte("b\nc\n")) || second.count != 2 {
+		t.Errorf("splitSeqBatch() second = %q (%v)", second.body, second.count)
+	}
+}
+
+func TestSeqStream_TLS(t *testing.T) {
+	server := newStubSeqServer(0, 0)
+	server.Close()
+	server.Server = httptest.NewTLSServer(http.HandlerFunc(server.handle))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0644); err != nil {
+		t.Fatalf("unable to write ca file: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		tls          config.TLSCfg
+		wantReceived int
+	}{
+		{
+			name:         "untrusted cert",
+			tls:          config.TLSCfg{},
+			wantReceived: 0,
+		},
+		{
+			name:         "custom ca",
+			tls:          config.TLSCfg{CAFile: caFile},
+			wantReceived: 1,
+		},
+		{
+			name:         "skip verify",
+			tls:          config.TLSCfg{InsecureSkipVerify: true},
+			wantReceived: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := server.received()
+
+			s, err := NewSeqStream(context.Background(), config.OutputSeqCfg{
+				URL:        server.URL,
+				TLS:        tt.tls,
+				MaxRetries: -1,
+			})
+			if err != nil {
+				t.Fatalf("NewSeqStream() error = %v", err)
+			}
+			s.Output(types.ParsedLine{Message: "secure"})
+			s.Close()
+
+			if got := server.received() - before; got != tt.wantReceived {
+				t.Errorf("server received %v lines, want %v", got, tt.wantReceived)
+			}
+		})
+	}
+}
@@ -0,0 +1,393 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/log"
+	"github.com/erobsham/reform/lib/types"
+)
+
+//#< Splunk Stream
+
+// SplunkStream posts lines to a Splunk HTTP Event Collector (HEC).
+type SplunkStream struct {
+	*batchOutput
+
+	client  *http.Client
+	cfg     config.OutputSplunkCfg
+	log     *slog.Logger
+	channel string
+	format  lineFormatter // nil sends the CLEF json
+
+	// batches waiting on indexer acknowledgement, by ack id.
+	// only touched from the runloop.
+	pendingAcks map[uint64]*splunkBatch
+	ackPoll     time.Duration
+
+	bodyBuf bytes.Buffer
+}
+
+type splunkBatch struct {
+	body     []byte
+	count    int
+	lines    []types.ParsedLine
+	sentAt   time.Time
+	attempts int
+}
+
+func NewSplunkStream(ctx context.Context, cfg config.OutputSplunkCfg) (*SplunkStream, error) {
+	cfg = cfg.WithDefaults()
+
+	client, err := newHTTPClient(cfg.TLS, cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := newLineFormatter(cfg.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	channel := cfg.Channel
+	if channel == "" && cfg.UseAck {
+		channel = newSplunkChannel()
+	}
+
+	s := &SplunkStream{
+		client:  client,
+		cfg:     cfg,
+		log:     log.Default().With(slog.String("url", cfg.URL)),
+		channel: channel,
+		format:  format,
+
+		pendingAcks: map[uint64]*splunkBatch{},
+		ackPoll:     min(time.Second, cfg.AckTimeout/4),
+	}
+	if cfg.UseAck {
+		// acks are checked as each batch is sent, and polled for in between
+		s.batchOutput = newPollingBatchOutput(ctx, cfg.MaxBatchEvents, cfg.BatchWait, s.sendLines, s.drainAcks, s.ackPoll, s.pollAcks)
+	} else {
+		s.batchOutput = newBatchOutput(ctx, cfg.MaxBatchEvents, cfg.BatchWait, s.sendLines, s.drainAcks)
+	}
+
+	return s, nil
+}
+
+func (s *SplunkStream) sendLines(lines []types.ParsedLine) {
+	s.bodyBuf.Reset()
+	encoder := json.NewEncoder(&s.bodyBuf)
+
+	encoded := make([]types.ParsedLine, 0, len(lines))
+	for _, line := range lines {
+		event, err := s.event(line)
+		if err == nil {
+			err = encoder.Encode(event)
+		}
+		if err != nil {
+			s.log.
+				Error("unable to encode log for Splunk",
+					slog.String("error", err.Error()),
+				)
+			s.failed([]types.ParsedLine{line}, err)
+			continue
+		}
+		encoded = append(encoded, line)
+	}
+	if len(encoded) == 0 {
+		return
+	}
+
+	// copied, since it may need to be resent after the next batch is encoded
+	s.deliver(&splunkBatch{body: bytes.Clone(s.bodyBuf.Bytes()), count: len(encoded), lines: encoded})
+
+	if s.cfg.UseAck {
+		s.checkAcks()
+	}
+}
+
+type splunkEvent struct {
+	// epoch seconds, with fractional milli/microseconds
+	Time       json.Number `json:"time"`
+	Host       string      `json:"host,omitempty"`
+	Source     string      `json:"source,omitempty"`
+	SourceType string      `json:"sourcetype,omitempty"`
+	Index      string      `json:"index,omitempty"`
+	// the line, or its formatted json object / string
+	Event any `json:"event"`
+}
+
+func (s *SplunkStream) event(line types.ParsedLine) (splunkEvent, error) {
+	ts := line.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	source := s.cfg.Source
+	if source == "" {
+		source = line.Source
+	}
+
+	event := splunkEvent{
+		Time:       splunkTime(ts),
+		Host:       line.Host,
+		Source:     source,
+		SourceType: s.cfg.SourceType,
+		Index:      s.cfg.Index,
+		Event:      line,
+	}
+	if s.format == nil {
+		return event, nil
+	}
+
+	formatted, err := s.format(nil, line)
+	if err != nil {
+		return splunkEvent{}, err
+	}
+	if isJSONFormat(s.cfg.Format.Format) {
+		event.Event = json.RawMessage(formatted)
+	} else {
+		event.Event = string(formatted)
+	}
+	return event, nil
+}
+
+// formatted from integers, float64 loses the microseconds
+func splunkTime(ts time.Time) json.Number {
+	return json.Number(fmt.Sprintf("%d.%06d", ts.Unix(), ts.Nanosecond()/1000))
+}
+
+func (s *SplunkStream) deliver(batch *splunkBatch) {
+	policy := retryPolicy{
+		MaxRetries: s.cfg.MaxRetries,
+		Backoff:    s.cfg.Backoff,
+		MaxBackoff: s.cfg.MaxBackoff,
+	}
+
+	var ackID uint64
+	err := retryWithBackoff(s.ctx, s.log, policy, func() error {
+		var err error
+		ackID, err = s.post(batch.body)
+		return err
+	})
+	if err != nil {
+		s.log.
+			Error("error sending logs to Splunk, dropping",
+				slog.Int("numDropped", batch.count),
+				slog.String("error", err.Error()),
+			)
+		s.failed(batch.lines, err)
+		return
+	}
+
+	if !s.cfg.UseAck {
+		s.log.Info("sent logs",
+			slog.Int("numSent", batch.count),
+		)
+		s.sent(batch.lines)
+		return
+	}
+
+	batch.sentAt = time.Now()
+	batch.attempts += 1
+	s.pendingAcks[ackID] = batch
+}
+
+// posts the events, returning the ack id (when acks are enabled)
+func (s *SplunkStream) post(body []byte) (uint64, error) {
+	resp, err := s.request(s.cfg.URL+"/services/collector/event", body)
+	if err != nil {
+		return 0, err
+	}
+
+	result := struct {
+		AckID *uint64 `json:"ackId"`
+	}{}
+	if s.cfg.UseAck {
+		err = json.Unmarshal(resp, &result)
+		if err != nil || result.AckID == nil {
+			return 0, fmt.Errorf("HEC response missing ackId, is indexer acknowledgement enabled for the token? %s", resp)
+		}
+		return *result.AckID, nil
+	}
+	return 0, nil
+}
+
+func (s *SplunkStream) request(u string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range s.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Splunk "+s.cfg.Token)
+	if s.channel != "" {
+		req.Header.Set("X-Splunk-Request-Channel", s.channel)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, HTTPResponseError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			Body:       string(respBody[:min(len(respBody), 4096)]),
+		}
+	}
+	return respBody, nil
+}
+
+//#> Splunk Stream
+
+//#< Splunk Acks
+
+// asks which of the pending batches have been indexed, resending any which
+// have been waiting too long.
+func (s *SplunkStream) checkAcks() {
+	if len(s.pendingAcks) == 0 {
+		return
+	}
+
+	ids := slices.Sorted(maps.Keys(s.pendingAcks))
+	acked, err := s.queryAcks(ids)
+	if err != nil {
+		s.log.
+			Warn("unable to check Splunk acks",
+				slog.Int("pending", len(ids)),
+				slog.String("error", err.Error()),
+			)
+	}
+
+	sent := 0
+	for _, id := range ids {
+		batch := s.pendingAcks[id]
+		if acked[id] {
+			sent += batch.count
+			delete(s.pendingAcks, id)
+			s.sent(batch.lines)
+			continue
+		}
+		if time.Since(batch.sentAt) < s.cfg.AckTimeout {
+			continue
+		}
+
+		delete(s.pendingAcks, id)
+		if batch.attempts > s.cfg.MaxRetries {
+			s.log.
+				Error("Splunk never acknowledged logs, dropping",
+					slog.Int("numDropped", batch.count),
+					slog.Int("attempts", batch.attempts),
+				)
+			s.failed(batch.lines, ErrNotAcknowledged)
+			continue
+		}
+
+		s.log.
+			Warn("Splunk didn't acknowledge logs, resending",
+				slog.Int("numResent", batch.count),
+				slog.Uint64("ackId", id),
+			)
+		s.deliver(batch)
+	}
+
+	if sent > 0 {
+		s.log.Info("sent logs",
+			slog.Int("numSent", sent),
+		)
+	}
+}
+
+// checks on the pending batches when no more are being sent, returning
+// whether any are still waiting.
+func (s *SplunkStream) pollAcks() bool {
+	s.checkAcks()
+	return len(s.pendingAcks) > 0
+}
+
+func (s *SplunkStream) queryAcks(ids []uint64) (map[uint64]bool, error) {
+	body, err := json.Marshal(map[string][]uint64{"acks": ids})
+	if err != nil {
+		return nil, err
+	}
+
+	u := s.cfg.URL + "/services/collector/ack?channel=" + url.QueryEscape(s.channel)
+	resp, err := s.request(u, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// {"acks":{"0":true,"1":false}}
+	result := struct {
+		Acks map[string]bool `json:"acks"`
+	}{}
+	err = json.Unmarshal(resp, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	acked := make(map[uint64]bool, len(result.Acks))
+	for id, ok := range result.Acks {
+		n, err := strconv.ParseUint(id, 10, 64)
+		if err == nil {
+			acked[n] = ok
+		}
+	}
+	return acked, nil
+}
+
+// waits for the last batches to be acknowledged, or given up on once
+// they've been resent `MaxRetries` times.
+func (s *SplunkStream) drainAcks() {
+waiting:
+	for len(s.pendingAcks) > 0 {
+		select {
+		case <-s.ctx.Done():
+			break waiting
+		case <-time.After(s.ackPoll):
+		}
+		s.checkAcks()
+	}
+
+	pending := 0
+	for _, batch := range s.pendingAcks {
+		pending += batch.count
+		s.failed(batch.lines, ErrNotAcknowledged)
+	}
+	if pending > 0 {
+		s.log.
+			Error("closing with unacknowledged Splunk logs",
+				slog.Int("numUnacknowledged", pending),
+			)
+	}
+}
+
+// HEC channels are GUIDs
+func newSplunkChannel() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant 10
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+//#> Splunk Acks
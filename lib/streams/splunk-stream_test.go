@@ -0,0 +1,208 @@
+package streams
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/types"
+)
+
+// a stub HEC endpoint, which never acknowledges the ack ids in `lost`
+type stubSplunkServer struct {
+	*httptest.Server
+
+	lost map[uint64]bool
+
+	lock     sync.Mutex
+	nextAck  uint64
+	events   []map[string]any
+	auth     []string
+	channels []string
+	ackPolls int
+}
+
+func newStubSplunkServer(lost ...uint64) *stubSplunkServer {
+	s := &stubSplunkServer{lost: map[uint64]bool{}}
+	for _, id := range lost {
+		s.lost[id] = true
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *stubSplunkServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.auth = append(s.auth, r.Header.Get("Authorization"))
+	s.channels = append(s.channels, r.Header.Get("X-Splunk-Request-Channel"))
+
+	switch r.URL.Path {
+	case "/services/collector/event":
+		decoder := json.NewDecoder(r.Body)
+		for decoder.More() {
+			var event map[string]any
+			if err := decoder.Decode(&event); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			s.events = append(s.events, event)
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{"text": "Success", "code": 0, "ackId": s.nextAck})
+		s.nextAck += 1
+
+	case "/services/collector/ack":
+		s.ackPolls += 1
+		req := struct {
+			Acks []uint64 `json:"acks"`
+		}{}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		acks := map[string]bool{}
+		for _, id := range req.Acks {
+			acks[jsonString(id)] = !s.lost[id]
+		}
+		json.NewEncoder(w).Encode(map[string]any{"acks": acks})
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func jsonString(v any) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func (s *stubSplunkServer) messages() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	msgs := []string{}
+	for _, event := range s.events {
+		msgs = append(msgs, event["event"].(map[string]any)["@m"].(string))
+	}
+	return msgs
+}
+
+func TestSplunkStream_Events(t *testing.T) {
+	server := newStubSplunkServer()
+	defer server.Close()
+
+	s, err := NewSplunkStream(t.Context(), config.OutputSplunkCfg{
+		HTTPOutputCfg: config.HTTPOutputCfg{URL: server.URL},
+		Token:         "token",
+		Index:         "main",
+		SourceType:    "reform",
+	})
+	if err != nil {
+		t.Fatalf("NewSplunkStream() error = %v", err)
+	}
+	s.Output(types.ParsedLine{
+		Timestamp: time.Date(2025, 3, 4, 5, 6, 7, 123456789, time.UTC),
+		Host:      "hst-name001",
+		Message:   "hello",
+		Source:    "lab",
+	})
+	s.Close()
+
+	if len(server.events) != 1 {
+		t.Fatalf("got %v events, want 1", len(server.events))
+	}
+	event := server.events[0]
+
+	// compared as text, since it's decoded as a float64
+	if got := jsonString(event["time"]); got != "1741064767.123456" {
+		t.Errorf("time = %v, want %v", got, "1741064767.123456")
+	}
+	want := map[string]string{
+		"host":       "hst-name001",
+		"source":     "lab",
+		"sourcetype": "reform",
+		"index":      "main",
+	}
+	for key, value := range want {
+		if event[key] != value {
+			t.Errorf("%v = %v, want %v", key, event[key], value)
+		}
+	}
+	if server.auth[0] != "Splunk token" {
+		t.Errorf("Authorization = %q, want %q", server.auth[0], "Splunk token")
+	}
+	if server.ackPolls != 0 {
+		t.Errorf("got %v ack polls with acks disabled", server.ackPolls)
+	}
+}
+
+func TestSplunkStream_Acks(t *testing.T) {
+	// the first batch is never acknowledged, so it has to be resent
+	server := newStubSplunkServer(0)
+	defer server.Close()
+
+	s, err := NewSplunkStream(t.Context(), config.OutputSplunkCfg{
+		HTTPOutputCfg: config.HTTPOutputCfg{URL: server.URL},
+		Token:         "token",
+		UseAck:        true,
+		AckTimeout:    time.Millisecond * 50,
+	})
+	if err != nil {
+		t.Fatalf("NewSplunkStream() error = %v", err)
+	}
+	s.Output(types.ParsedLine{Message: "hello"})
+	s.Close()
+
+	msgs := server.messages()
+	if len(msgs) != 2 || msgs[0] != "hello" || msgs[1] != "hello" {
+		t.Errorf("messages = %v, want the batch sent twice", msgs)
+	}
+	if len(s.pendingAcks) != 0 {
+		t.Errorf("%v batches still waiting on acks", len(s.pendingAcks))
+	}
+	for _, channel := range server.channels {
+		if channel == "" || channel != server.channels[0] {
+			t.Errorf("X-Splunk-Request-Channel = %q, want a consistent channel", channel)
+		}
+	}
+}
+
+func TestSplunkStream_PollsAcks(t *testing.T) {
+	// the first send is never acknowledged, and no more batches arrive to
+	// check on it, so it's only resent (and then acked) by polling
+	server := newStubSplunkServer(0)
+	defer server.Close()
+
+	s, err := NewSplunkStream(t.Context(), config.OutputSplunkCfg{
+		HTTPOutputCfg: config.HTTPOutputCfg{URL: server.URL, BatchWait: time.Millisecond * 10},
+		Token:         "token",
+		UseAck:        true,
+		AckTimeout:    time.Millisecond * 50,
+	})
+	if err != nil {
+		t.Fatalf("NewSplunkStream() error = %v", err)
+	}
+	defer s.Close()
+
+	delivered := make(chan Delivery, 1)
+	s.OnDelivery(func(d Delivery) { delivered <- d })
+	s.Output(types.ParsedLine{Message: "hello"})
+
+	select {
+	case d := <-delivered:
+		if d.Sent != 1 || len(d.Failed) != 0 {
+			t.Errorf("got %+v, want the line sent", d)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatalf("the batch was never acknowledged")
+	}
+
+	msgs := server.messages()
+	if len(msgs) != 2 {
+		t.Errorf("messages = %v, want the batch resent once", msgs)
+	}
+}
@@ -0,0 +1,272 @@
+package streams
+
+import (
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/types"
+)
+
+//#< Syslog Stream
+
+// SyslogStream re-emits lines as RFC 5424 (or RFC 3164) syslog messages.
+type SyslogStream struct {
+	cfg    config.OutputSyslogCfg
+	format lineFormatter // nil sends the plain message
+
+	lock   sync.Mutex
+	closed bool
+	writer *netWriter
+
+	// used when a line doesn't have a host
+	defaultHost string
+
+	// reused across messages
+	buf []byte
+}
+
+func NewSyslogStream(cfg config.OutputSyslogCfg) (*SyslogStream, error) {
+	cfg = cfg.WithDefaults()
+
+	writer, err := newNetWriter(cfg.NetOutputCfg)
+	if err != nil {
+		return nil, err
+	}
+	format, err := newLineFormatter(cfg.MessageFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultHost, err := os.Hostname()
+	if err != nil {
+		defaultHost = "-"
+	}
+
+	return &SyslogStream{
+		cfg:         cfg,
+		format:      format,
+		writer:      writer,
+		defaultHost: defaultHost,
+	}, nil
+}
+
+func (s *SyslogStream) Output(line types.ParsedLine) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return ErrStreamClosed
+	}
+
+	if s.format != nil {
+		formatted, err := s.format(nil, line)
+		if err != nil {
+			return err
+		}
+		line.Message = string(formatted)
+	}
+
+	newlineFramed := s.writer.isStream() && s.cfg.Framing == config.SyslogFraming_Newline
+	if newlineFramed && strings.Contains(line.Message, "\n") {
+		// a newline would end the message early
+		line.Message = strings.ReplaceAll(line.Message, "\n", " ")
+	}
+
+	var msg []byte
+	if s.cfg.Format == config.SyslogFormat_RFC3164 {
+		msg = s.appendRFC3164(s.buf[:0], line)
+	} else {
+		msg = s.appendRFC5424(s.buf[:0], line)
+	}
+	s.buf = msg
+
+	if s.writer.isStream() {
+		if newlineFramed {
+			msg = append(msg, '\n')
+		} else {
+			frame := strconv.AppendInt(nil, int64(len(msg)), 10)
+			frame = append(frame, ' ')
+			msg = append(frame, msg...)
+		}
+	}
+
+	return s.writer.write(msg)
+}
+
+func (s *SyslogStream) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+	s.writer.close()
+}
+
+func (s *SyslogStream) priority(line types.ParsedLine) int {
+	return int(s.cfg.Facility)*8 + int(line.Severity())
+}
+
+func (s *SyslogStream) host(line types.ParsedLine) string {
+	if line.Host != "" {
+		return line.Host
+	}
+	return s.defaultHost
+}
+
+// <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID PARAM="VALUE" ...] MSG
+func (s *SyslogStream) appendRFC5424(b []byte, line types.ParsedLine) []byte {
+	ts := line.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	appName := line.Process.Name
+	if appName == "" {
+		appName = s.cfg.AppName
+	}
+	procID := "-"
+	if line.Process.PID != 0 {
+		procID = strconv.FormatUint(line.Process.PID, 10)
+	}
+
+	b = append(b, '<')
+	b = strconv.AppendInt(b, int64(s.priority(line)), 10)
+	b = append(b, ">1 "...)
+	b = ts.AppendFormat(b, "2006-01-02T15:04:05.000000Z07:00")
+	b = append(b, ' ')
+	b = appendSyslogHeaderField(b, s.host(line), 255)
+	b = append(b, ' ')
+	b = appendSyslogHeaderField(b, appName, 48)
+	b = append(b, ' ')
+	b = appendSyslogHeaderField(b, procID, 128)
+	// MSGID
+	b = append(b, " - "...)
+	b = s.appendStructuredData(b, line)
+	if line.Message != "" {
+		b = append(b, ' ')
+		b = append(b, line.Message...)
+	}
+	return b
+}
+
+// everything that doesn't have a place in the header goes into a single
+// structured data element
+func (s *SyslogStream) appendStructuredData(b []byte, line types.ParsedLine) []byte {
+	params := [][2]string{}
+	for _, name := range []string{
+		types.Field_Level,
+		types.Field_TID,
+		types.Field_Source,
+		types.Field_File,
+		types.Field_Line,
+		types.Field_Language,
+	} {
+		if value, ok := line.Field(name); ok {
+			params = append(params, [2]string{name, value})
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(line.Properties)) {
+		value, _ := line.Field(key)
+		params = append(params, [2]string{key, value})
+	}
+
+	if len(params) == 0 {
+		return append(b, '-')
+	}
+
+	b = append(b, '[')
+	b = append(b, s.cfg.SDID...)
+	for _, param := range params {
+		b = append(b, ' ')
+		b = appendSyslogParamName(b, param[0])
+		b = append(b, `="`...)
+		for _, c := range []byte(param[1]) {
+			// `"`, `\` and `]` have to be escaped within param values
+			if c == '"' || c == '\\' || c == ']' {
+				b = append(b, '\\')
+			}
+			b = append(b, c)
+		}
+		b = append(b, '"')
+	}
+	return append(b, ']')
+}
+
+// <PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG
+func (s *SyslogStream) appendRFC3164(b []byte, line types.ParsedLine) []byte {
+	ts := line.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	tag := line.Process.Name
+	if tag == "" {
+		tag = s.cfg.AppName
+	}
+
+	b = append(b, '<')
+	b = strconv.AppendInt(b, int64(s.priority(line)), 10)
+	b = append(b, '>')
+	b = ts.AppendFormat(b, time.Stamp)
+	b = append(b, ' ')
+	b = appendSyslogHeaderField(b, s.host(line), 255)
+	b = append(b, ' ')
+
+	// the tag is limited to 32 alphanumeric chars
+	n := 0
+	for _, c := range []byte(tag) {
+		if n >= 32 {
+			break
+		}
+		isAlnum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if isAlnum || c == '-' || c == '_' || c == '.' {
+			b = append(b, c)
+			n += 1
+		}
+	}
+	if line.Process.PID != 0 {
+		b = append(b, '[')
+		b = strconv.AppendUint(b, line.Process.PID, 10)
+		b = append(b, ']')
+	}
+	b = append(b, ": "...)
+	return append(b, line.Message...)
+}
+
+// header fields are printable US-ASCII without spaces, or `-` if empty
+func appendSyslogHeaderField(b []byte, value string, maxLen int) []byte {
+	if value == "" {
+		return append(b, '-')
+	}
+	for i, c := range []byte(value) {
+		if i >= maxLen {
+			break
+		}
+		if c < 33 || c > 126 {
+			c = '_'
+		}
+		b = append(b, c)
+	}
+	return b
+}
+
+// param names can't contain `=`, ` `, `]`, or `"`, and are at most 32 chars
+func appendSyslogParamName(b []byte, name string) []byte {
+	for i, c := range []byte(name) {
+		if i >= 32 {
+			break
+		}
+		if c < 33 || c > 126 || c == '=' || c == ']' || c == '"' {
+			c = '_'
+		}
+		b = append(b, c)
+	}
+	return b
+}
+
+//#> Syslog Stream
@@ -0,0 +1,184 @@
+package streams
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/types"
+)
+
+func TestSyslogStream_format(t *testing.T) {
+	ts := time.Date(2025, 3, 4, 5, 6, 7, 8000, time.UTC)
+	line := types.ParsedLine{
+		Timestamp:  ts,
+		Host:       "hst-name001",
+		Process:    types.ProcessInfo{Name: "acme", PID: 12},
+		Message:    "hello world",
+		LogLevel:   "error",
+		SourceInfo: types.SourceFileInfo{Filename: "main.c", LineNumber: 890},
+		Properties: map[string]any{"path": `C:\[x]`},
+	}
+
+	tests := []struct {
+		name string
+		cfg  config.OutputSyslogCfg
+		line types.ParsedLine
+		want string
+	}{
+		{
+			name: "rfc5424",
+			cfg:  config.OutputSyslogCfg{Facility: config.SyslogFacility_Local0},
+			line: line,
+			want: `<131>1 2025-03-04T05:06:07.000008Z hst-name001 acme 12 - [reform@32473 level="error" file="main.c" line="890" path="C:\\[x\]"] hello world`,
+		},
+		{
+			name: "rfc5424 without extra fields",
+			cfg:  config.OutputSyslogCfg{Facility: config.SyslogFacility_User},
+			line: types.ParsedLine{Timestamp: ts, Host: "hst name", Message: "hi"},
+			want: `<14>1 2025-03-04T05:06:07.000008Z hst_name reform - - - hi`,
+		},
+		{
+			name: "rfc3164",
+			cfg:  config.OutputSyslogCfg{Format: config.SyslogFormat_RFC3164, Facility: config.SyslogFacility_Daemon},
+			line: line,
+			want: `<27>Mar  4 05:06:07 hst-name001 acme[12]: hello world`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &SyslogStream{cfg: tt.cfg.WithDefaults(), defaultHost: "reform-host"}
+
+			var got []byte
+			if tt.cfg.Format == config.SyslogFormat_RFC3164 {
+				got = s.appendRFC3164(nil, tt.line)
+			} else {
+				got = s.appendRFC5424(nil, tt.line)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got vs want:\n  %s\n  %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyslogStream_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() error = %v", err)
+	}
+	defer conn.Close()
+
+	s, err := NewSyslogStream(config.OutputSyslogCfg{
+		NetOutputCfg: config.NetOutputCfg{Address: conn.LocalAddr().String()},
+	})
+	if err != nil {
+		t.Fatalf("NewSyslogStream() error = %v", err)
+	}
+	defer s.Close()
+
+	if err := s.Output(types.ParsedLine{Message: "over udp", LogLevel: "warn"}); err != nil {
+		t.Fatalf("Output() error = %v", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	buf := make([]byte, 2048)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatalf("ReadFrom() error = %v", err)
+	}
+	got := string(buf[:n])
+	if !strings.HasPrefix(got, "<4>1 ") || !strings.HasSuffix(got, " over udp") {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestSyslogStream_TCPFraming(t *testing.T) {
+	tests := []struct {
+		name    string
+		framing config.SyslogFraming
+	}{
+		{
+			name:    "octet counting",
+			framing: config.SyslogFraming_OctetCounting,
+		},
+		{
+			name:    "newline",
+			framing: config.SyslogFraming_Newline,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Listen() error = %v", err)
+			}
+			defer listener.Close()
+
+			received := make(chan []string)
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					close(received)
+					return
+				}
+				defer conn.Close()
+
+				msgs := []string{}
+				reader := bufio.NewReader(conn)
+				for {
+					var msg string
+					if tt.framing == config.SyslogFraming_Newline {
+						msg, err = reader.ReadString('\n')
+						msg = strings.TrimSuffix(msg, "\n")
+					} else {
+						var size string
+						size, err = reader.ReadString(' ')
+						if err == nil {
+							n, _ := strconv.Atoi(strings.TrimSpace(size))
+							buf := make([]byte, n)
+							_, err = io.ReadFull(reader, buf)
+							msg = string(buf)
+						}
+					}
+					if err != nil {
+						break
+					}
+					msgs = append(msgs, msg[strings.LastIndex(msg, " - ")+3:])
+				}
+				received <- msgs
+			}()
+
+			s, err := NewSyslogStream(config.OutputSyslogCfg{
+				NetOutputCfg: config.NetOutputCfg{
+					Address:  listener.Addr().String(),
+					Protocol: config.NetProtocol_TCP,
+				},
+				Framing: tt.framing,
+			})
+			if err != nil {
+				t.Fatalf("NewSyslogStream() error = %v", err)
+			}
+			for _, msg := range []string{"one", "multi\nline"} {
+				if err := s.Output(types.ParsedLine{Message: msg}); err != nil {
+					t.Fatalf("Output() error = %v", err)
+				}
+			}
+			s.Close()
+
+			want := []string{"one", "multi\nline"}
+			if tt.framing == config.SyslogFraming_Newline {
+				want = []string{"one", "multi line"}
+			}
+			if got := <-received; !reflect.DeepEqual(got, want) {
+				t.Errorf("received = %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package streams
+
+import (
+	"hash/fnv"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/types"
+)
+
+//#< Terminal Stream
+
+const (
+	// the host / process columns grow to fit what's been seen, up to these widths
+	maxTerminalHostWidth = 20
+	maxTerminalProcWidth = 24
+	// messages get at least this much room, even on a narrow terminal
+	minTerminalMessageWidth = 20
+
+	// how often the terminal's width is checked, to pick up resizes
+	terminalWidthInterval = time.Second
+)
+
+const (
+	ansiReset   = "\x1b[0m"
+	ansiRed     = "\x1b[31m"
+	ansiBoldRed = "\x1b[1;31m"
+	ansiGreen   = "\x1b[32m"
+	ansiYellow  = "\x1b[33m"
+	ansiCyan    = "\x1b[36m"
+	ansiGray    = "\x1b[90m"
+)
+
+// hosts are given a (stable) color from here, by the hash of their name
+var terminalHostColors = []string{
+	"\x1b[34m", "\x1b[35m", "\x1b[36m", "\x1b[32m", "\x1b[33m", "\x1b[94m", "\x1b[95m", "\x1b[96m",
+}
+
+func NewTerminalStream(cfg config.OutputStdoutCfg) *TerminalStream {
+	fd := int(os.Stdout.Fd())
+	isTerminal := term.IsTerminal(fd)
+
+	t := newTerminalStream(cfg, os.Stdout, isTerminal)
+	if isTerminal && cfg.Width == 0 {
+		t.termWidth = func() int {
+			width, _, err := term.GetSize(fd)
+			if err != nil {
+				return 0
+			}
+			return width
+		}
+	}
+	return t
+}
+
+func newTerminalStream(cfg config.OutputStdoutCfg, out io.Writer, isTerminal bool) *TerminalStream {
+	color := false
+	switch cfg.Color {
+	case config.ColorMode_Always:
+		color = true
+	case config.ColorMode_Auto:
+		_, noColor := os.LookupEnv("NO_COLOR")
+		color = isTerminal && !noColor
+	}
+
+	return &TerminalStream{
+		out:   out,
+		color: color,
+		wrap:  cfg.Wrap,
+		width: cfg.Width,
+	}
+}
+
+// TerminalStream prints lines as aligned, colorized columns:
+//
+//	15:04:05.000 WARN  hst-name001 acme[42]   the message, truncated (or wrapped) to fit
+type TerminalStream struct {
+	out   io.Writer
+	color bool
+	wrap  bool
+
+	// 0 is unlimited
+	width        int
+	termWidth    func() int // nil when the width is fixed
+	widthChecked time.Time
+
+	hostWidth int
+	procWidth int
+
+	buf []byte
+}
+
+func (t *TerminalStream) Output(line types.ParsedLine) error {
+	if t.termWidth != nil && time.Since(t.widthChecked) > terminalWidthInterval {
+		t.width = t.termWidth()
+		t.widthChecked = time.Now()
+	}
+
+	b := t.buf[:0]
+
+	// time
+	if line.Timestamp.IsZero() {
+		b = append(b, "            "...)
+	} else {
+		b = t.appendColored(b, ansiGray, line.Timestamp.Format("15:04:05.000"))
+	}
+	b = append(b, ' ')
+
+	// level
+	label, levelColor := terminalLevel(line)
+	b = t.appendColored(b, levelColor, padRunes(label, 5))
+	b = append(b, ' ')
+
+	// host
+	t.hostWidth = min(max(t.hostWidth, utf8.RuneCountInString(line.Host)), maxTerminalHostWidth)
+	if t.hostWidth > 0 {
+		b = t.appendColored(b, terminalHostColor(line.Host), padRunes(line.Host, t.hostWidth))
+		b = append(b, ' ')
+	}
+
+	// process
+	proc := procLabel(line)
+	t.procWidth = min(max(t.procWidth, utf8.RuneCountInString(proc)), maxTerminalProcWidth)
+	if t.procWidth > 0 {
+		b = append(b, padRunes(proc, t.procWidth)...)
+		b = append(b, ' ')
+	}
+
+	// message, in whatever room is left
+	indent := 12 + 1 + 5 + 1
+	if t.hostWidth > 0 {
+		indent += t.hostWidth + 1
+	}
+	if t.procWidth > 0 {
+		indent += t.procWidth + 1
+	}
+
+	msgWidth := 0
+	if t.width > 0 {
+		msgWidth = max(t.width-indent, minTerminalMessageWidth)
+	}
+
+	message := strings.TrimRight(line.Message, "\r\n")
+	if !t.wrap {
+		if first, _, multiline := strings.Cut(message, "\n"); multiline {
+			message = first + " …"
+		}
+		if msgWidth > 0 {
+			message = truncateRunes(message, msgWidth)
+		}
+		b = append(b, message...)
+		b = append(b, '\n')
+	} else {
+		for i, row := range wrapRunes(message, msgWidth) {
+			if i > 0 {
+				b = append(b, strings.Repeat(" ", indent)...)
+			}
+			b = append(b, row...)
+			b = append(b, '\n')
+		}
+	}
+
+	t.buf = b
+	_, err := t.out.Write(b)
+	return err
+}
+
+func (t *TerminalStream) Close() {}
+
+func (t *TerminalStream) appendColored(b []byte, color string, str string) []byte {
+	if !t.color || color == "" {
+		return append(b, str...)
+	}
+	b = append(b, color...)
+	b = append(b, str...)
+	return append(b, ansiReset...)
+}
+
+// a short, fixed width label for the line's level, and its color
+func terminalLevel(line types.ParsedLine) (string, string) {
+	if line.LogLevel == "" {
+		return "", ""
+	}
+
+	severity, known := types.LevelSeverity(line.LogLevel)
+	if !known {
+		return truncateRunes(strings.ToUpper(line.LogLevel), 5), ""
+	}
+	return severityLabel(severity)
+}
+
+func severityLabel(severity types.Severity) (string, string) {
+	switch severity {
+	case types.Severity_Emergency:
+		return "EMERG", ansiBoldRed
+	case types.Severity_Alert:
+		return "ALERT", ansiBoldRed
+	case types.Severity_Critical:
+		return "CRIT", ansiBoldRed
+	case types.Severity_Error:
+		return "ERROR", ansiRed
+	case types.Severity_Warning:
+		return "WARN", ansiYellow
+	case types.Severity_Notice:
+		return "NOTE", ansiCyan
+	case types.Severity_Info:
+		return "INFO", ansiGreen
+	default:
+		return "DEBUG", ansiGray
+	}
+}
+
+// ie `sshd[42]`
+func procLabel(line types.ParsedLine) string {
+	if line.Process.PID == 0 {
+		return line.Process.Name
+	}
+	return line.Process.Name + "[" + strconv.FormatUint(line.Process.PID, 10) + "]"
+}
+
+func terminalHostColor(host string) string {
+	if host == "" {
+		return ""
+	}
+	h := fnv.New32a()
+	h.Write([]byte(host))
+	return terminalHostColors[h.Sum32()%uint32(len(terminalHostColors))]
+}
+
+// truncates `str` to `width` runes, marking the cut with '…'
+func truncateRunes(str string, width int) string {
+	if utf8.RuneCountInString(str) <= width {
+		return str
+	}
+	if width <= 1 {
+		return string([]rune(str)[:max(width, 0)])
+	}
+	return string([]rune(str)[:width-1]) + "…"
+}
+
+// truncates or pads `str` to exactly `width` runes
+func padRunes(str string, width int) string {
+	str = truncateRunes(str, width)
+	if n := utf8.RuneCountInString(str); n < width {
+		str += strings.Repeat(" ", width-n)
+	}
+	return str
+}
+
+// splits `str` into rows of at most `width` runes, breaking at spaces where
+// possible.  a `width` of 0 only splits on newlines.
+func wrapRunes(str string, width int) []string {
+	rows := []string{}
+	for _, line := range strings.Split(str, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if width <= 0 {
+			rows = append(rows, line)
+			continue
+		}
+
+		runes := []rune(line)
+		for len(runes) > width {
+			cut := width
+			for i := width; i > width/2; i-- {
+				if runes[i] == ' ' {
+					cut = i
+					break
+				}
+			}
+			rows = append(rows, strings.TrimRight(string(runes[:cut]), " "))
+			runes = runes[cut:]
+			for len(runes) > 0 && runes[0] == ' ' {
+				runes = runes[1:]
+			}
+		}
+		rows = append(rows, string(runes))
+	}
+	return rows
+}
+
+//#> Terminal Stream
@@ -0,0 +1,155 @@
+package streams
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/erobsham/reform/lib/config"
+	"github.com/erobsham/reform/lib/types"
+)
+
+func TestTerminalStream_Output(t *testing.T) {
+	ts := time.Date(2025, 3, 4, 5, 6, 7, 8000000, time.UTC)
+
+	tests := []struct {
+		name  string
+		cfg   config.OutputStdoutCfg
+		lines []types.ParsedLine
+		want  string
+	}{
+		{
+			name: "aligned columns",
+			cfg:  config.OutputStdoutCfg{Color: config.ColorMode_Never},
+			lines: []types.ParsedLine{
+				{Timestamp: ts, LogLevel: "warning", Host: "hst-name001", Process: types.ProcessInfo{Name: "acme", PID: 42}, Message: "first"},
+				{Timestamp: ts, LogLevel: "err", Host: "hst-2", Process: types.ProcessInfo{Name: "kernel"}, Message: "second"},
+			},
+			want: "" +
+				"05:06:07.008 WARN  hst-name001 acme[42] first\n" +
+				"05:06:07.008 ERROR hst-2       kernel   second\n",
+		},
+		{
+			name: "truncated to width",
+			cfg:  config.OutputStdoutCfg{Color: config.ColorMode_Never, Width: 45},
+			lines: []types.ParsedLine{
+				{Timestamp: ts, Host: "hst", Message: "ünïcödé messages are cut by rune, not byte"},
+			},
+			want: "05:06:07.008       hst ünïcödé messages are …\n",
+		},
+		{
+			name: "multi-line message",
+			cfg:  config.OutputStdoutCfg{Color: config.ColorMode_Never},
+			lines: []types.ParsedLine{
+				{Message: "first line\nsecond line"},
+			},
+			want: "                   first line …\n",
+		},
+		{
+			name: "wrapped",
+			cfg:  config.OutputStdoutCfg{Color: config.ColorMode_Never, Width: 45, Wrap: true},
+			lines: []types.ParsedLine{
+				{Timestamp: ts, Host: "hst", Message: "a long message which is wrapped onto extra rows\nand new lines"},
+			},
+			want: "" +
+				"05:06:07.008       hst a long message which\n" +
+				"                       is wrapped onto extra\n" +
+				"                       rows\n" +
+				"                       and new lines\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := bytes.Buffer{}
+			s := newTerminalStream(tt.cfg, &out, false)
+			for _, line := range tt.lines {
+				if err := s.Output(line); err != nil {
+					t.Fatalf("Output() error = %v", err)
+				}
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("Output() got vs want:\n%s\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTerminalStream_Color(t *testing.T) {
+	line := types.ParsedLine{LogLevel: "error", Host: "hst-name001", Message: "boom"}
+
+	tests := []struct {
+		name       string
+		color      config.ColorMode
+		isTerminal bool
+		noColorEnv bool
+		wantColor  bool
+	}{
+		{name: "auto, terminal", color: config.ColorMode_Auto, isTerminal: true, wantColor: true},
+		{name: "auto, not a terminal", color: config.ColorMode_Auto, isTerminal: false, wantColor: false},
+		{name: "auto, NO_COLOR", color: config.ColorMode_Auto, isTerminal: true, noColorEnv: true, wantColor: false},
+		{name: "always", color: config.ColorMode_Always, isTerminal: false, wantColor: true},
+		{name: "never", color: config.ColorMode_Never, isTerminal: true, wantColor: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.noColorEnv {
+				t.Setenv("NO_COLOR", "1")
+			}
+
+			out := bytes.Buffer{}
+			s := newTerminalStream(config.OutputStdoutCfg{Color: tt.color}, &out, tt.isTerminal)
+			s.Output(line)
+
+			hasColor := strings.Contains(out.String(), ansiRed+"ERROR"+ansiReset)
+			if hasColor != tt.wantColor {
+				t.Errorf("Output() = %q, want color %v", out.String(), tt.wantColor)
+			}
+			if tt.wantColor && !strings.Contains(out.String(), terminalHostColor(line.Host)+"hst-name001") {
+				t.Errorf("Output() = %q, expected a colored host", out.String())
+			}
+		})
+	}
+}
+
+func Test_wrapRunes(t *testing.T) {
+	tests := []struct {
+		name  string
+		str   string
+		width int
+		want  []string
+	}{
+		{
+			name:  "fits",
+			str:   "short",
+			width: 10,
+			want:  []string{"short"},
+		},
+		{
+			name:  "breaks at spaces",
+			str:   "one two three four",
+			width: 9,
+			want:  []string{"one two", "three", "four"},
+		},
+		{
+			name:  "hard break",
+			str:   "ääääääääää",
+			width: 4,
+			want:  []string{"ääää", "ääää", "ää"},
+		},
+		{
+			name:  "unlimited",
+			str:   "a\nb",
+			width: 0,
+			want:  []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wrapRunes(tt.str, tt.width); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wrapRunes() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
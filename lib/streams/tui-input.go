@@ -0,0 +1,106 @@
+package streams
+
+import (
+	"unicode/utf8"
+)
+
+//#< TUI Keys
+
+type tuiKeyCode uint8
+
+const (
+	key_Rune tuiKeyCode = iota
+	key_Enter
+	key_Escape
+	key_Backspace
+	key_CtrlC
+	key_Up
+	key_Down
+	key_PageUp
+	key_PageDown
+	key_Home
+	key_End
+	key_Unknown
+)
+
+type tuiKey struct {
+	code tuiKeyCode
+	r    rune // only set for `key_Rune`
+}
+
+func (k tuiKey) is(r rune) bool {
+	return k.code == key_Rune && k.r == r
+}
+
+// the escape sequences terminals send for the keys we care about
+var tuiEscapeSequences = map[string]tuiKeyCode{
+	"\x1b[A":  key_Up,
+	"\x1bOA":  key_Up,
+	"\x1b[B":  key_Down,
+	"\x1bOB":  key_Down,
+	"\x1b[5~": key_PageUp,
+	"\x1b[6~": key_PageDown,
+	"\x1b[H":  key_Home,
+	"\x1bOH":  key_Home,
+	"\x1b[1~": key_Home,
+	"\x1b[7~": key_Home,
+	"\x1b[F":  key_End,
+	"\x1bOF":  key_End,
+	"\x1b[4~": key_End,
+	"\x1b[8~": key_End,
+}
+
+// decodes the keys in a chunk read from a raw mode terminal.  a lone `ESC`
+// is the escape key, otherwise it starts an escape sequence.
+func decodeKeys(data []byte) []tuiKey {
+	keys := []tuiKey{}
+	for len(data) > 0 {
+		switch c := data[0]; {
+		case c == 0x1b:
+			if len(data) == 1 {
+				keys = append(keys, tuiKey{code: key_Escape})
+				data = data[1:]
+				continue
+			}
+
+			// `ESC [` / `ESC O` then parameters, ending with a letter or '~'
+			end := 2
+			for end < len(data) && end < 8 && !isSequenceEnd(data[end-1], end) {
+				end += 1
+			}
+			code, known := tuiEscapeSequences[string(data[:end])]
+			if !known {
+				code = key_Unknown
+			}
+			keys = append(keys, tuiKey{code: code})
+			data = data[end:]
+		case c == '\r' || c == '\n':
+			keys = append(keys, tuiKey{code: key_Enter})
+			data = data[1:]
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, tuiKey{code: key_Backspace})
+			data = data[1:]
+		case c == 0x03:
+			keys = append(keys, tuiKey{code: key_CtrlC})
+			data = data[1:]
+		case c < ' ':
+			keys = append(keys, tuiKey{code: key_Unknown})
+			data = data[1:]
+		default:
+			r, size := utf8.DecodeRune(data)
+			keys = append(keys, tuiKey{code: key_Rune, r: r})
+			data = data[size:]
+		}
+	}
+	return keys
+}
+
+// whether the byte at `idx - 1` ends an escape sequence (the first two bytes never do)
+func isSequenceEnd(c byte, idx int) bool {
+	if idx <= 2 {
+		return false
+	}
+	return (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || c == '~'
+}
+
+//#> TUI Keys
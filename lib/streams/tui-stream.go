@@ -0,0 +1,776 @@
+package streams
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"github.com/erobsham/reform/lib/log"
+	"github.com/erobsham/reform/lib/types"
+)
+
+//#< TUI Stream
+
+const (
+	// events kept for scrolling back through, the oldest are dropped past this
+	DefaultTUIHistory = 10000
+
+	// the screen is redrawn at most this often
+	tuiFrameInterval = time.Millisecond * 50
+)
+
+var ErrNotATerminal = errors.New("the tui needs stdin and stdout to be a terminal")
+
+type tuiMode uint8
+
+const (
+	tuiMode_List tuiMode = iota
+	tuiMode_Search
+	tuiMode_Filters
+	tuiMode_Detail
+)
+
+// NewTUIStream takes over the terminal to show the live stream in a scrollable,
+// filterable list.  `onQuit` is called when the user quits.
+func NewTUIStream(history int, onQuit func()) (*TUIStream, error) {
+	inFd, outFd := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+	if !term.IsTerminal(inFd) || !term.IsTerminal(outFd) {
+		return nil, ErrNotATerminal
+	}
+
+	prevState, err := term.MakeRaw(inFd)
+	if err != nil {
+		return nil, err
+	}
+
+	t := newTUIModel(history)
+	t.onQuit = onQuit
+	t.restore = func() {
+		// leave the alternate screen, and show the cursor again
+		os.Stdout.WriteString("\x1b[?1049l\x1b[?25h")
+		term.Restore(inFd, prevState)
+		log.SetOutput(os.Stderr)
+	}
+	t.size = func() (int, int) {
+		width, height, err := term.GetSize(outFd)
+		if err != nil {
+			return 80, 24
+		}
+		return width, height
+	}
+	t.stopRender = make(chan struct{})
+	t.renderDone = make(chan struct{})
+
+	os.Stdout.WriteString("\x1b[?1049h\x1b[?25l")
+	// anything logged to stderr would be drawn over the viewer
+	log.SetOutput(t)
+
+	go t.renderLoop()
+	go t.inputLoop()
+
+	return t, nil
+}
+
+func newTUIModel(history int) *TUIStream {
+	if history <= 0 {
+		history = DefaultTUIHistory
+	}
+	return &TUIStream{
+		history:   history,
+		follow:    true,
+		hosts:     map[string]bool{},
+		viewLimit: ^uint64(0),
+		dirty:     true,
+	}
+}
+
+// TUIStream is an output which shows lines in an interactive terminal viewer.
+type TUIStream struct {
+	history int
+	onQuit  func()
+	restore func()
+	size    func() (width int, height int)
+
+	stopRender chan struct{}
+	renderDone chan struct{}
+	closeOnce  sync.Once
+	quitOnce   sync.Once
+
+	lock  sync.Mutex
+	dirty bool // needs to be redrawn
+
+	// `events[i]` has the sequence number `firstSeq + i`
+	events   []types.ParsedLine
+	firstSeq uint64
+	// sequence numbers of the events which pass the filters, oldest first
+	view []uint64
+	// events at / after this sequence number are left out of the view while paused
+	viewLimit uint64
+	paused    bool
+
+	mode   tuiMode
+	cursor int  // index into `view`
+	top    int  // first row of `view` on screen
+	follow bool // keep the cursor on the newest event
+
+	// hosts seen so far, and whether they're shown
+	hosts        map[string]bool
+	hiddenLevels [types.Severity_Debug + 1]bool
+	filterCursor int
+
+	query        string
+	searchOrigin int
+
+	detailScroll int
+
+	// the last thing reform logged, shown in the footer
+	lastLog string
+}
+
+func (t *TUIStream) Output(line types.ParsedLine) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	seq := t.firstSeq + uint64(len(t.events))
+	t.events = append(t.events, line)
+	if _, seen := t.hosts[line.Host]; !seen {
+		t.hosts[line.Host] = true
+	}
+
+	if seq < t.viewLimit && t.visible(line) {
+		t.view = append(t.view, seq)
+		if t.follow {
+			t.cursor = len(t.view) - 1
+		}
+	}
+
+	if len(t.events) > t.history {
+		t.dropOldest(max(len(t.events)-t.history, t.history/10))
+	}
+
+	t.dirty = true
+	return nil
+}
+
+func (t *TUIStream) Close() {
+	t.closeOnce.Do(func() {
+		if t.stopRender != nil {
+			close(t.stopRender)
+			<-t.renderDone
+		}
+		if t.restore != nil {
+			t.restore()
+		}
+	})
+}
+
+// Write shows reform's own log lines in the footer, see `log.SetOutput`
+func (t *TUIStream) Write(p []byte) (int, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.lastLog = strings.TrimSpace(string(p))
+	t.dirty = true
+	return len(p), nil
+}
+
+func (t *TUIStream) quit() {
+	t.quitOnce.Do(func() {
+		if t.onQuit != nil {
+			go t.onQuit()
+		}
+	})
+}
+
+func (t *TUIStream) event(seq uint64) *types.ParsedLine {
+	return &t.events[seq-t.firstSeq]
+}
+
+func (t *TUIStream) selected() (*types.ParsedLine, bool) {
+	if t.cursor < 0 || t.cursor >= len(t.view) {
+		return nil, false
+	}
+	return t.event(t.view[t.cursor]), true
+}
+
+func (t *TUIStream) visible(line types.ParsedLine) bool {
+	return t.hosts[line.Host] && !t.hiddenLevels[line.Severity()]
+}
+
+func (t *TUIStream) dropOldest(count int) {
+	count = min(count, len(t.events))
+	t.events = slices.Delete(t.events, 0, count)
+	t.firstSeq += uint64(count)
+
+	dropped, _ := slices.BinarySearch(t.view, t.firstSeq)
+	t.view = slices.Delete(t.view, 0, dropped)
+	t.cursor = max(t.cursor-dropped, 0)
+	t.top = max(t.top-dropped, 0)
+	t.searchOrigin = max(t.searchOrigin-dropped, 0)
+}
+
+// rebuilds the view after the filters change, keeping the cursor on the same
+// event (or the closest one before it)
+func (t *TUIStream) rebuildView() {
+	var selectedSeq uint64
+	if len(t.view) > 0 {
+		selectedSeq = t.view[min(t.cursor, len(t.view)-1)]
+	}
+
+	t.view = t.view[:0]
+	for i, line := range t.events {
+		seq := t.firstSeq + uint64(i)
+		if seq >= t.viewLimit {
+			break
+		}
+		if t.visible(line) {
+			t.view = append(t.view, seq)
+		}
+	}
+
+	if t.follow {
+		t.cursor = len(t.view) - 1
+		return
+	}
+	idx, found := slices.BinarySearch(t.view, selectedSeq)
+	if !found {
+		idx -= 1
+	}
+	t.cursor = min(max(idx, 0), len(t.view)-1)
+}
+
+func (t *TUIStream) setPaused(paused bool) {
+	t.paused = paused
+	if paused {
+		t.viewLimit = t.firstSeq + uint64(len(t.events))
+		t.follow = false
+		return
+	}
+
+	t.viewLimit = ^uint64(0)
+	t.follow = true
+	t.rebuildView()
+}
+
+// events which have arrived since pausing
+func (t *TUIStream) pending() int {
+	if !t.paused {
+		return 0
+	}
+	return int(t.firstSeq + uint64(len(t.events)) - max(t.viewLimit, t.firstSeq))
+}
+
+func (t *TUIStream) moveCursor(delta int) {
+	if len(t.view) == 0 {
+		return
+	}
+	t.cursor = min(max(t.cursor+delta, 0), len(t.view)-1)
+	t.follow = !t.paused && t.cursor == len(t.view)-1
+}
+
+//#> TUI Stream
+
+//#< TUI Search
+
+func (t *TUIStream) matches(line *types.ParsedLine) bool {
+	if t.query == "" {
+		return false
+	}
+	return containsFold(line.Message, t.query) ||
+		containsFold(line.Host, t.query) ||
+		containsFold(line.Process.Name, t.query) ||
+		containsFold(line.Raw, t.query)
+}
+
+// moves the cursor to the nearest match from `from`, searching towards older
+// events first when `backwards`.  returns false (leaving the cursor alone) if
+// nothing matches.
+func (t *TUIStream) findMatch(from int, backwards bool) bool {
+	step := 1
+	if backwards {
+		step = -1
+	}
+
+	for i := 0; i < len(t.view); i++ {
+		idx := from + i*step
+		// wrap around
+		idx = ((idx % len(t.view)) + len(t.view)) % len(t.view)
+		if t.matches(t.event(t.view[idx])) {
+			t.cursor = idx
+			t.follow = false
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(str string, substr string) bool {
+	return indexFold(str, substr) >= 0
+}
+
+// case insensitive `strings.Index`
+func indexFold(str string, substr string) int {
+	if substr == "" {
+		return -1
+	}
+	return strings.Index(strings.ToLower(str), strings.ToLower(substr))
+}
+
+//#> TUI Search
+
+//#< TUI Input
+
+func (t *TUIStream) inputLoop() {
+	buf := make([]byte, 256)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+
+		t.lock.Lock()
+		for _, k := range decodeKeys(buf[:n]) {
+			t.handleKey(k)
+		}
+		t.dirty = true
+		t.lock.Unlock()
+	}
+}
+
+// must be called with `lock` held
+func (t *TUIStream) handleKey(k tuiKey) {
+	if k.code == key_CtrlC {
+		t.quit()
+		return
+	}
+
+	_, height := t.screenSize()
+	page := max(height-3, 1)
+
+	switch t.mode {
+	case tuiMode_List:
+		switch {
+		case k.is('q'):
+			t.quit()
+		case k.code == key_Up || k.is('k'):
+			t.moveCursor(-1)
+		case k.code == key_Down || k.is('j'):
+			t.moveCursor(1)
+		case k.code == key_PageUp:
+			t.moveCursor(-page)
+		case k.code == key_PageDown:
+			t.moveCursor(page)
+		case k.code == key_Home || k.is('g'):
+			t.moveCursor(-len(t.view))
+		case k.code == key_End || k.is('G'):
+			t.moveCursor(len(t.view))
+		case k.is(' ') || k.is('p'):
+			t.setPaused(!t.paused)
+		case k.is('/'):
+			t.mode = tuiMode_Search
+			t.query = ""
+			t.searchOrigin = t.cursor
+		case k.is('n'):
+			t.findMatch(t.cursor+1, false)
+		case k.is('N'):
+			t.findMatch(t.cursor-1, true)
+		case k.is('f'):
+			t.mode = tuiMode_Filters
+		case k.code == key_Enter:
+			if _, ok := t.selected(); ok {
+				t.mode = tuiMode_Detail
+				t.detailScroll = 0
+			}
+		case k.code == key_Escape:
+			t.query = ""
+		}
+
+	case tuiMode_Search:
+		switch {
+		case k.code == key_Enter:
+			t.mode = tuiMode_List
+		case k.code == key_Escape:
+			t.mode = tuiMode_List
+			t.query = ""
+			t.cursor = min(t.searchOrigin, len(t.view)-1)
+		case k.code == key_Backspace:
+			if len(t.query) > 0 {
+				runes := []rune(t.query)
+				t.query = string(runes[:len(runes)-1])
+			}
+			t.searchFromOrigin()
+		case k.code == key_Rune:
+			t.query += string(k.r)
+			t.searchFromOrigin()
+		}
+
+	case tuiMode_Filters:
+		items := t.filterItems()
+		switch {
+		case k.code == key_Escape || k.is('f') || k.is('q'):
+			t.mode = tuiMode_List
+		case k.code == key_Up || k.is('k'):
+			t.filterCursor = max(t.filterCursor-1, 0)
+		case k.code == key_Down || k.is('j'):
+			t.filterCursor = min(t.filterCursor+1, len(items)-1)
+		case k.is(' ') || k.code == key_Enter:
+			if t.filterCursor < len(items) {
+				items[t.filterCursor].toggle()
+				t.rebuildView()
+			}
+		case k.is('a'):
+			t.hiddenLevels = [types.Severity_Debug + 1]bool{}
+			for host := range t.hosts {
+				t.hosts[host] = true
+			}
+			t.rebuildView()
+		}
+
+	case tuiMode_Detail:
+		switch {
+		case k.code == key_Escape || k.code == key_Enter || k.is('q'):
+			t.mode = tuiMode_List
+		case k.code == key_Up || k.is('k'):
+			t.detailScroll = max(t.detailScroll-1, 0)
+		case k.code == key_Down || k.is('j'):
+			t.detailScroll += 1
+		case k.code == key_PageUp:
+			t.detailScroll = max(t.detailScroll-page, 0)
+		case k.code == key_PageDown:
+			t.detailScroll += page
+		}
+	}
+}
+
+// incremental search, from where the cursor was when the search started
+func (t *TUIStream) searchFromOrigin() {
+	if !t.findMatch(t.searchOrigin, true) && len(t.view) > 0 {
+		t.cursor = min(t.searchOrigin, len(t.view)-1)
+	}
+}
+
+type tuiFilterItem struct {
+	label  string
+	shown  bool
+	toggle func()
+}
+
+// the levels, then the hosts (sorted)
+func (t *TUIStream) filterItems() []tuiFilterItem {
+	items := []tuiFilterItem{}
+	for sev := types.Severity_Emergency; sev <= types.Severity_Debug; sev++ {
+		label, _ := severityLabel(sev)
+		items = append(items, tuiFilterItem{
+			label:  "level " + label,
+			shown:  !t.hiddenLevels[sev],
+			toggle: func() { t.hiddenLevels[sev] = !t.hiddenLevels[sev] },
+		})
+	}
+
+	for _, host := range slices.Sorted(maps.Keys(t.hosts)) {
+		label := host
+		if label == "" {
+			label = "(no host)"
+		}
+		items = append(items, tuiFilterItem{
+			label:  "host  " + label,
+			shown:  t.hosts[host],
+			toggle: func() { t.hosts[host] = !t.hosts[host] },
+		})
+	}
+	return items
+}
+
+//#> TUI Input
+
+//#< TUI Rendering
+
+func (t *TUIStream) renderLoop() {
+	defer close(t.renderDone)
+
+	ticker := time.NewTicker(tuiFrameInterval)
+	defer ticker.Stop()
+
+	lastWidth, lastHeight := 0, 0
+	for {
+		select {
+		case <-t.stopRender:
+			return
+		case <-ticker.C:
+		}
+
+		width, height := t.size()
+
+		t.lock.Lock()
+		if !t.dirty && width == lastWidth && height == lastHeight {
+			t.lock.Unlock()
+			continue
+		}
+		t.dirty = false
+		frame := t.render(width, height)
+		t.lock.Unlock()
+
+		lastWidth, lastHeight = width, height
+		os.Stdout.Write(frame)
+	}
+}
+
+func (t *TUIStream) screenSize() (int, int) {
+	if t.size == nil {
+		return 80, 24
+	}
+	return t.size()
+}
+
+// must be called with `lock` held
+func (t *TUIStream) render(width int, height int) []byte {
+	width, height = max(width, 20), max(height, 4)
+
+	rows := []string{t.renderHeader(width)}
+	bodyHeight := height - 2
+	switch t.mode {
+	case tuiMode_Filters:
+		rows = append(rows, t.renderFilters(width, bodyHeight)...)
+	case tuiMode_Detail:
+		rows = append(rows, t.renderDetail(width, bodyHeight)...)
+	default:
+		rows = append(rows, t.renderList(width, bodyHeight)...)
+	}
+	for len(rows) < height-1 {
+		rows = append(rows, "")
+	}
+	rows = append(rows, t.renderFooter(width))
+
+	b := bytes.Buffer{}
+	b.WriteString("\x1b[H")
+	for i, row := range rows {
+		if i > 0 {
+			b.WriteString("\r\n")
+		}
+		b.WriteString(row)
+		b.WriteString("\x1b[0m\x1b[K")
+	}
+	return b.Bytes()
+}
+
+func (t *TUIStream) renderHeader(width int) string {
+	state := "following"
+	if t.paused {
+		state = "PAUSED"
+		if pending := t.pending(); pending > 0 {
+			state += " (+" + strconv.Itoa(pending) + " new)"
+		}
+	} else if !t.follow {
+		state = "scrolled"
+	}
+
+	shownHosts := 0
+	for _, shown := range t.hosts {
+		if shown {
+			shownHosts += 1
+		}
+	}
+	shownLevels := 0
+	for _, hidden := range t.hiddenLevels {
+		if !hidden {
+			shownLevels += 1
+		}
+	}
+
+	header := fmt.Sprintf(" reform │ %d/%d events │ %s │ hosts %d/%d │ levels %d/%d",
+		len(t.view), len(t.events), state, shownHosts, len(t.hosts), shownLevels, len(t.hiddenLevels))
+	if t.query != "" && t.mode != tuiMode_Search {
+		header += " │ /" + t.query
+	}
+	return "\x1b[7m" + padRunes(header, width)
+}
+
+func (t *TUIStream) renderFooter(width int) string {
+	var help string
+	switch t.mode {
+	case tuiMode_Search:
+		return "/" + truncateRunes(t.query, width-2) + "\x1b[7m \x1b[0m"
+	case tuiMode_Filters:
+		help = "↑/↓ move  space toggle  a show all  esc back"
+	case tuiMode_Detail:
+		help = "↑/↓ scroll  esc back"
+	default:
+		help = "q quit  space pause  / search  n/N next/prev  f filters  enter details"
+	}
+
+	help = truncateRunes(help, width)
+	if room := width - utf8.RuneCountInString(help) - 2; t.lastLog != "" && room > 0 {
+		help += "  " + ansiGray + truncateRunes(t.lastLog, room)
+	}
+	return help
+}
+
+func (t *TUIStream) renderList(width int, height int) []string {
+	if len(t.view) == 0 {
+		return []string{ansiGray + " waiting for events..."}
+	}
+
+	// keep the cursor on screen
+	if t.cursor < t.top {
+		t.top = t.cursor
+	}
+	if t.cursor >= t.top+height {
+		t.top = t.cursor - height + 1
+	}
+	t.top = min(max(t.top, 0), max(len(t.view)-height, 0))
+
+	end := min(t.top+height, len(t.view))
+
+	// size the columns to what's on screen
+	hostWidth, procWidth := 0, 0
+	for _, seq := range t.view[t.top:end] {
+		line := t.event(seq)
+		hostWidth = max(hostWidth, utf8.RuneCountInString(line.Host))
+		procWidth = max(procWidth, utf8.RuneCountInString(procLabel(*line)))
+	}
+	hostWidth = min(hostWidth, maxTerminalHostWidth)
+	procWidth = min(procWidth, maxTerminalProcWidth)
+
+	rows := make([]string, 0, end-t.top)
+	for i := t.top; i < end; i++ {
+		rows = append(rows, t.renderRow(t.event(t.view[i]), i == t.cursor, width, hostWidth, procWidth))
+	}
+	return rows
+}
+
+func (t *TUIStream) renderRow(line *types.ParsedLine, selected bool, width int, hostWidth int, procWidth int) string {
+	colored := func(color string, str string) string {
+		if selected || color == "" {
+			return str
+		}
+		return color + str + ansiReset
+	}
+
+	b := strings.Builder{}
+	if selected {
+		b.WriteString("\x1b[7m")
+	}
+
+	used := 0
+	if line.Timestamp.IsZero() {
+		b.WriteString("            ")
+	} else {
+		b.WriteString(colored(ansiGray, line.Timestamp.Format("15:04:05.000")))
+	}
+	b.WriteByte(' ')
+	used += 13
+
+	label, levelColor := terminalLevel(*line)
+	b.WriteString(colored(levelColor, padRunes(label, 5)))
+	b.WriteByte(' ')
+	used += 6
+
+	if hostWidth > 0 {
+		b.WriteString(colored(terminalHostColor(line.Host), padRunes(line.Host, hostWidth)))
+		b.WriteByte(' ')
+		used += hostWidth + 1
+	}
+	if procWidth > 0 {
+		b.WriteString(padRunes(procLabel(*line), procWidth))
+		b.WriteByte(' ')
+		used += procWidth + 1
+	}
+
+	message, _, multiline := strings.Cut(line.Message, "\n")
+	if multiline {
+		message += " …"
+	}
+	message = truncateRunes(message, max(width-used, 0))
+
+	// highlight the search match (when lower casing doesn't shift the byte offsets)
+	if idx := indexFold(message, t.query); idx >= 0 && !selected && len(message) == len(strings.ToLower(message)) {
+		end := idx + len(t.query)
+		message = message[:idx] + "\x1b[30;43m" + message[idx:end] + ansiReset + message[end:]
+	}
+	b.WriteString(message)
+
+	if selected {
+		// fill the rest of the row, so the whole line is highlighted
+		b.WriteString(strings.Repeat(" ", max(width-used-utf8.RuneCountInString(message), 0)))
+	}
+	return b.String()
+}
+
+func (t *TUIStream) renderFilters(width int, height int) []string {
+	items := t.filterItems()
+	t.filterCursor = min(t.filterCursor, len(items)-1)
+
+	top := max(t.filterCursor-height+1, 0)
+	rows := []string{}
+	for i := top; i < len(items) && len(rows) < height; i++ {
+		check := "[ ]"
+		if items[i].shown {
+			check = "[x]"
+		}
+		row := truncateRunes(" "+check+" "+items[i].label, width)
+		if i == t.filterCursor {
+			row = "\x1b[7m" + padRunes(row, width)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func (t *TUIStream) renderDetail(width int, height int) []string {
+	line, ok := t.selected()
+	if !ok {
+		return nil
+	}
+
+	rows := []string{}
+	field := func(name string, value string) {
+		if value == "" {
+			return
+		}
+		for i, row := range wrapRunes(value, max(width-12, 10)) {
+			label := ""
+			if i == 0 {
+				label = name
+			}
+			rows = append(rows, ansiGray+padRunes(label, 11)+ansiReset+" "+row)
+		}
+	}
+
+	if !line.Timestamp.IsZero() {
+		field("time", line.Timestamp.Format(time.RFC3339Nano))
+	}
+	for _, name := range types.FixedFields {
+		if name == types.Field_Message {
+			continue
+		}
+		value, _ := line.Field(name)
+		if (name == types.Field_PID || name == types.Field_TID || name == types.Field_Line) && value == "0" {
+			continue
+		}
+		field(name, value)
+	}
+	for _, key := range slices.Sorted(maps.Keys(line.Properties)) {
+		value, _ := line.Field(key)
+		field(key, value)
+	}
+
+	rows = append(rows, "")
+	field("message", line.Message)
+	rows = append(rows, "")
+	field("raw", line.Raw)
+
+	t.detailScroll = min(t.detailScroll, max(len(rows)-height, 0))
+	return rows[t.detailScroll:min(t.detailScroll+height, len(rows))]
+}
+
+//#> TUI Rendering
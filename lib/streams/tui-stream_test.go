@@ -0,0 +1,208 @@
+package streams
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/erobsham/reform/lib/types"
+)
+
+func tuiLines(t *TUIStream) []string {
+	msgs := []string{}
+	for _, seq := range t.view {
+		msgs = append(msgs, t.event(seq).Message)
+	}
+	return msgs
+}
+
+func tuiSelected(t *TUIStream) string {
+	line, ok := t.selected()
+	if !ok {
+		return ""
+	}
+	return line.Message
+}
+
+func tuiKeys(t *TUIStream, keys string) {
+	for _, k := range decodeKeys([]byte(keys)) {
+		t.handleKey(k)
+	}
+}
+
+func TestTUIStream_Follow(t *testing.T) {
+	tui := newTUIModel(0)
+
+	tui.Output(types.ParsedLine{Message: "one"})
+	tui.Output(types.ParsedLine{Message: "two"})
+	if got := tuiSelected(tui); got != "two" {
+		t.Errorf("following: selected %q, want %q", got, "two")
+	}
+
+	// scrolling up stops following
+	tuiKeys(tui, "k")
+	tui.Output(types.ParsedLine{Message: "three"})
+	if got := tuiSelected(tui); got != "one" {
+		t.Errorf("scrolled: selected %q, want %q", got, "one")
+	}
+
+	// and going to the end starts again
+	tuiKeys(tui, "G")
+	tui.Output(types.ParsedLine{Message: "four"})
+	if got := tuiSelected(tui); got != "four" {
+		t.Errorf("end: selected %q, want %q", got, "four")
+	}
+}
+
+func TestTUIStream_Pause(t *testing.T) {
+	tui := newTUIModel(0)
+
+	tui.Output(types.ParsedLine{Message: "one"})
+	tuiKeys(tui, " ")
+	tui.Output(types.ParsedLine{Message: "two"})
+	tui.Output(types.ParsedLine{Message: "three"})
+
+	if got, want := tuiLines(tui), []string{"one"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("paused: got %v, want %v", got, want)
+	}
+	if got := tui.pending(); got != 2 {
+		t.Errorf("pending() = %d, want 2", got)
+	}
+	if header := tui.renderHeader(120); !strings.Contains(header, "PAUSED (+2 new)") {
+		t.Errorf("header doesn't show the pause: %q", header)
+	}
+
+	tuiKeys(tui, " ")
+	if got, want := tuiLines(tui), []string{"one", "two", "three"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resumed: got %v, want %v", got, want)
+	}
+	if got := tuiSelected(tui); got != "three" {
+		t.Errorf("resumed: selected %q, want %q", got, "three")
+	}
+}
+
+func TestTUIStream_Filters(t *testing.T) {
+	tui := newTUIModel(0)
+	tui.Output(types.ParsedLine{Host: "hst-a", LogLevel: "info", Message: "a info"})
+	tui.Output(types.ParsedLine{Host: "hst-b", LogLevel: "error", Message: "b error"})
+	tui.Output(types.ParsedLine{Host: "hst-a", LogLevel: "error", Message: "a error"})
+
+	// levels come first (EMERG ... DEBUG), then the hosts: INFO is the 7th item
+	tuiKeys(tui, "f"+strings.Repeat("j", 6)+" ")
+	if got, want := tuiLines(tui), []string{"b error", "a error"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hide info: got %v, want %v", got, want)
+	}
+
+	// hst-b is the 10th
+	tuiKeys(tui, "jjj ")
+	if got, want := tuiLines(tui), []string{"a error"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hide hst-b: got %v, want %v", got, want)
+	}
+
+	// hidden lines aren't added as they arrive
+	tui.Output(types.ParsedLine{Host: "hst-b", LogLevel: "error", Message: "b error 2"})
+	if got, want := tuiLines(tui), []string{"a error"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("hidden output: got %v, want %v", got, want)
+	}
+
+	tuiKeys(tui, "a\x1b")
+	if got, want := tuiLines(tui), []string{"a info", "b error", "a error", "b error 2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("show all: got %v, want %v", got, want)
+	}
+	if tui.mode != tuiMode_List {
+		t.Errorf("mode = %v, want the list", tui.mode)
+	}
+}
+
+func TestTUIStream_Search(t *testing.T) {
+	tui := newTUIModel(0)
+	for _, msg := range []string{"connected", "Timeout waiting", "ok", "timeout again", "ok"} {
+		tui.Output(types.ParsedLine{Message: msg})
+	}
+
+	// incremental, searching back from the cursor
+	tuiKeys(tui, "/time")
+	if got := tuiSelected(tui); got != "timeout again" {
+		t.Errorf("search: selected %q, want %q", got, "timeout again")
+	}
+	tuiKeys(tui, "\rN")
+	if got := tuiSelected(tui); got != "Timeout waiting" {
+		t.Errorf("prev: selected %q, want %q", got, "Timeout waiting")
+	}
+	tuiKeys(tui, "n")
+	if got := tuiSelected(tui); got != "timeout again" {
+		t.Errorf("next: selected %q, want %q", got, "timeout again")
+	}
+
+	// escape returns to where the search started
+	tuiKeys(tui, "G/conn")
+	if got := tuiSelected(tui); got != "connected" {
+		t.Errorf("search: selected %q, want %q", got, "connected")
+	}
+	tuiKeys(tui, "\x1b")
+	if got := tuiSelected(tui); got != "ok" || tui.query != "" {
+		t.Errorf("cancel: selected %q (query %q), want %q", got, tui.query, "ok")
+	}
+}
+
+func TestTUIStream_History(t *testing.T) {
+	tui := newTUIModel(10)
+	for i := range 25 {
+		tui.Output(types.ParsedLine{Message: strings.Repeat("x", i)})
+	}
+
+	if len(tui.events) > 10 {
+		t.Errorf("kept %d events, want at most 10", len(tui.events))
+	}
+	if len(tui.view) != len(tui.events) || tui.view[0] != tui.firstSeq {
+		t.Errorf("view doesn't match the events: %v (first seq %d)", tui.view, tui.firstSeq)
+	}
+	if got := tuiSelected(tui); got != strings.Repeat("x", 24) {
+		t.Errorf("selected %q, want the newest", got)
+	}
+}
+
+func TestTUIStream_Detail(t *testing.T) {
+	tui := newTUIModel(0)
+	tui.Output(types.ParsedLine{
+		Host:       "hst-name001",
+		Process:    types.ProcessInfo{Name: "sshd", PID: 42},
+		Message:    "accepted key",
+		Properties: map[string]any{"user": "root"},
+		Raw:        "Mar  1 10:00:00 hst-name001 sshd[42]: accepted key",
+	})
+
+	tuiKeys(tui, "\r")
+	if tui.mode != tuiMode_Detail {
+		t.Fatalf("mode = %v, want the detail view", tui.mode)
+	}
+
+	frame := string(tui.render(100, 30))
+	for _, want := range []string{"hst-name001", "sshd", "42", "user", "root", "accepted key", "Mar  1 10:00:00 hst-name001 sshd[42]: accepted key"} {
+		if !strings.Contains(frame, want) {
+			t.Errorf("detail view is missing %q", want)
+		}
+	}
+
+	tuiKeys(tui, "\x1b")
+	if tui.mode != tuiMode_List {
+		t.Errorf("mode = %v, want the list", tui.mode)
+	}
+}
+
+func Test_decodeKeys(t *testing.T) {
+	got := decodeKeys([]byte("a\x1b[A\x1b[6~\r\x7f\x03é\x1b"))
+	want := []tuiKey{
+		{code: key_Rune, r: 'a'},
+		{code: key_Up},
+		{code: key_PageDown},
+		{code: key_Enter},
+		{code: key_Backspace},
+		{code: key_CtrlC},
+		{code: key_Rune, r: 'é'},
+		{code: key_Escape},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got vs want:\n  %+v\n  %+v", got, want)
+	}
+}
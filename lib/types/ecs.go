@@ -0,0 +1,80 @@
+package types
+
+import (
+	"time"
+)
+
+// the version of the [Elastic Common Schema](https://www.elastic.co/guide/en/ecs/current/index.html)
+// which `ECS` follows
+const ECSVersion = "8.11.0"
+
+// ECS maps the line onto Elastic Common Schema fields:
+//
+//	@timestamp, message, host.name, process.{name,pid,thread.id},
+//	log.level, log.origin.file.{name,line}, service.name (the source)
+//
+// properties are added as top-level fields, unless they'd collide with one
+// of the ECS fields above.
+func (l ParsedLine) ECS() map[string]any {
+	doc := map[string]any{
+		"ecs": map[string]any{"version": ECSVersion},
+	}
+
+	if !l.Timestamp.IsZero() {
+		doc["@timestamp"] = l.Timestamp.Format(time.RFC3339Nano)
+	}
+	if l.Message != "" {
+		doc["message"] = l.Message
+	}
+	if l.Host != "" {
+		doc["host"] = map[string]any{"name": l.Host}
+	}
+	if l.Source != "" {
+		doc["service"] = map[string]any{"name": l.Source}
+	}
+
+	process := map[string]any{}
+	if l.Process.Name != "" {
+		process["name"] = l.Process.Name
+	}
+	if l.Process.PID != 0 {
+		process["pid"] = l.Process.PID
+	}
+	if l.Process.TID != 0 {
+		process["thread"] = map[string]any{"id": l.Process.TID}
+	}
+	if len(process) > 0 {
+		doc["process"] = process
+	}
+
+	logField := map[string]any{}
+	if l.LogLevel != "" {
+		logField["level"] = l.LogLevel
+	}
+	file := map[string]any{}
+	if l.SourceInfo.Filename != "" {
+		file["name"] = l.SourceInfo.Filename
+	}
+	if l.SourceInfo.LineNumber != 0 {
+		file["line"] = l.SourceInfo.LineNumber
+	}
+	if len(file) > 0 {
+		logField["origin"] = map[string]any{"file": file}
+	}
+	if len(logField) > 0 {
+		doc["log"] = logField
+	}
+
+	if l.SourceInfo.Language != "" {
+		doc["labels"] = map[string]any{"lang": l.SourceInfo.Language}
+	}
+
+	for key, value := range l.Properties {
+		if _, exists := doc[key]; exists {
+			continue
+		}
+		doc[key] = value
+	}
+
+	return doc
+}
@@ -0,0 +1,53 @@
+package types
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParsedLine_ECS(t *testing.T) {
+	tests := []struct {
+		name string
+		line ParsedLine
+		want string
+	}{
+		{
+			name: "empty",
+			line: ParsedLine{},
+			want: `{"ecs":{"version":"8.11.0"}}`,
+		},
+		{
+			name: "all fields",
+			line: ParsedLine{
+				Timestamp:  time.Date(2025, 3, 4, 5, 6, 7, 8000000, time.UTC),
+				Host:       "hst-name001",
+				Process:    ProcessInfo{Name: "acme", PID: 12, TID: 34},
+				Message:    "hello",
+				LogLevel:   "warn",
+				SourceInfo: SourceFileInfo{Language: "C", Filename: "main.c", LineNumber: 890},
+				Source:     "lab",
+			},
+			want: `{"@timestamp":"2025-03-04T05:06:07.008Z","ecs":{"version":"8.11.0"},"host":{"name":"hst-name001"},"labels":{"lang":"C"},"log":{"level":"warn","origin":{"file":{"line":890,"name":"main.c"}}},"message":"hello","process":{"name":"acme","pid":12,"thread":{"id":34}},"service":{"name":"lab"}}`,
+		},
+		{
+			name: "properties",
+			line: ParsedLine{
+				Message:    "hello",
+				Properties: map[string]any{"message": "ignored", "user": "bob"},
+			},
+			want: `{"ecs":{"version":"8.11.0"},"message":"hello","user":"bob"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.line.ECS())
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			if string(data) != tt.want {
+				t.Errorf("ECS() got vs want:\n  %s\n  %s", data, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package types
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// names for the `ParsedLine` fields, as used when picking out fields by name
+// (ie as labels for outputs). they match the CLEF json keys where possible.
+const (
+	Field_Host     = "host"
+	Field_Process  = "proc"
+	Field_PID      = "pid"
+	Field_TID      = "tid"
+	Field_Level    = "level"
+	Field_Message  = "msg"
+	Field_Source   = "source"
+	Field_File     = "file"
+	Field_Line     = "line"
+	Field_Language = "lang"
+)
+
+// Field returns the string value of a field by name, falling back to the
+// line's properties for anything that isn't a fixed field.
+func (l ParsedLine) Field(name string) (string, bool) {
+	switch name {
+	case Field_Host:
+		return l.Host, l.Host != ""
+	case Field_Process:
+		return l.Process.Name, l.Process.Name != ""
+	case Field_PID:
+		return strconv.FormatUint(l.Process.PID, 10), l.Process.PID != 0
+	case Field_TID:
+		return strconv.FormatUint(l.Process.TID, 10), l.Process.TID != 0
+	case Field_Level:
+		return l.LogLevel, l.LogLevel != ""
+	case Field_Message:
+		return l.Message, l.Message != ""
+	case Field_Source:
+		return l.Source, l.Source != ""
+	case Field_File:
+		return l.SourceInfo.Filename, l.SourceInfo.Filename != ""
+	case Field_Line:
+		return strconv.FormatUint(l.SourceInfo.LineNumber, 10), l.SourceInfo.LineNumber != 0
+	case Field_Language:
+		return l.SourceInfo.Language, l.SourceInfo.Language != ""
+	}
+
+	v, ok := l.Properties[name]
+	if !ok {
+		return "", false
+	}
+	if str, isStr := v.(string); isStr {
+		return str, true
+	}
+	return fmt.Sprint(v), true
+}
+
+// all the fixed fields (other than the timestamp) which `Field` knows about.
+var FixedFields = []string{
+	Field_Host,
+	Field_Process,
+	Field_PID,
+	Field_TID,
+	Field_Level,
+	Field_Message,
+	Field_Source,
+	Field_File,
+	Field_Line,
+	Field_Language,
+}
@@ -0,0 +1,121 @@
+package types
+
+import (
+	"strings"
+)
+
+// syslog severities (RFC 5424), lower is more severe
+type Severity uint8
+
+const (
+	Severity_Emergency Severity = iota
+	Severity_Alert
+	Severity_Critical
+	Severity_Error
+	Severity_Warning
+	Severity_Notice
+	Severity_Info
+	Severity_Debug
+)
+
+// the short level name, as the parser normalizes them
+func (s Severity) String() string {
+	switch s {
+	case Severity_Emergency:
+		return "emerg"
+	case Severity_Alert:
+		return "alert"
+	case Severity_Critical:
+		return "crit"
+	case Severity_Error:
+		return "error"
+	case Severity_Warning:
+		return "warn"
+	case Severity_Notice:
+		return "notice"
+	case Severity_Info:
+		return "info"
+	default:
+		return "debug"
+	}
+}
+
+// the normalized levels from the parser, plus the common spellings
+// that come in through properties / other formats
+var levelSeverities = map[string]Severity{
+	"emerg":     Severity_Emergency,
+	"emergency": Severity_Emergency,
+	"fatal":     Severity_Emergency,
+	"alert":     Severity_Alert,
+	"crit":      Severity_Critical,
+	"critical":  Severity_Critical,
+	"err":       Severity_Error,
+	"error":     Severity_Error,
+	"wrn":       Severity_Warning,
+	"warn":      Severity_Warning,
+	"warning":   Severity_Warning,
+	"notice":    Severity_Notice,
+	"inf":       Severity_Info,
+	"info":      Severity_Info,
+	"dbg":       Severity_Debug,
+	"debug":     Severity_Debug,
+	"trace":     Severity_Debug,
+}
+
+// LevelSeverity maps a log level name (ie `warn`) onto its syslog severity,
+// returning `false` for empty / unknown levels.
+func LevelSeverity(level string) (Severity, bool) {
+	sev, ok := levelSeverities[strings.ToLower(level)]
+	return sev, ok
+}
+
+// Severity returns the line's syslog severity, defaulting to `info` for
+// lines without a (known) level.
+func (l ParsedLine) Severity() Severity {
+	if sev, ok := LevelSeverity(l.LogLevel); ok {
+		return sev
+	}
+	return Severity_Info
+}
+
+// OpenTelemetry `SeverityNumber`s, the first of each range
+// see: https://opentelemetry.io/docs/specs/otel/logs/data-model/#field-severitynumber
+const (
+	OTelSeverity_Unspecified = 0
+	OTelSeverity_Debug       = 5
+	OTelSeverity_Info        = 9
+	OTelSeverity_Info2       = 10
+	OTelSeverity_Warn        = 13
+	OTelSeverity_Error       = 17
+	OTelSeverity_Fatal       = 21
+	OTelSeverity_Fatal2      = 22
+	OTelSeverity_Fatal3      = 23
+)
+
+// OTelSeverity maps a log level onto an OpenTelemetry severity number,
+// following the spec's mapping for syslog severities.
+func OTelSeverity(level string) int {
+	sev, ok := LevelSeverity(level)
+	if !ok {
+		return OTelSeverity_Unspecified
+	}
+
+	switch sev {
+	case Severity_Emergency:
+		return OTelSeverity_Fatal3
+	case Severity_Alert:
+		return OTelSeverity_Fatal2
+	case Severity_Critical:
+		return OTelSeverity_Fatal
+	case Severity_Error:
+		return OTelSeverity_Error
+	case Severity_Warning:
+		return OTelSeverity_Warn
+	case Severity_Notice:
+		return OTelSeverity_Info2
+	case Severity_Info:
+		return OTelSeverity_Info
+	default:
+		return OTelSeverity_Debug
+	}
+}
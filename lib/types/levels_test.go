@@ -0,0 +1,32 @@
+package types
+
+import "testing"
+
+func TestLevelSeverity(t *testing.T) {
+	tests := []struct {
+		level        string
+		wantSeverity Severity
+		wantOK       bool
+		wantOTel     int
+	}{
+		{level: "debug", wantSeverity: Severity_Debug, wantOK: true, wantOTel: OTelSeverity_Debug},
+		{level: "info", wantSeverity: Severity_Info, wantOK: true, wantOTel: OTelSeverity_Info},
+		{level: "WARNING", wantSeverity: Severity_Warning, wantOK: true, wantOTel: OTelSeverity_Warn},
+		{level: "error", wantSeverity: Severity_Error, wantOK: true, wantOTel: OTelSeverity_Error},
+		{level: "crit", wantSeverity: Severity_Critical, wantOK: true, wantOTel: OTelSeverity_Fatal},
+		{level: "alert", wantSeverity: Severity_Alert, wantOK: true, wantOTel: OTelSeverity_Fatal2},
+		{level: "", wantSeverity: 0, wantOK: false, wantOTel: OTelSeverity_Unspecified},
+		{level: "verbose", wantSeverity: 0, wantOK: false, wantOTel: OTelSeverity_Unspecified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.level, func(t *testing.T) {
+			got, ok := LevelSeverity(tt.level)
+			if got != tt.wantSeverity || ok != tt.wantOK {
+				t.Errorf("LevelSeverity() = %v, %v, want %v, %v", got, ok, tt.wantSeverity, tt.wantOK)
+			}
+			if got := OTelSeverity(tt.level); got != tt.wantOTel {
+				t.Errorf("OTelSeverity() = %v, want %v", got, tt.wantOTel)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package types
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// AppendLogfmt appends the line to `b` as [logfmt](https://brandur.org/logfmt):
+//
+//	time=2024-03-09T23:30:00Z host=hst-name001 proc=sshd pid=42 level=info msg="some message"
+//
+// using the `Field_*` names, followed by any properties (sorted by key).
+// empty fields are left out.
+func (l ParsedLine) AppendLogfmt(b []byte) []byte {
+	start := len(b)
+	appendLogfmtPair := func(b []byte, key string, value string) []byte {
+		if len(b) > start {
+			b = append(b, ' ')
+		}
+		return appendLogfmtKeyValue(b, key, value)
+	}
+
+	if !l.Timestamp.IsZero() {
+		b = appendLogfmtPair(b, "time", l.Timestamp.Format(time.RFC3339Nano))
+	}
+	for _, name := range FixedFields {
+		if v, ok := l.Field(name); ok {
+			b = appendLogfmtPair(b, name, v)
+		}
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(l.Properties)) {
+		if key == "time" || slices.Contains(FixedFields, key) {
+			continue
+		}
+
+		switch v := l.Properties[key].(type) {
+		case string:
+			b = appendLogfmtPair(b, key, v)
+		default:
+			data, err := json.Marshal(v)
+			if err != nil {
+				continue
+			}
+			b = appendLogfmtPair(b, key, string(data))
+		}
+	}
+
+	return b
+}
+
+func (l ParsedLine) Logfmt() string {
+	return string(l.AppendLogfmt(nil))
+}
+
+func appendLogfmtKeyValue(b []byte, key string, value string) []byte {
+	b = append(b, key...)
+	b = append(b, '=')
+	if logfmtNeedsQuotes(value) {
+		return strconv.AppendQuote(b, value)
+	}
+	return append(b, value...)
+}
+
+func logfmtNeedsQuotes(value string) bool {
+	if value == "" {
+		return true
+	}
+	for _, r := range value {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
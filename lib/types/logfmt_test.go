@@ -0,0 +1,53 @@
+package types
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsedLine_Logfmt(t *testing.T) {
+	tests := []struct {
+		name string
+		line ParsedLine
+		want string
+	}{
+		{
+			name: "empty",
+			line: ParsedLine{},
+			want: ``,
+		},
+		{
+			name: "all fields",
+			line: ParsedLine{
+				Timestamp:  time.Date(2025, 3, 4, 5, 6, 7, 8000000, time.UTC),
+				Host:       "hst-name001",
+				Process:    ProcessInfo{Name: "acme", PID: 12, TID: 34},
+				Message:    "hello there",
+				LogLevel:   "warn",
+				SourceInfo: SourceFileInfo{Language: "C", Filename: "main.c", LineNumber: 890},
+				Source:     "lab",
+			},
+			want: `time=2025-03-04T05:06:07.008Z host=hst-name001 proc=acme pid=12 tid=34 level=warn msg="hello there" source=lab file=main.c line=890 lang=C`,
+		},
+		{
+			name: "quoting",
+			line: ParsedLine{Message: "a=\"b\"\nc"},
+			want: `msg="a=\"b\"\nc"`,
+		},
+		{
+			name: "properties",
+			line: ParsedLine{
+				Message:    "hello",
+				Properties: map[string]any{"msg": "ignored", "user": "bob", "count": 3, "tags": []string{"a", "b"}},
+			},
+			want: `msg=hello count=3 tags="[\"a\",\"b\"]" user=bob`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.line.Logfmt(); got != tt.want {
+				t.Errorf("Logfmt() got vs want:\n  %s\n  %s", got, tt.want)
+			}
+		})
+	}
+}
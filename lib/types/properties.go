@@ -0,0 +1,87 @@
+package types
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+)
+
+// keys used by the fixed `ParsedLine` fields, which properties can't overwrite
+var reservedKeys = map[string]struct{}{
+	"@t":   {},
+	"@m":   {},
+	"@l":   {},
+	"host": {},
+	"proc": {},
+	"src":  {},
+}
+
+// same fields, without the custom (un)marshaling
+type parsedLineJSON ParsedLine
+
+func (l ParsedLine) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(parsedLineJSON(l))
+	if err != nil || len(l.Properties) == 0 {
+		return data, err
+	}
+
+	// splice the properties in as top-level keys: `{...fields, ...props}`
+	buf := data[:len(data)-1]
+	for _, key := range slices.Sorted(maps.Keys(l.Properties)) {
+		if _, reserved := reservedKeys[key]; reserved {
+			continue
+		}
+
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(l.Properties[key])
+		if err != nil {
+			return nil, err
+		}
+
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, k...)
+		buf = append(buf, ':')
+		buf = append(buf, v...)
+	}
+	buf = append(buf, '}')
+
+	return buf, nil
+}
+
+func (l *ParsedLine) UnmarshalJSON(data []byte) error {
+	var line parsedLineJSON
+	err := json.Unmarshal(data, &line)
+	if err != nil {
+		return err
+	}
+
+	var all map[string]any
+	err = json.Unmarshal(data, &all)
+	if err != nil {
+		return err
+	}
+	for key := range reservedKeys {
+		delete(all, key)
+	}
+	if len(all) > 0 {
+		line.Properties = all
+	}
+
+	*l = ParsedLine(line)
+	return nil
+}
+
+// WithProperty returns a copy of the line with the property set, leaving
+// the original's properties untouched.
+func (l ParsedLine) WithProperty(key string, value any) ParsedLine {
+	props := make(map[string]any, len(l.Properties)+1)
+	maps.Copy(props, l.Properties)
+	props[key] = value
+	l.Properties = props
+	return l
+}
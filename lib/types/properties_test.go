@@ -0,0 +1,75 @@
+package types
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsedLine_JSON(t *testing.T) {
+	ts := time.Date(2025, 6, 12, 8, 24, 46, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		line     ParsedLine
+		wantJSON string
+	}{
+		{
+			name:     "no properties",
+			line:     ParsedLine{Timestamp: ts, Host: "hst-name0000", Message: "hello"},
+			wantJSON: `{"@t":"2025-06-12T08:24:46Z","host":"hst-name0000","@m":"hello"}`,
+		},
+		{
+			name: "properties are top-level",
+			line: ParsedLine{
+				Timestamp:  ts,
+				Message:    "hello",
+				Properties: map[string]any{"repeat_count": float64(3), "zone": "a"},
+			},
+			wantJSON: `{"@t":"2025-06-12T08:24:46Z","@m":"hello","repeat_count":3,"zone":"a"}`,
+		},
+		{
+			name: "reserved properties are skipped",
+			line: ParsedLine{
+				Timestamp:  ts,
+				Message:    "hello",
+				Properties: map[string]any{"@m": "nope", "ok": true},
+			},
+			wantJSON: `{"@t":"2025-06-12T08:24:46Z","@m":"hello","ok":true}`,
+		},
+		{
+			name: "the input's name isn't written",
+			line: ParsedLine{
+				Timestamp:  ts,
+				Message:    "hello",
+				Source:     "lab",
+				Properties: map[string]any{"source": "app.go"},
+			},
+			wantJSON: `{"@t":"2025-06-12T08:24:46Z","@m":"hello","source":"app.go"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.line)
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+			if string(data) != tt.wantJSON {
+				t.Errorf("json.Marshal() got vs want:\n  %s\n  %s", data, tt.wantJSON)
+			}
+
+			var got ParsedLine
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("json.Unmarshal() error = %v", err)
+			}
+
+			want := tt.line
+			want.Source = ""
+			delete(want.Properties, "@m")
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("json.Unmarshal() got vs want:\n  %+v\n  %+v", got, want)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // special keys from '[CLEF](https://clef-json.org/)' standard:
@@ -20,6 +21,16 @@ type ParsedLine struct {
 	// optional
 	LogLevel   string         `json:"@l,omitempty"`
 	SourceInfo SourceFileInfo `json:"src,omitzero"`
+
+	// name of the input stream the line was read from,
+	// not part of the CLEF output
+	Source string `json:"-"`
+
+	// any extra structured data, written as top-level CLEF properties
+	Properties map[string]any `json:"-"`
+
+	// the unparsed text the line came from
+	Raw string `json:"-"`
 }
 
 type ProcessInfo struct {
@@ -52,12 +63,19 @@ func (l ParsedLine) String() string {
 	return b.String()
 }
 
+// keeps the first `maxLen` runes of `str`
 func trimEndTo(str string, maxLen int) string {
-	strLen := len(str)
-	return str[:min(strLen, maxLen)]
+	if utf8.RuneCountInString(str) <= maxLen {
+		return str
+	}
+	return string([]rune(str)[:maxLen])
 }
+
+// keeps the last `maxLen` runes of `str`
 func trimStartTo(str string, maxLen int) string {
-	strLen := len(str)
-	startIdx := max(0, strLen-maxLen)
-	return str[startIdx:strLen]
+	runes := []rune(str)
+	if len(runes) <= maxLen {
+		return str
+	}
+	return string(runes[len(runes)-maxLen:])
 }
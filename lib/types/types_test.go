@@ -0,0 +1,54 @@
+package types
+
+import (
+	"testing"
+)
+
+func Test_trimTo(t *testing.T) {
+	tests := []struct {
+		name      string
+		str       string
+		maxLen    int
+		wantEnd   string
+		wantStart string
+	}{
+		{
+			name:      "short",
+			str:       "abc",
+			maxLen:    5,
+			wantEnd:   "abc",
+			wantStart: "abc",
+		},
+		{
+			name:      "ascii",
+			str:       "abcdef",
+			maxLen:    3,
+			wantEnd:   "abc",
+			wantStart: "def",
+		},
+		{
+			name:      "multi-byte runes",
+			str:       "héllo wörld",
+			maxLen:    4,
+			wantEnd:   "héll",
+			wantStart: "örld",
+		},
+		{
+			name:      "fits in runes, not bytes",
+			str:       "日本語",
+			maxLen:    3,
+			wantEnd:   "日本語",
+			wantStart: "日本語",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := trimEndTo(tt.str, tt.maxLen); got != tt.wantEnd {
+				t.Errorf("trimEndTo() = %q, want %q", got, tt.wantEnd)
+			}
+			if got := trimStartTo(tt.str, tt.maxLen); got != tt.wantStart {
+				t.Errorf("trimStartTo() = %q, want %q", got, tt.wantStart)
+			}
+		})
+	}
+}